@@ -0,0 +1,176 @@
+package alias
+
+import (
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/utils"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"sort"
+)
+
+var ErrAliasConflict = errors.New("alias conflicts with existing canonical name or alias")
+
+/*
+AliasGroup 为一个规范名及其所有别名。
+*/
+type AliasGroup struct {
+	CanonicalName string
+	Aliases       []string
+}
+
+/*
+setAliases 将 aliases 设为 canonicalName 的别名。已经是其它规范名的别名的，改为 canonicalName 的别名。
+canonicalName 本身是别名，或 aliases 中有其它实体的规范名时返回 ErrAliasConflict。
+*/
+func setAliases(setting *AliasSetting, ctx context.Context, canonicalName string, aliases []string) error {
+	return setAliasesWithDB(setting, setting.GetMetadataDatabase().WithContext(ctx), canonicalName, aliases)
+}
+
+/*
+setAliasesWithDB 同 setAliases，db 为调用方的事务时在该事务中执行。
+*/
+func setAliasesWithDB(setting *AliasSetting, db *gorm.DB, canonicalName string, aliases []string) error {
+	aliases = uniqueNames(aliases, canonicalName)
+	if len(canonicalName) == 0 || len(aliases) == 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Model(&metadata.EntityAlias{}).Where("alias = ?", canonicalName).Count(&count).Error
+		if err != nil {
+			return utils.WrapErrorf(err, "count alias [%s] fail", canonicalName)
+		}
+		if count != 0 {
+			return utils.WrapErrorf(ErrAliasConflict, "canonical name [%s] is an alias", canonicalName)
+		}
+
+		err = tx.Model(&metadata.EntityAlias{}).Where("canonical_name in ?", aliases).Count(&count).Error
+		if err != nil {
+			return utils.WrapError(err, "count canonical names fail")
+		}
+		if count != 0 {
+			return utils.WrapErrorf(ErrAliasConflict, "aliases %#v contain canonical names", aliases)
+		}
+
+		var existing []metadata.EntityAlias
+		if err := tx.Where("alias in ?", aliases).Find(&existing).Error; err != nil {
+			return utils.WrapError(err, "select existing aliases fail")
+		}
+
+		unchanged := make(map[string]struct{}, len(existing))
+		repointed := make([]uint, 0, len(existing))
+		for _, row := range existing {
+			if row.CanonicalName == canonicalName {
+				unchanged[row.Alias] = struct{}{}
+			} else {
+				repointed = append(repointed, row.ID)
+			}
+		}
+
+		if len(repointed) != 0 {
+			if err := tx.Delete(&metadata.EntityAlias{}, repointed).Error; err != nil {
+				return utils.WrapError(err, "delete repointed aliases fail")
+			}
+		}
+
+		rows := make([]metadata.EntityAlias, 0, len(aliases))
+		for _, alias := range aliases {
+			if _, ok := unchanged[alias]; ok {
+				continue
+			}
+
+			rows = append(rows, metadata.EntityAlias{
+				CanonicalName: canonicalName,
+				Alias:         alias,
+			})
+		}
+
+		if len(rows) == 0 {
+			return nil
+		}
+
+		if err := tx.Create(&rows).Error; err != nil {
+			return utils.WrapError(err, "insert aliases fail")
+		}
+
+		setting.Logger.Infof("set aliases %#v of [%s]", aliases, canonicalName)
+		return nil
+	})
+}
+
+/*
+removeAliases 删除 aliases，不存在的别名会被忽略。
+*/
+func removeAliases(setting *AliasSetting, ctx context.Context, aliases []string) error {
+	return removeAliasesWithDB(setting, setting.GetMetadataDatabase().WithContext(ctx), aliases)
+}
+
+/*
+removeAliasesWithDB 同 removeAliases，db 为调用方的事务时在该事务中执行。
+*/
+func removeAliasesWithDB(setting *AliasSetting, db *gorm.DB, aliases []string) error {
+	aliases = uniqueNames(aliases, "")
+	if len(aliases) == 0 {
+		return nil
+	}
+
+	err := db.
+		Where("alias in ?", aliases).
+		Delete(&metadata.EntityAlias{}).Error
+	if err != nil {
+		return utils.WrapErrorf(err, "delete aliases %#v fail", aliases)
+	}
+
+	setting.Logger.Infof("remove aliases %#v", aliases)
+	return nil
+}
+
+/*
+listAliases 列出所有规范名及其别名，按规范名排序。canonicalName 非空时只列出该规范名。
+*/
+func listAliases(setting *AliasSetting, ctx context.Context, canonicalName string) ([]AliasGroup, error) {
+	query := setting.GetMetadataDatabase().WithContext(ctx).Order("canonical_name, alias")
+	if len(canonicalName) != 0 {
+		query = query.Where("canonical_name = ?", canonicalName)
+	}
+
+	var rows []metadata.EntityAlias
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, utils.WrapError(err, "select aliases fail")
+	}
+
+	ret := make([]AliasGroup, 0)
+	for _, row := range rows {
+		if len(ret) == 0 || ret[len(ret)-1].CanonicalName != row.CanonicalName {
+			ret = append(ret, AliasGroup{CanonicalName: row.CanonicalName})
+		}
+
+		group := &ret[len(ret)-1]
+		group.Aliases = append(group.Aliases, row.Alias)
+	}
+
+	return ret, nil
+}
+
+/*
+uniqueNames 去除 names 中的空串、重复的名字和 exclude，并排序。
+*/
+func uniqueNames(names []string, exclude string) []string {
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		if len(name) == 0 || name == exclude {
+			continue
+		}
+		set[name] = struct{}{}
+	}
+
+	ret := make([]string, 0, len(set))
+	for name := range set {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
@@ -0,0 +1,66 @@
+package alias
+
+import (
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/repository/metadata"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestAliases(t *testing.T) {
+	logging.SetDefaultConfig(logging.GenerateTestConfig(t))
+
+	database, err := metadata.CreateDatabase(metadata.GenerateTestConfig())
+	require.Nil(t, err)
+
+	setting := AliasSetting{
+		GetMetadataDatabase: func() *gorm.DB {
+			return database
+		},
+		Logger: logging.NewLogger(),
+	}
+	ctx := context.TODO()
+
+	err = setAliases(&setting, ctx, "TestAliases-北京航空航天大学", []string{"TestAliases-北航", "TestAliases-BUAA", "TestAliases-北航", "TestAliases-北京航空航天大学"})
+	require.Nil(t, err)
+
+	groups, err := listAliases(&setting, ctx, "TestAliases-北京航空航天大学")
+	require.Nil(t, err)
+	assert.Equal(t, []AliasGroup{{
+		CanonicalName: "TestAliases-北京航空航天大学",
+		Aliases:       []string{"TestAliases-BUAA", "TestAliases-北航"},
+	}}, groups)
+
+	// 规范名不能是别名，别名不能是规范名
+	err = setAliases(&setting, ctx, "TestAliases-北航", []string{"TestAliases-航大"})
+	assert.ErrorIs(t, err, ErrAliasConflict)
+
+	err = setAliases(&setting, ctx, "TestAliases-北航大学", []string{"TestAliases-北京航空航天大学"})
+	assert.ErrorIs(t, err, ErrAliasConflict)
+
+	// 别名改为其它规范名的别名
+	err = setAliases(&setting, ctx, "TestAliases-北京航大", []string{"TestAliases-BUAA"})
+	require.Nil(t, err)
+
+	groups, err = listAliases(&setting, ctx, "TestAliases-北京航空航天大学")
+	require.Nil(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, []string{"TestAliases-北航"}, groups[0].Aliases)
+
+	err = removeAliases(&setting, ctx, []string{"TestAliases-北航", "TestAliases-不存在"})
+	require.Nil(t, err)
+
+	groups, err = listAliases(&setting, ctx, "TestAliases-北京航空航天大学")
+	require.Nil(t, err)
+	assert.Empty(t, groups)
+
+	groups, err = listAliases(&setting, ctx, "TestAliases-北京航大")
+	require.Nil(t, err)
+	assert.Equal(t, []AliasGroup{{
+		CanonicalName: "TestAliases-北京航大",
+		Aliases:       []string{"TestAliases-BUAA"},
+	}}, groups)
+}
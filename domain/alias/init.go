@@ -0,0 +1,44 @@
+package alias
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type AliasSetting struct {
+	GetMetadataDatabase func() *gorm.DB
+	Logger              *logrus.Logger
+}
+
+var globalSetting AliasSetting
+
+func Init(setting *AliasSetting) {
+	globalSetting = *setting
+}
+
+func SetAliases(ctx context.Context, canonicalName string, aliases []string) error {
+	return setAliases(&globalSetting, ctx, canonicalName, aliases)
+}
+
+func RemoveAliases(ctx context.Context, aliases []string) error {
+	return removeAliases(&globalSetting, ctx, aliases)
+}
+
+/*
+SetAliasesWithTx 在调用方的事务 tx 中设置别名，与其它写入一起提交或回滚
+*/
+func SetAliasesWithTx(tx *gorm.DB, canonicalName string, aliases []string) error {
+	return setAliasesWithDB(&globalSetting, tx, canonicalName, aliases)
+}
+
+/*
+RemoveAliasesWithTx 在调用方的事务 tx 中删除别名，与其它写入一起提交或回滚
+*/
+func RemoveAliasesWithTx(tx *gorm.DB, aliases []string) error {
+	return removeAliasesWithDB(&globalSetting, tx, aliases)
+}
+
+func ListAliases(ctx context.Context, canonicalName string) ([]AliasGroup, error) {
+	return listAliases(&globalSetting, ctx, canonicalName)
+}
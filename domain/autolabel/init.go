@@ -0,0 +1,34 @@
+package autolabel
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type LabelSetting struct {
+	GetMetadataDatabase func() *gorm.DB
+	Logger              *logrus.Logger
+}
+
+var globalSetting LabelSetting
+
+func Init(setting *LabelSetting) {
+	globalSetting = *setting
+
+	if err := failInterruptedJobs(&globalSetting); err != nil {
+		globalSetting.Logger.WithError(err).Errorf("fail interrupted label jobs error=\n%v", err)
+	}
+}
+
+func Submit(ctx context.Context, config *LabelConfig) (uint, error) {
+	return submit(&globalSetting, ctx, config)
+}
+
+func Cancel(jobID uint) error {
+	return cancel(jobID)
+}
+
+func GetJob(jobID uint) (*JobInfo, error) {
+	return getJob(&globalSetting, jobID)
+}
@@ -0,0 +1,393 @@
+package autolabel
+
+import (
+	"autograph-backend-controller/domain/tagger"
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"gorm.io/gorm"
+	"sync"
+	"time"
+)
+
+var (
+	ErrJobNotRunning = errors.New("label job is not running")
+
+	errInterrupted = errors.New("label job interrupted by server restart")
+)
+
+/*
+runningJobs 记录正在进行的任务的取消函数，用于取消任务
+*/
+var runningJobs = struct {
+	sync.Mutex
+	cancels map[uint]context.CancelFunc // LabelJob.ID -> cancel
+}{
+	cancels: make(map[uint]context.CancelFunc),
+}
+
+type JobInfo struct {
+	ID          uint
+	BuildID     uint
+	Name        string
+	Desc        string
+	Status      uint
+	Progress    uint
+	ExtractorID *uint
+	Result      LabelResult
+	ErrMsg      string
+	CreateTime  time.Time
+	UpdateTime  time.Time
+}
+
+/*
+jobConfig 为 LabelJob.ConfigJSON 的内容
+*/
+type jobConfig struct {
+	FileIDList []uint               `json:"file_list"`
+	TextIDList []uint               `json:"text_list"`
+	Matcher    tagger.MatcherOption `json:"matcher"`
+}
+
+/*
+submit 检查构建和要标注的句子，创建一条标注任务记录，并在后台开始标注，返回 LabelJob.ID。
+构建不存在时返回 gorm.ErrRecordNotFound，没有要标注的句子时返回 ErrNoText。
+*/
+func submit(setting *LabelSetting, ctx context.Context, config *LabelConfig) (uint, error) {
+	runner, err := newJobRunner(setting, ctx, config)
+	if err != nil {
+		return 0, err
+	}
+
+	runner.start()
+	return runner.jobID, nil
+}
+
+/*
+newJobRunner 检查构建和要标注的句子，创建一条标注任务记录
+*/
+func newJobRunner(setting *LabelSetting, ctx context.Context, config *LabelConfig) (*jobRunner, error) {
+	if len(config.FileIDList) == 0 && len(config.TextIDList) == 0 {
+		return nil, ErrNoText
+	}
+
+	db := setting.GetMetadataDatabase().WithContext(ctx)
+
+	var build metadata.Build
+	if err := db.Select("id").Take(&build, config.BuildID).Error; err != nil {
+		return nil, utils.WrapErrorf(err, "select build [%d] fail", config.BuildID)
+	}
+
+	var total int64
+	if err := db.Model(&metadata.Text{}).Scopes(textScope(config)).Count(&total).Error; err != nil {
+		return nil, utils.WrapError(err, "count texts fail")
+	}
+	if total == 0 {
+		return nil, ErrNoText
+	}
+
+	configJSON, err := json.Marshal(jobConfig{
+		FileIDList: config.FileIDList,
+		TextIDList: config.TextIDList,
+		Matcher:    config.Matcher,
+	})
+	if err != nil {
+		return nil, utils.WrapError(err, "json marshal label config fail")
+	}
+
+	job := metadata.LabelJob{
+		BuildID:    config.BuildID,
+		Name:       config.Name,
+		Desc:       config.Desc,
+		ConfigJSON: string(configJSON),
+		Status:     metadata.LabelJobStatusWaiting,
+	}
+	if err := db.Create(&job).Error; err != nil {
+		return nil, utils.WrapError(err, "insert label job fail")
+	}
+
+	return &jobRunner{
+		setting: setting,
+		jobID:   job.ID,
+		config:  config,
+		total:   int(total),
+	}, nil
+}
+
+/*
+start 记录任务的取消函数，并在后台开始标注
+*/
+func (r *jobRunner) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	runningJobs.Lock()
+	runningJobs.cancels[r.jobID] = cancel
+	runningJobs.Unlock()
+
+	go func() {
+		defer func() {
+			runningJobs.Lock()
+			delete(runningJobs.cancels, r.jobID)
+			runningJobs.Unlock()
+
+			cancel()
+		}()
+
+		r.run(ctx)
+	}()
+}
+
+/*
+cancel 取消一个正在进行的任务，任务会在当前一批句子标注完后停止，并删除已经写入的标注
+*/
+func cancel(jobID uint) error {
+	runningJobs.Lock()
+	defer runningJobs.Unlock()
+
+	cancelFunc, ok := runningJobs.cancels[jobID]
+	if !ok {
+		return utils.WrapErrorf(ErrJobNotRunning, "label job [%d] not found in running jobs", jobID)
+	}
+
+	cancelFunc()
+	return nil
+}
+
+func getJob(setting *LabelSetting, jobID uint) (*JobInfo, error) {
+	var job metadata.LabelJob
+	if err := setting.GetMetadataDatabase().Take(&job, jobID).Error; err != nil {
+		return nil, utils.WrapErrorf(err, "select label job [%d] fail", jobID)
+	}
+
+	return &JobInfo{
+		ID:          job.ID,
+		BuildID:     job.BuildID,
+		Name:        job.Name,
+		Desc:        job.Desc,
+		Status:      job.Status,
+		Progress:    job.Progress,
+		ExtractorID: job.ExtractorID,
+		Result: LabelResult{
+			TextCount:     job.TextCount,
+			LabeledCount:  job.LabeledCount,
+			EntityCount:   job.EntityCount,
+			RelationCount: job.RelationCount,
+		},
+		ErrMsg:     job.ErrMsg,
+		CreateTime: job.CreatedAt,
+		UpdateTime: job.UpdatedAt,
+	}, nil
+}
+
+/*
+failInterruptedJobs 与任务失败时相同，删除服务重启前未完成的任务已经写入的抽取器及标注，并将任务标记为失败
+*/
+func failInterruptedJobs(setting *LabelSetting) error {
+	db := setting.GetMetadataDatabase()
+
+	var jobs []metadata.LabelJob
+	err := db.Select("id", "extractor_id").
+		Where("status in ?", []uint{metadata.LabelJobStatusWaiting, metadata.LabelJobStatusRunning}).
+		Find(&jobs).Error
+	if err != nil {
+		return utils.WrapError(err, "select interrupted label jobs fail")
+	}
+
+	for _, job := range jobs {
+		if job.ExtractorID != nil {
+			if err := deleteLabels(db, *job.ExtractorID); err != nil {
+				return utils.WrapErrorf(err, "delete distant extractor [%d] of label job [%d] fail", *job.ExtractorID, job.ID)
+			}
+		}
+
+		err := db.Model(&metadata.LabelJob{}).
+			Where("id = ?", job.ID).
+			Updates(map[string]interface{}{
+				"status":       metadata.LabelJobStatusFail,
+				"extractor_id": nil,
+				"err_msg":      errInterrupted.Error(),
+			}).Error
+		if err != nil {
+			return utils.WrapErrorf(err, "update interrupted label job [%d] fail", job.ID)
+		}
+	}
+
+	return nil
+}
+
+/*
+deleteLabels 在一个事务中删除远程监督抽取器及其写入的 Entity 与 Relation
+*/
+func deleteLabels(db *gorm.DB, extractorID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("extractor_id = ?", extractorID).Delete(&metadata.Relation{}).Error; err != nil {
+			return utils.WrapError(err, "delete relations fail")
+		}
+		if err := tx.Where("extractor_id = ?", extractorID).Delete(&metadata.Entity{}).Error; err != nil {
+			return utils.WrapError(err, "delete entities fail")
+		}
+		if err := tx.Delete(&metadata.Extractor{}, extractorID).Error; err != nil {
+			return utils.WrapError(err, "delete extractor fail")
+		}
+		return nil
+	})
+}
+
+type jobRunner struct {
+	setting *LabelSetting
+	jobID   uint
+	config  *LabelConfig
+	total   int // 提交时要标注的句子数，用于计算进度
+
+	// outputs
+	extractorID *uint
+	result      LabelResult
+
+	afterBatch func() // 可以为空，每批句子写入后调用，测试中用于在批之间取消任务
+}
+
+func (r *jobRunner) run(ctx context.Context) {
+	logger := r.setting.Logger
+
+	r.update(map[string]interface{}{
+		"status": metadata.LabelJobStatusRunning,
+	})
+
+	err := r.produce(ctx)
+	if err != nil && ctx.Err() != nil {
+		logger.Infof("label job [%d] cancelled: %s", r.jobID, err.Error())
+		r.cleanup()
+		r.update(map[string]interface{}{
+			"status":       metadata.LabelJobStatusCancelled,
+			"extractor_id": nil,
+			"err_msg":      ctx.Err().Error(),
+		})
+		return
+	}
+
+	if err != nil {
+		logger.WithError(err).Errorf("label job [%d] fail: %s", r.jobID, err.Error())
+		r.cleanup()
+		r.update(map[string]interface{}{
+			"status":       metadata.LabelJobStatusFail,
+			"extractor_id": nil,
+			"err_msg":      err.Error(),
+		})
+		return
+	}
+
+	r.update(map[string]interface{}{
+		"status":   metadata.LabelJobStatusDone,
+		"progress": 100,
+	})
+	logger.Infof("label job [%d] finished: distant extractor [%d] labeled %d of %d texts with %d entities and %d relations",
+		r.jobID, *r.extractorID, r.result.LabeledCount, r.result.TextCount, r.result.EntityCount, r.result.RelationCount)
+}
+
+/*
+produce 创建类型为 Distant 的抽取器，再按 ID 分批标注句子，每批在一个事务中写入并更新任务的进度
+*/
+func (r *jobRunner) produce(ctx context.Context) error {
+	db := r.setting.GetMetadataDatabase().WithContext(ctx)
+
+	t, release, err := tagger.Acquire(ctx, r.config.BuildID, r.config.Matcher)
+	if err != nil {
+		return utils.WrapErrorf(err, "acquire tagger of build [%d] fail", r.config.BuildID)
+	}
+	defer release()
+
+	extractor := metadata.Extractor{
+		Name: r.config.Name,
+		Desc: r.config.Desc,
+		Type: metadata.ExtractorTypeDistant,
+	}
+	if err := db.Create(&extractor).Error; err != nil {
+		return utils.WrapError(err, "create extractor fail")
+	}
+	r.extractorID = utils.UintToPtr(extractor.ID)
+	r.update(map[string]interface{}{
+		"extractor_id": extractor.ID,
+	})
+
+	var lastID uint = 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return utils.WrapError(err, "label cancelled")
+		}
+
+		var texts []metadata.Text
+		err := db.Select("id", "content").
+			Scopes(textScope(r.config)).
+			Where("id > ?", lastID).
+			Order("id").
+			Limit(labelBatchSize).
+			Find(&texts).Error
+		if err != nil {
+			return utils.WrapErrorf(err, "select texts after id=[%d] fail", lastID)
+		}
+
+		if len(texts) == 0 {
+			break
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			return labelBatch(tx, t, r.config.BuildID, extractor.ID, texts, &r.result)
+		})
+		if err != nil {
+			return utils.WrapErrorf(err, "label texts after id=[%d] fail", lastID)
+		}
+		r.setProgress()
+		if r.afterBatch != nil {
+			r.afterBatch()
+		}
+
+		if len(texts) < labelBatchSize {
+			break
+		}
+		lastID = texts[len(texts)-1].ID
+	}
+
+	return nil
+}
+
+/*
+cleanup 删除未完成的任务已经写入的抽取器及标注。此时任务的 ctx 可能已被取消，因此使用新的 ctx。
+*/
+func (r *jobRunner) cleanup() {
+	if r.extractorID == nil {
+		return
+	}
+
+	if err := deleteLabels(r.setting.GetMetadataDatabase(), *r.extractorID); err != nil {
+		r.setting.Logger.WithError(err).Errorf("delete distant extractor [%d] of label job [%d] fail: %s", *r.extractorID, r.jobID, err.Error())
+	}
+}
+
+func (r *jobRunner) update(values map[string]interface{}) {
+	err := r.setting.GetMetadataDatabase().Model(&metadata.LabelJob{}).
+		Where("id = ?", r.jobID).
+		Updates(values).Error
+	if err != nil {
+		r.setting.Logger.WithError(err).Errorf("update label job [%d] with %#v fail: %s", r.jobID, values, err.Error())
+	}
+}
+
+/*
+setProgress 在每批句子写入后更新任务的进度和计数。标注期间新增的句子可能使已标注的句子数超过提交时的句子数，进度最多为 99，完成时才为 100。
+*/
+func (r *jobRunner) setProgress() {
+	progress := uint(99)
+	if r.result.TextCount < r.total {
+		progress = uint(r.result.TextCount * 100 / r.total)
+	}
+
+	r.update(map[string]interface{}{
+		"progress":       progress,
+		"text_count":     r.result.TextCount,
+		"labeled_count":  r.result.LabeledCount,
+		"entity_count":   r.result.EntityCount,
+		"relation_count": r.result.RelationCount,
+	})
+}
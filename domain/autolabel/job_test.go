@@ -0,0 +1,142 @@
+package autolabel
+
+import (
+	"autograph-backend-controller/domain/graph"
+	"autograph-backend-controller/domain/tagger"
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/repository/metadata"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func newTestSetting(t *testing.T) *LabelSetting {
+	logging.SetDefaultConfig(logging.GenerateTestConfig(t))
+
+	database, err := metadata.CreateDatabase(metadata.GenerateTestConfig())
+	require.Nil(t, err)
+
+	setting := LabelSetting{
+		GetMetadataDatabase: func() *gorm.DB {
+			return database
+		},
+		Logger: logging.NewLogger(),
+	}
+	graph.Init(&graph.KGSetting{
+		GetMetadataDatabase: setting.GetMetadataDatabase,
+		Logger:              logging.NewLogger(),
+	})
+	tagger.Init(&tagger.TagSetting{
+		GetMetadataDatabase: setting.GetMetadataDatabase,
+		Logger:              logging.NewLogger(),
+	})
+
+	return &setting
+}
+
+/*
+assertLabelsDeleted 检查远程监督抽取器及其写入的 Entity 与 Relation 都已被删除
+*/
+func assertLabelsDeleted(t *testing.T, database *gorm.DB, extractorID uint) {
+	assert.ErrorIs(t, database.Take(&metadata.Extractor{}, extractorID).Error, gorm.ErrRecordNotFound)
+
+	var entities, relations int64
+	require.Nil(t, database.Model(&metadata.Entity{}).Where("extractor_id = ?", extractorID).Count(&entities).Error)
+	require.Nil(t, database.Model(&metadata.Relation{}).Where("extractor_id = ?", extractorID).Count(&relations).Error)
+	assert.Zero(t, entities)
+	assert.Zero(t, relations)
+}
+
+func TestLabelJobCancel(t *testing.T) {
+	setting := newTestSetting(t)
+	database := setting.GetMetadataDatabase()
+	ctx := context.TODO()
+
+	// 知识库：数组 --包含--> 指针
+	extractor := metadata.Extractor{Name: "TestLabelJobCancel", Type: metadata.ExtractorTypeModel}
+	require.Nil(t, database.Create(&extractor).Error)
+	entities := []metadata.Entity{
+		{Name: "数组", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID},
+		{Name: "指针", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID},
+	}
+	require.Nil(t, database.Create(&entities).Error)
+	require.Nil(t, database.Create(&metadata.Relation{
+		Name: "包含", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID, HeadID: entities[0].ID, TailID: entities[1].ID,
+	}).Error)
+
+	build, err := graph.BuildKG(ctx, &graph.KGBuildConfig{
+		ExtractorIDList: []uint{extractor.ID},
+		Desc:            t.Name(),
+	})
+	require.Nil(t, err)
+
+	// 两批句子，第一批写入后取消
+	file := metadata.File{Type: "txt", Name: t.Name()}
+	require.Nil(t, database.Create(&file).Error)
+	texts := make([]metadata.Text, 0, labelBatchSize+1)
+	for i := 0; i < labelBatchSize+1; i++ {
+		texts = append(texts, metadata.Text{Content: "指向数组首元素的指针", FileID: &file.ID})
+	}
+	require.Nil(t, database.CreateInBatches(&texts, labelBatchSize).Error)
+
+	runner, err := newJobRunner(setting, ctx, &LabelConfig{
+		BuildID:    build.BuildID,
+		Name:       t.Name(),
+		FileIDList: []uint{file.ID},
+		Matcher:    tagger.MatcherOption{Mode: tagger.MatchModeLongest},
+	})
+	require.Nil(t, err)
+
+	var labeled LabelResult
+	runner.afterBatch = func() {
+		labeled = runner.result
+		assert.Nil(t, cancel(runner.jobID))
+	}
+	runner.start()
+
+	var job *JobInfo
+	require.Eventually(t, func() bool {
+		job, err = getJob(setting, runner.jobID)
+		return err == nil && job.Status == metadata.LabelJobStatusCancelled
+	}, 10*time.Second, 50*time.Millisecond)
+
+	// 取消前第一批已经写入，取消后被删除
+	assert.Equal(t, labelBatchSize, labeled.LabeledCount)
+	assert.NotZero(t, labeled.RelationCount)
+	assert.Nil(t, job.ExtractorID)
+	require.NotNil(t, runner.extractorID)
+	assertLabelsDeleted(t, database, *runner.extractorID)
+
+	assert.ErrorIs(t, cancel(runner.jobID), ErrJobNotRunning)
+}
+
+func TestFailInterruptedJobs(t *testing.T) {
+	setting := newTestSetting(t)
+	database := setting.GetMetadataDatabase()
+
+	// 服务重启前写入了部分标注的任务
+	extractor := metadata.Extractor{Name: "TestFailInterruptedJobs", Type: metadata.ExtractorTypeDistant}
+	require.Nil(t, database.Create(&extractor).Error)
+	entities := []metadata.Entity{
+		{Name: "数组", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID},
+		{Name: "指针", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID},
+	}
+	require.Nil(t, database.Create(&entities).Error)
+	require.Nil(t, database.Create(&metadata.Relation{
+		Name: "包含", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID, HeadID: entities[0].ID, TailID: entities[1].ID,
+	}).Error)
+
+	job := metadata.LabelJob{Name: t.Name(), Status: metadata.LabelJobStatusRunning, ExtractorID: &extractor.ID}
+	require.Nil(t, database.Create(&job).Error)
+
+	require.Nil(t, failInterruptedJobs(setting))
+
+	info, err := getJob(setting, job.ID)
+	require.Nil(t, err)
+	assert.Equal(t, metadata.LabelJobStatusFail, info.Status)
+	assert.Nil(t, info.ExtractorID)
+	assertLabelsDeleted(t, database, extractor.ID)
+}
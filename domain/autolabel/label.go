@@ -0,0 +1,275 @@
+package autolabel
+
+import (
+	"autograph-backend-controller/domain/tagger"
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/utils"
+	"errors"
+	"gorm.io/gorm"
+	"sort"
+)
+
+// labelBatchSize 每批标注的句子数
+const labelBatchSize = 256
+
+var ErrNoText = errors.New("no text to label")
+
+/*
+LabelConfig 自动标注的配置。
+
+	BuildID 使用该构建的节点和边作为远程监督的知识库；
+	Name、Desc 新建的远程监督抽取器的名称和描述；
+	FileIDList、TextIDList 要标注的文件和句子，标注文件中的所有句子，两者至少有一个非空；
+	Matcher Tagger 识别实体的方式；
+*/
+type LabelConfig struct {
+	BuildID    uint
+	Name       string
+	Desc       string
+	FileIDList []uint
+	TextIDList []uint
+	Matcher    tagger.MatcherOption
+}
+
+/*
+LabelResult 已标注的句子数、标注出关系的句子数及写入的 Entity 与 Relation 数
+*/
+type LabelResult struct {
+	TextCount     int
+	LabeledCount  int
+	EntityCount   int
+	RelationCount int
+}
+
+/*
+producer 在句子中找出知识库中已有的三元组，由 tagger.Tagger 实现
+*/
+type producer interface {
+	Produce(text string) []tagger.SPOTriple
+}
+
+/*
+labeledEntity 标注出的实体，Begin、End 以字符为单位，左闭右开
+*/
+type labeledEntity struct {
+	Name  string
+	Begin int
+	End   int
+}
+
+/*
+labeledRelation 标注出的关系，Head、Tail 为实体在 labeledEntity 列表中的下标
+*/
+type labeledRelation struct {
+	Name string
+	Head int
+	Tail int
+}
+
+func textScope(config *LabelConfig) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch {
+		case len(config.FileIDList) != 0 && len(config.TextIDList) != 0:
+			return db.Where("file_id in ? or id in ?", config.FileIDList, config.TextIDList)
+		case len(config.FileIDList) != 0:
+			return db.Where("file_id in ?", config.FileIDList)
+		default:
+			return db.Where("id in ?", config.TextIDList)
+		}
+	}
+}
+
+/*
+labelBatch 标注一批句子并写入标注结果。实体的类别取构建中同名节点的类别；没有标注出任何关系的句子不写入。
+*/
+func labelBatch(tx *gorm.DB, p producer, buildID, extractorID uint, texts []metadata.Text, result *LabelResult) error {
+	type labeledText struct {
+		textID    uint
+		entities  []labeledEntity
+		relations []labeledRelation
+	}
+
+	labeled := make([]labeledText, 0, len(texts))
+	names := make(map[string]struct{})
+	for _, text := range texts {
+		entities, relations := labelText(text.Content, p.Produce(text.Content))
+		if len(relations) == 0 {
+			continue
+		}
+
+		labeled = append(labeled, labeledText{textID: text.ID, entities: entities, relations: relations})
+		for _, entity := range entities {
+			names[entity.Name] = struct{}{}
+		}
+	}
+	result.TextCount += len(texts)
+
+	if len(labeled) == 0 {
+		return nil
+	}
+
+	categories, err := nodeCategories(tx, buildID, names)
+	if err != nil {
+		return utils.WrapError(err, "load categories fail")
+	}
+
+	entities := make([]metadata.Entity, 0)
+	for _, text := range labeled {
+		for _, entity := range text.entities {
+			entities = append(entities, metadata.Entity{
+				Name:        entity.Name,
+				Type:        metadata.EntityTypeAdd,
+				Category:    categories[entity.Name],
+				ExtractorID: extractorID,
+				TextID:      utils.UintToPtr(text.textID),
+				Begin:       utils.IntToPtr(entity.Begin),
+				End:         utils.IntToPtr(entity.End),
+			})
+		}
+	}
+
+	if err := tx.CreateInBatches(&entities, labelBatchSize).Error; err != nil {
+		return utils.WrapError(err, "create entities fail")
+	}
+
+	relations := make([]metadata.Relation, 0)
+	offset := 0 // 当前句子的第一个实体在 entities 中的下标
+	for _, text := range labeled {
+		for _, relation := range text.relations {
+			head := &entities[offset+relation.Head]
+			tail := &entities[offset+relation.Tail]
+
+			begin, end := *head.Begin, *head.End
+			if *tail.Begin < begin {
+				begin = *tail.Begin
+			}
+			if *tail.End > end {
+				end = *tail.End
+			}
+
+			relations = append(relations, metadata.Relation{
+				Name:        relation.Name,
+				Type:        metadata.EntityTypeAdd,
+				ExtractorID: extractorID,
+				TextID:      utils.UintToPtr(text.textID),
+				HeadID:      head.ID,
+				TailID:      tail.ID,
+				Begin:       utils.IntToPtr(begin),
+				End:         utils.IntToPtr(end),
+			})
+		}
+		offset += len(text.entities)
+	}
+
+	if err := tx.CreateInBatches(&relations, labelBatchSize).Error; err != nil {
+		return utils.WrapError(err, "create relations fail")
+	}
+
+	result.LabeledCount += len(labeled)
+	result.EntityCount += len(entities)
+	result.RelationCount += len(relations)
+
+	return nil
+}
+
+/*
+labelText 将 Tagger 输出的三元组转换为句子中的实体和关系，Tagger 输出的位置以字节为单位，转换为以字符为单位。
+实体名取 Tagger 给出的知识库中的实体名，忽略大小写或全角半角识别时可能与句子中的原文不同。
+同一位置的同名实体只保留一个，重复的关系只保留一条，实体按位置排序。
+*/
+func labelText(content string, triples []tagger.SPOTriple) ([]labeledEntity, []labeledRelation) {
+	index := make(map[tagger.EntityInfo]int) // 字节位置及实体名 -> 在 entities 中的下标
+	entities := make([]labeledEntity, 0)
+	addEntity := func(entity tagger.EntityInfo) {
+		if _, ok := index[entity]; ok {
+			return
+		}
+
+		index[entity] = len(entities)
+		runes := tagger.RuneRange(content, entity.Range)
+		entities = append(entities, labeledEntity{
+			Name:  entity.Name,
+			Begin: runes.Begin,
+			End:   runes.End,
+		})
+	}
+
+	type entityRelation struct {
+		name       string
+		head, tail tagger.EntityInfo
+	}
+	seen := make(map[entityRelation]struct{})
+	ranged := make([]entityRelation, 0, len(triples))
+	for _, triple := range triples {
+		if !validRange(content, triple.HeadEntity.Range) || !validRange(content, triple.TailEntity.Range) {
+			continue
+		}
+
+		relation := entityRelation{name: triple.Relation, head: triple.HeadEntity, tail: triple.TailEntity}
+		if _, ok := seen[relation]; ok {
+			continue
+		}
+		seen[relation] = struct{}{}
+
+		addEntity(triple.HeadEntity)
+		addEntity(triple.TailEntity)
+		ranged = append(ranged, relation)
+	}
+
+	// 按位置排序实体，并更新下标
+	order := make([]int, len(entities))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return entities[order[a]].Begin < entities[order[b]].Begin ||
+			entities[order[a]].Begin == entities[order[b]].Begin && entities[order[a]].End < entities[order[b]].End
+	})
+
+	sorted := make([]labeledEntity, len(entities))
+	position := make([]int, len(entities)) // 原下标 -> 排序后的下标
+	for i, j := range order {
+		sorted[i] = entities[j]
+		position[j] = i
+	}
+
+	relations := make([]labeledRelation, 0, len(ranged))
+	for _, relation := range ranged {
+		relations = append(relations, labeledRelation{
+			Name: relation.name,
+			Head: position[index[relation.head]],
+			Tail: position[index[relation.tail]],
+		})
+	}
+
+	return sorted, relations
+}
+
+func validRange(content string, r tagger.Range) bool {
+	return r.Begin >= 0 && r.Begin < r.End && r.End <= len(content)
+}
+
+/*
+nodeCategories 查询构建中节点的类别，返回实体名 -> 类别
+*/
+func nodeCategories(tx *gorm.DB, buildID uint, names map[string]struct{}) (map[string]string, error) {
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+
+	var nodes []metadata.Node
+	err := tx.Select("name", "category").
+		Where("build_id = ? and name in ?", buildID, list).
+		Find(&nodes).Error
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "select nodes of build [%d] fail", buildID)
+	}
+
+	ret := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		ret[node.Name] = node.Category
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,67 @@
+package autolabel
+
+import (
+	"autograph-backend-controller/domain/tagger"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestLabelText(t *testing.T) {
+	content := "指向数组首元素的指针"
+	span := func(name string) tagger.EntityInfo {
+		begin := strings.Index(content, name)
+		if name == "指针" {
+			begin = strings.LastIndex(content, name)
+		}
+		return tagger.EntityInfo{Range: tagger.Range{Begin: begin, End: begin + len(name)}, Name: name}
+	}
+
+	triples := []tagger.SPOTriple{
+		{HeadEntity: span("指针"), TailEntity: span("数组"), Relation: "相关"},
+		{HeadEntity: span("数组"), TailEntity: span("指针"), Relation: "包含"},
+		{HeadEntity: span("指针"), TailEntity: span("数组"), Relation: "相关"},                                                 // 重复
+		{HeadEntity: tagger.EntityInfo{Range: tagger.Range{Begin: 0, End: 100}}, TailEntity: span("数组"), Relation: "非法"}, // 越界
+	}
+
+	entities, relations := labelText(content, triples)
+
+	assert.Equal(t, []labeledEntity{
+		{Name: "数组", Begin: 2, End: 4},
+		{Name: "指针", Begin: 8, End: 10},
+	}, entities)
+	assert.Equal(t, []labeledRelation{
+		{Name: "相关", Head: 1, Tail: 0},
+		{Name: "包含", Head: 0, Tail: 1},
+	}, relations)
+}
+
+/*
+TestLabelTextFolded 忽略大小写和全角半角识别时，Tagger 给出的实体名是知识库中的实体名，与句子中的原文不同；
+知识库中只有大小写不同的两个实体会在同一位置被识别出来。
+*/
+func TestLabelTextFolded(t *testing.T) {
+	content := "用Ｐｙｔｈｏｎ调用c接口"
+	span := func(surface, name string) tagger.EntityInfo {
+		begin := strings.Index(content, surface)
+		return tagger.EntityInfo{Range: tagger.Range{Begin: begin, End: begin + len(surface)}, Name: name}
+	}
+
+	triples := []tagger.SPOTriple{
+		{HeadEntity: span("Ｐｙｔｈｏｎ", "python"), TailEntity: span("c", "C"), Relation: "调用"},
+		{HeadEntity: span("Ｐｙｔｈｏｎ", "Python"), TailEntity: span("c", "C"), Relation: "调用"},
+		{HeadEntity: span("Ｐｙｔｈｏｎ", "python"), TailEntity: span("c", "C"), Relation: "调用"}, // 重复
+	}
+
+	entities, relations := labelText(content, triples)
+
+	assert.Equal(t, []labeledEntity{
+		{Name: "python", Begin: 1, End: 7},
+		{Name: "Python", Begin: 1, End: 7},
+		{Name: "C", Begin: 9, End: 10},
+	}, entities)
+	assert.Equal(t, []labeledRelation{
+		{Name: "调用", Head: 0, Tail: 2},
+		{Name: "调用", Head: 1, Tail: 2},
+	}, relations)
+}
@@ -0,0 +1,38 @@
+package buildjob
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type JobSetting struct {
+	GetMetadataDatabase func() *gorm.DB
+	Logger              *logrus.Logger
+}
+
+var globalSetting JobSetting
+
+func Init(setting *JobSetting) {
+	globalSetting = *setting
+
+	if err := failInterruptedJobs(&globalSetting); err != nil {
+		globalSetting.Logger.WithError(err).Errorf("fail interrupted build jobs error=\n%v", err)
+	}
+}
+
+func Submit(config *JobConfig) (uint, error) {
+	return submit(&globalSetting, config)
+}
+
+func Cancel(jobID uint) error {
+	return cancel(jobID)
+}
+
+func GetJob(jobID uint) (*JobInfo, error) {
+	return getJob(&globalSetting, jobID)
+}
+
+func DeleteVersion(ctx context.Context, buildID uint) error {
+	return deleteVersion(&globalSetting, ctx, buildID)
+}
@@ -0,0 +1,340 @@
+package buildjob
+
+import (
+	"autograph-backend-controller/domain/graph"
+	"autograph-backend-controller/repository/filesave"
+	"autograph-backend-controller/repository/graphstore"
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrJobNotRunning = errors.New("build job is not running")
+
+	errInterrupted = errors.New("build job interrupted by server restart")
+)
+
+/*
+runningJobs 记录正在进行的任务的取消函数，用于取消任务
+*/
+var runningJobs = struct {
+	sync.Mutex
+	cancels map[uint]context.CancelFunc // BuildJob.ID -> cancel
+}{
+	cancels: make(map[uint]context.CancelFunc),
+}
+
+type JobConfig struct {
+	ExtractorIDList  []uint
+	Desc             string
+	BaseBuildID      uint
+	RelationResolver graph.RelationResolver
+	MinConfidence    float64
+}
+
+type JobInfo struct {
+	ID         uint
+	Desc       string
+	Status     uint
+	Stage      uint
+	Progress   uint
+	BuildID    *uint
+	ErrMsg     string
+	CreateTime time.Time
+	UpdateTime time.Time
+}
+
+/*
+submit 创建一条构建任务记录，并在后台开始构建，返回 BuildJob.ID
+*/
+func submit(setting *JobSetting, config *JobConfig) (uint, error) {
+	extractorListJSON, err := json.Marshal(config.ExtractorIDList)
+	if err != nil {
+		return 0, utils.WrapError(err, "json marshal extractor list fail")
+	}
+
+	job := metadata.BuildJob{
+		Desc:              config.Desc,
+		ExtractorListJSON: string(extractorListJSON),
+		Status:            metadata.BuildJobStatusWaiting,
+		Stage:             metadata.BuildJobStageSnapshot,
+	}
+	if err := setting.GetMetadataDatabase().Create(&job).Error; err != nil {
+		return 0, utils.WrapError(err, "insert build job fail")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	runningJobs.Lock()
+	runningJobs.cancels[job.ID] = cancel
+	runningJobs.Unlock()
+
+	runner := jobRunner{
+		setting:      setting,
+		jobID:        job.ID,
+		config:       config,
+		lastStage:    job.Stage,
+		lastProgress: job.Progress,
+	}
+	go func() {
+		defer func() {
+			runningJobs.Lock()
+			delete(runningJobs.cancels, job.ID)
+			runningJobs.Unlock()
+
+			cancel()
+		}()
+
+		runner.run(ctx)
+	}()
+
+	return job.ID, nil
+}
+
+/*
+cancel 取消一个正在进行的任务，任务会在下一个检查点停止，并清理已经产生的数据
+*/
+func cancel(jobID uint) error {
+	runningJobs.Lock()
+	defer runningJobs.Unlock()
+
+	cancelFunc, ok := runningJobs.cancels[jobID]
+	if !ok {
+		return utils.WrapErrorf(ErrJobNotRunning, "build job [%d] not found in running jobs", jobID)
+	}
+
+	cancelFunc()
+	return nil
+}
+
+func getJob(setting *JobSetting, jobID uint) (*JobInfo, error) {
+	var job metadata.BuildJob
+	if err := setting.GetMetadataDatabase().Take(&job, jobID).Error; err != nil {
+		return nil, utils.WrapErrorf(err, "select build job [%d] fail", jobID)
+	}
+
+	return &JobInfo{
+		ID:         job.ID,
+		Desc:       job.Desc,
+		Status:     job.Status,
+		Stage:      job.Stage,
+		Progress:   job.Progress,
+		BuildID:    job.BuildID,
+		ErrMsg:     job.ErrMsg,
+		CreateTime: job.CreatedAt,
+		UpdateTime: job.UpdatedAt,
+	}, nil
+}
+
+/*
+failInterruptedJobs 将服务重启前未完成的任务标记为失败
+*/
+func failInterruptedJobs(setting *JobSetting) error {
+	err := setting.GetMetadataDatabase().Model(&metadata.BuildJob{}).
+		Where("status in ?", []uint{metadata.BuildJobStatusWaiting, metadata.BuildJobStatusRunning}).
+		Updates(map[string]interface{}{
+			"status":  metadata.BuildJobStatusFail,
+			"err_msg": errInterrupted.Error(),
+		}).Error
+	if err != nil {
+		return utils.WrapError(err, "update interrupted build jobs fail")
+	}
+
+	return nil
+}
+
+type jobRunner struct {
+	setting *JobSetting
+	jobID   uint
+	config  *JobConfig
+
+	// status
+	lastStage    uint
+	lastProgress uint
+
+	// outputs
+	buildID         *uint
+	entityFileURL   string
+	relationFileURL string
+	neo4jTouched    bool
+}
+
+func (r *jobRunner) run(ctx context.Context) {
+	logger := r.setting.Logger
+
+	r.update(map[string]interface{}{
+		"status": metadata.BuildJobStatusRunning,
+	})
+
+	buildID, err := r.produce(ctx)
+	if err != nil && ctx.Err() != nil {
+		logger.Infof("build job [%d] cancelled: %s", r.jobID, err.Error())
+		r.cleanup()
+		r.update(map[string]interface{}{
+			"status":   metadata.BuildJobStatusCancelled,
+			"build_id": nil,
+			"err_msg":  ctx.Err().Error(),
+		})
+		return
+	}
+
+	if err != nil {
+		logger.WithError(err).Errorf("build job [%d] fail: %s", r.jobID, err.Error())
+		r.update(map[string]interface{}{
+			"status":  metadata.BuildJobStatusFail,
+			"err_msg": err.Error(),
+		})
+		return
+	}
+
+	r.update(map[string]interface{}{
+		"status": metadata.BuildJobStatusDone,
+	})
+	logger.Infof("build job [%d] finished with buildID=%d", r.jobID, buildID)
+}
+
+/*
+cleanup 清理被取消的任务已经产生的数据。此时任务的 ctx 已被取消，因此使用新的 ctx。
+构建过程中的 MySQL 事务会因 ctx 取消而回滚，这里只需处理事务提交后产生的数据。
+*/
+func (r *jobRunner) cleanup() {
+	logger := r.setting.Logger
+	ctx := context.Background()
+
+	if r.buildID == nil {
+		return
+	}
+
+	if r.neo4jTouched {
+		if err := graphstore.Default().DeleteVersion(ctx, *r.buildID); err != nil {
+			logger.WithError(err).Errorf("delete neo4j version [%d] of build job [%d] fail: %s", *r.buildID, r.jobID, err.Error())
+		}
+	}
+
+	for _, url := range []string{r.entityFileURL, r.relationFileURL} {
+		if len(url) == 0 {
+			continue
+		}
+
+		if err := filesave.DeleteFile(url); err != nil {
+			logger.WithError(err).Errorf("delete csv [%s] of build job [%d] fail: %s", url, r.jobID, err.Error())
+		}
+	}
+
+	if err := graph.DropKG(ctx, *r.buildID); err != nil {
+		logger.WithError(err).Errorf("drop build [%d] of build job [%d] fail: %s", *r.buildID, r.jobID, err.Error())
+	}
+}
+
+func (r *jobRunner) update(values map[string]interface{}) {
+	err := r.setting.GetMetadataDatabase().Model(&metadata.BuildJob{}).
+		Where("id = ?", r.jobID).
+		Updates(values).Error
+	if err != nil {
+		r.setting.Logger.WithError(err).Errorf("update build job [%d] with %#v fail: %s", r.jobID, values, err.Error())
+	}
+}
+
+/*
+setProgress 更新任务的阶段和进度，仅在阶段或百分比发生变化时写入数据库
+*/
+func (r *jobRunner) setProgress(stage uint, finished, total int) {
+	progress := uint(100)
+	if total > 0 {
+		progress = uint(finished * 100 / total)
+	}
+
+	if stage == r.lastStage && progress == r.lastProgress {
+		return
+	}
+
+	r.lastStage = stage
+	r.lastProgress = progress
+	r.update(map[string]interface{}{
+		"stage":    stage,
+		"progress": progress,
+	})
+}
+
+func (r *jobRunner) reportKG(stage graph.KGBuildStage, finished, total int) {
+	switch stage {
+	case graph.KGBuildStageSnapshot:
+		r.setProgress(metadata.BuildJobStageSnapshot, finished, total)
+	case graph.KGBuildStageCollectEntities:
+		r.setProgress(metadata.BuildJobStageCollectEntities, finished, total)
+	case graph.KGBuildStageCreateNodes:
+		r.setProgress(metadata.BuildJobStageCreateNodes, finished, total)
+	}
+}
+
+func (r *jobRunner) produce(ctx context.Context) (uint, error) {
+	buildInfo, err := graph.BuildKG(ctx, &graph.KGBuildConfig{
+		ExtractorIDList:  r.config.ExtractorIDList,
+		Desc:             r.config.Desc,
+		BaseBuildID:      r.config.BaseBuildID,
+		RelationResolver: r.config.RelationResolver,
+		MinConfidence:    r.config.MinConfidence,
+		Progress:         r.reportKG,
+	})
+	if err != nil {
+		return 0, utils.WrapErrorf(err, "build kg with extractors=%#v, desc=%#v fail", r.config.ExtractorIDList, r.config.Desc)
+	}
+
+	r.buildID = utils.UintToPtr(buildInfo.BuildID)
+	r.update(map[string]interface{}{
+		"build_id": buildInfo.BuildID,
+	})
+
+	// 导出CSV，CSV 只作为构建产物保存在 filesave 中，导入 Neo4j 时直接读取 Node
+	if err := ctx.Err(); err != nil {
+		return 0, utils.WrapError(err, "build job cancelled before exporting csv")
+	}
+
+	r.setProgress(metadata.BuildJobStageExportCSV, 0, 3)
+
+	entityData, relationData, err := graph.TransKGToCSV(buildInfo.BuildID)
+	if err != nil {
+		return 0, utils.WrapErrorf(err, "transform kg [%d] to csv fail", buildInfo.BuildID)
+	}
+
+	r.setProgress(metadata.BuildJobStageExportCSV, 1, 3)
+
+	entityFileInfo, err := filesave.SaveFile(entityData)
+	if err != nil {
+		return 0, utils.WrapErrorf(err, "save entity csv [buildID=%d] fail", buildInfo.BuildID)
+	}
+	r.entityFileURL = entityFileInfo.URL
+
+	r.setProgress(metadata.BuildJobStageExportCSV, 2, 3)
+
+	relationFileInfo, err := filesave.SaveFile(relationData)
+	if err != nil {
+		return 0, utils.WrapErrorf(err, "save relation csv [buildID=%d] fail", buildInfo.BuildID)
+	}
+	r.relationFileURL = relationFileInfo.URL
+
+	if err := graph.SetKGFiles(ctx, buildInfo.BuildID, r.entityFileURL, r.relationFileURL); err != nil {
+		return 0, utils.WrapErrorf(err, "record csv urls of build [%d] fail", buildInfo.BuildID)
+	}
+
+	// 导入Neo4j
+	if err := ctx.Err(); err != nil {
+		return 0, utils.WrapError(err, "build job cancelled before loading neo4j")
+	}
+
+	r.setProgress(metadata.BuildJobStageLoadNeo4j, 0, 1)
+
+	r.neo4jTouched = true
+	if err := graphstore.Default().LoadVersion(ctx, buildInfo.BuildID, graph.NewVersionSource(buildInfo.BuildID)); err != nil {
+		return 0, utils.WrapError(err, "load version to neo4j fail")
+	}
+
+	r.setProgress(metadata.BuildJobStageLoadNeo4j, 1, 1)
+
+	return buildInfo.BuildID, nil
+}
@@ -0,0 +1,61 @@
+package buildjob
+
+import (
+	"autograph-backend-controller/domain/graph"
+	"autograph-backend-controller/domain/suggest"
+	"autograph-backend-controller/domain/tagger"
+	"autograph-backend-controller/repository/filesave"
+	"autograph-backend-controller/repository/graphstore"
+	"autograph-backend-controller/utils"
+	"context"
+)
+
+/*
+deleteVersion 删除一个版本在 Neo4j、filesave 和 MySQL 中的全部数据。
+MySQL 中的构建记录最后删除，任意一步失败时构建记录仍然存在，可以再次删除。
+*/
+func deleteVersion(setting *JobSetting, ctx context.Context, buildID uint) error {
+	build, err := graph.CheckKGDeletable(ctx, buildID)
+	if err != nil {
+		return utils.WrapErrorf(err, "check build [%d] fail", buildID)
+	}
+
+	if err := graphstore.Default().DeleteVersion(ctx, buildID); err != nil {
+		return utils.WrapErrorf(err, "delete neo4j version [%d] fail", buildID)
+	}
+
+	entityFileURL, relationFileURL := build.EntityFileURL, build.RelationFileURL
+
+	if len(entityFileURL) != 0 {
+		if err := filesave.DeleteFile(entityFileURL); err != nil {
+			return utils.WrapErrorf(err, "delete entity csv [%s] fail", entityFileURL)
+		}
+
+		entityFileURL = ""
+		if err := graph.SetKGFiles(ctx, buildID, entityFileURL, relationFileURL); err != nil {
+			return utils.WrapError(err, "clear entity csv url fail")
+		}
+	}
+
+	if len(relationFileURL) != 0 {
+		if err := filesave.DeleteFile(relationFileURL); err != nil {
+			return utils.WrapErrorf(err, "delete relation csv [%s] fail", relationFileURL)
+		}
+
+		relationFileURL = ""
+		if err := graph.SetKGFiles(ctx, buildID, entityFileURL, relationFileURL); err != nil {
+			return utils.WrapError(err, "clear relation csv url fail")
+		}
+	}
+
+	if err := graph.DropKG(ctx, buildID); err != nil {
+		return utils.WrapErrorf(err, "drop build [%d] fail", buildID)
+	}
+
+	suggest.Invalidate(buildID)
+	tagger.Invalidate(buildID)
+
+	setting.Logger.Infof("version [%d] deleted", buildID)
+
+	return nil
+}
@@ -0,0 +1,438 @@
+package dataset
+
+import (
+	"autograph-backend-controller/domain/graph"
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/utils"
+	"context"
+	"gorm.io/gorm"
+	"sort"
+)
+
+// textBatchSize 每批导出的句子数
+const textBatchSize = 256
+
+/*
+DatasetConfig 导出训练数据的范围，各个条件同时生效，都为空时导出所有句子。
+
+	ExtractorIDList 只导出这些抽取器的输出；
+	TaskID 只导出该抽取任务的输出；
+	BuildID 只导出该构建所用的抽取器的输出；
+	ExtractorType 只导出该类型的抽取器的输出，如 metadata.ExtractorTypeDistant 为自动标注的句子；
+	ApplyIntervention 为 true 时，人为干预中删除的实体和关系不出现在导出结果中。指定 BuildID 时只使用该构建中的人为干预，否则使用所有的人为干预；
+*/
+type DatasetConfig struct {
+	ExtractorIDList   []uint
+	TaskID            *uint
+	BuildID           *uint
+	ExtractorType     *uint
+	ApplyIntervention bool
+}
+
+/*
+Mention 实体在句子中的一次出现，Begin、End 以字符为单位，左闭右开。
+*/
+type Mention struct {
+	Name        string
+	Category    string
+	Begin       int
+	End         int
+	Confidence  *float64
+	ExtractorID uint
+}
+
+/*
+RelationMention 关系在句子中的一次出现。
+*/
+type RelationMention struct {
+	Name        string
+	Confidence  *float64
+	ExtractorID uint
+	Head        Mention
+	Tail        Mention
+}
+
+/*
+Sentence 一个句子及其中的实体和关系，按位置排序。在句子中找不到位置的实体，以及涉及这些实体的关系不在其中。
+*/
+type Sentence struct {
+	TextID    uint
+	TaskID    *uint
+	Content   string
+	Entities  []Mention
+	Relations []RelationMention
+}
+
+/*
+Writer 将句子以某种训练数据格式写出，对每个句子调用 Sentence，最后调用 End。
+*/
+type Writer interface {
+	ContentType() string
+	Extension() string
+	Sentence(sentence *Sentence) error
+	End() error
+}
+
+type datasetBuilder struct {
+	setting *DatasetSetting
+	config  *DatasetConfig
+	db      *gorm.DB
+
+	extractors       []uint // 为空表示不限制抽取器
+	deletedEntities  map[string]struct{}
+	deletedRelations map[graph.KGEdge]struct{}
+}
+
+/*
+mentionRow 为导出所需的 Entity 信息，或 Relation 及其头尾实体的信息
+*/
+type mentionRow struct {
+	ID             uint
+	TextID         uint
+	TaskID         *uint
+	ExtractorID    uint
+	Name           string
+	Confidence     *float64
+	HeadName       string
+	HeadCategory   string
+	HeadBegin      *int
+	HeadEnd        *int
+	HeadConfidence *float64
+	TailName       string
+	TailCategory   string
+	TailBegin      *int
+	TailEnd        *int
+	TailConfidence *float64
+}
+
+func exportDataset(setting *DatasetSetting, ctx context.Context, config *DatasetConfig, writer Writer) error {
+	b := datasetBuilder{
+		setting:          setting,
+		config:           config,
+		db:               setting.GetMetadataDatabase().WithContext(ctx),
+		deletedEntities:  make(map[string]struct{}),
+		deletedRelations: make(map[graph.KGEdge]struct{}),
+	}
+
+	if err := b.loadExtractors(); err != nil {
+		return utils.WrapError(err, "load extractors fail")
+	}
+
+	if config.ApplyIntervention {
+		if err := b.loadDeletions(); err != nil {
+			return utils.WrapError(err, "load deletions fail")
+		}
+	}
+
+	var lastID uint = 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return utils.WrapError(err, "export dataset cancelled")
+		}
+
+		// 只导出未被删除的句子
+		var textIDs []uint
+		err := b.db.Model(&metadata.Entity{}).
+			Joins("join texts on texts.id = entities.text_id and texts.deleted_at is null").
+			Scopes(b.scope("entities")).
+			Where("entities.text_id > ? and entities.type = ?", lastID, metadata.EntityTypeAdd).
+			Distinct().
+			Order("entities.text_id").
+			Limit(textBatchSize).
+			Pluck("entities.text_id", &textIDs).Error
+		if err != nil {
+			return utils.WrapErrorf(err, "select texts after id=[%d] fail", lastID)
+		}
+
+		if len(textIDs) == 0 {
+			break
+		}
+
+		sentences, err := b.loadSentences(textIDs)
+		if err != nil {
+			return utils.WrapError(err, "load sentences fail")
+		}
+
+		for i := range sentences {
+			if err := writer.Sentence(&sentences[i]); err != nil {
+				return utils.WrapErrorf(err, "write sentence [%d] fail", sentences[i].TextID)
+			}
+		}
+
+		if len(textIDs) < textBatchSize {
+			break
+		}
+		lastID = textIDs[len(textIDs)-1]
+	}
+
+	if err := writer.End(); err != nil {
+		return utils.WrapError(err, "end dataset fail")
+	}
+
+	return nil
+}
+
+/*
+loadExtractors 根据 ExtractorIDList、BuildID 与 ExtractorType 确定导出的抽取器，取各条件的交集，都为空时不限制抽取器。
+*/
+func (b *datasetBuilder) loadExtractors() error {
+	candidates := make([][]uint, 0, 3)
+
+	if len(b.config.ExtractorIDList) != 0 {
+		candidates = append(candidates, b.config.ExtractorIDList)
+	}
+
+	if b.config.BuildID != nil {
+		var buildExtractors []uint
+		err := b.db.Model(&metadata.BuildExtractor{}).
+			Where("build_id = ?", *b.config.BuildID).
+			Pluck("extractor_id", &buildExtractors).Error
+		if err != nil {
+			return utils.WrapErrorf(err, "select extractors of build [%d] fail", *b.config.BuildID)
+		}
+		candidates = append(candidates, buildExtractors)
+	}
+
+	if b.config.ExtractorType != nil {
+		var typed []uint
+		err := b.db.Model(&metadata.Extractor{}).
+			Where("type = ?", *b.config.ExtractorType).
+			Pluck("id", &typed).Error
+		if err != nil {
+			return utils.WrapErrorf(err, "select extractors of type [%d] fail", *b.config.ExtractorType)
+		}
+		candidates = append(candidates, typed)
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	b.extractors = candidates[0]
+	for _, candidate := range candidates[1:] {
+		selected := make(map[uint]struct{}, len(candidate))
+		for _, id := range candidate {
+			selected[id] = struct{}{}
+		}
+
+		intersection := make([]uint, 0)
+		for _, id := range b.extractors {
+			if _, ok := selected[id]; ok {
+				intersection = append(intersection, id)
+			}
+		}
+		b.extractors = intersection
+	}
+
+	// 没有符合条件的抽取器时，使用不存在的 ID 使查询结果为空
+	if len(b.extractors) == 0 {
+		b.extractors = []uint{0}
+	}
+
+	return nil
+}
+
+func (b *datasetBuilder) scope(table string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if b.extractors != nil {
+			db = db.Where(table+".extractor_id in ?", b.extractors)
+		}
+		if b.config.TaskID != nil {
+			db = db.Where(table+".task_id = ?", *b.config.TaskID)
+		}
+		return db
+	}
+}
+
+/*
+loadDeletions 读取人为干预中删除的实体名和关系。
+*/
+func (b *datasetBuilder) loadDeletions() error {
+	query := b.db.Model(&metadata.Extractor{}).Where("type = ?", metadata.ExtractorTypeHumanIntervention)
+	if b.config.BuildID != nil {
+		query = b.db.Model(&metadata.BuildExtractor{}).
+			Where("build_id = ? and type = ?", *b.config.BuildID, metadata.ExtractorTypeHumanIntervention)
+	}
+
+	column := "id"
+	if b.config.BuildID != nil {
+		column = "extractor_id"
+	}
+
+	var interventions []uint
+	if err := query.Pluck(column, &interventions).Error; err != nil {
+		return utils.WrapError(err, "select human intervention extractors fail")
+	}
+
+	if len(interventions) == 0 {
+		return nil
+	}
+
+	var entities []string
+	err := b.db.Model(&metadata.Entity{}).
+		Where("extractor_id in ? and type = ?", interventions, metadata.EntityTypeDel).
+		Distinct().
+		Pluck("name", &entities).Error
+	if err != nil {
+		return utils.WrapError(err, "select deleted entities fail")
+	}
+
+	for _, name := range entities {
+		b.deletedEntities[name] = struct{}{}
+	}
+
+	var relations []mentionRow
+	err = b.db.Model(&metadata.Relation{}).
+		Select("relations.name, heads.name as head_name, tails.name as tail_name").
+		Joins("join entities heads on heads.id = relations.head_id and heads.deleted_at is null").
+		Joins("join entities tails on tails.id = relations.tail_id and tails.deleted_at is null").
+		Where("relations.extractor_id in ? and relations.type = ?", interventions, metadata.EntityTypeDel).
+		Scan(&relations).Error
+	if err != nil {
+		return utils.WrapError(err, "select deleted relations fail")
+	}
+
+	for _, relation := range relations {
+		b.deletedRelations[graph.KGEdge{Head: relation.HeadName, Relation: relation.Name, Tail: relation.TailName}] = struct{}{}
+	}
+
+	b.setting.Logger.Infof("dataset deletions: %d entities, %d relations", len(b.deletedEntities), len(b.deletedRelations))
+
+	return nil
+}
+
+func (b *datasetBuilder) isEntityDeleted(name string) bool {
+	_, ok := b.deletedEntities[name]
+	return ok
+}
+
+/*
+loadSentences 读取一批句子及其中的实体和关系。读取期间被删除的句子不在结果中，其中的实体和关系也被忽略。
+*/
+func (b *datasetBuilder) loadSentences(textIDs []uint) ([]Sentence, error) {
+	var texts []metadata.Text
+	if err := b.db.Select("id", "content").Order("id").Find(&texts, textIDs).Error; err != nil {
+		return nil, utils.WrapError(err, "select texts fail")
+	}
+
+	var entities []mentionRow
+	err := b.db.Model(&metadata.Entity{}).
+		Select("entities.id, entities.text_id, entities.task_id, entities.extractor_id, "+
+			"entities.name as head_name, entities.category as head_category, entities.begin as head_begin, "+
+			"entities.end as head_end, entities.confidence as head_confidence").
+		Scopes(b.scope("entities")).
+		Where("entities.text_id in ? and entities.type = ?", textIDs, metadata.EntityTypeAdd).
+		Order("entities.id").
+		Scan(&entities).Error
+	if err != nil {
+		return nil, utils.WrapError(err, "select entities fail")
+	}
+
+	var relations []mentionRow
+	err = b.db.Model(&metadata.Relation{}).
+		Select("relations.id, relations.text_id, relations.task_id, relations.extractor_id, relations.name, relations.confidence, "+
+			"heads.name as head_name, heads.category as head_category, heads.begin as head_begin, heads.end as head_end, heads.confidence as head_confidence, "+
+			"tails.name as tail_name, tails.category as tail_category, tails.begin as tail_begin, tails.end as tail_end, tails.confidence as tail_confidence").
+		Joins("join entities heads on heads.id = relations.head_id and heads.deleted_at is null").
+		Joins("join entities tails on tails.id = relations.tail_id and tails.deleted_at is null").
+		Scopes(b.scope("relations")).
+		Where("relations.text_id in ? and relations.type = ?", textIDs, metadata.EntityTypeAdd).
+		Order("relations.id").
+		Scan(&relations).Error
+	if err != nil {
+		return nil, utils.WrapError(err, "select relations fail")
+	}
+
+	index := make(map[uint]int, len(texts)) // Text.ID -> 在 ret 中的下标
+	ret := make([]Sentence, 0, len(texts))
+	for _, text := range texts {
+		index[text.ID] = len(ret)
+		ret = append(ret, Sentence{
+			TextID:    text.ID,
+			Content:   text.Content,
+			Entities:  make([]Mention, 0),
+			Relations: make([]RelationMention, 0),
+		})
+	}
+
+	for i := range entities {
+		row := &entities[i]
+		j, ok := index[row.TextID]
+		if !ok {
+			continue
+		}
+		sentence := &ret[j]
+		if sentence.TaskID == nil {
+			sentence.TaskID = row.TaskID
+		}
+
+		if b.isEntityDeleted(row.HeadName) {
+			continue
+		}
+
+		if mention, ok := makeMention(sentence.Content, row.HeadName, row.HeadCategory, row.HeadBegin, row.HeadEnd, row.HeadConfidence, row.ExtractorID); ok {
+			sentence.Entities = append(sentence.Entities, mention)
+		}
+	}
+
+	for i := range relations {
+		row := &relations[i]
+		j, ok := index[row.TextID]
+		if !ok {
+			continue
+		}
+		sentence := &ret[j]
+
+		if b.isEntityDeleted(row.HeadName) || b.isEntityDeleted(row.TailName) {
+			continue
+		}
+		if _, ok := b.deletedRelations[graph.KGEdge{Head: row.HeadName, Relation: row.Name, Tail: row.TailName}]; ok {
+			continue
+		}
+
+		head, ok := makeMention(sentence.Content, row.HeadName, row.HeadCategory, row.HeadBegin, row.HeadEnd, row.HeadConfidence, row.ExtractorID)
+		if !ok {
+			continue
+		}
+		tail, ok := makeMention(sentence.Content, row.TailName, row.TailCategory, row.TailBegin, row.TailEnd, row.TailConfidence, row.ExtractorID)
+		if !ok {
+			continue
+		}
+
+		sentence.Relations = append(sentence.Relations, RelationMention{
+			Name:        row.Name,
+			Confidence:  row.Confidence,
+			ExtractorID: row.ExtractorID,
+			Head:        head,
+			Tail:        tail,
+		})
+	}
+
+	for i := range ret {
+		entities := ret[i].Entities
+		sort.SliceStable(entities, func(a, b int) bool {
+			return entities[a].Begin < entities[b].Begin || entities[a].Begin == entities[b].Begin && entities[a].End > entities[b].End
+		})
+	}
+
+	return ret, nil
+}
+
+/*
+makeMention 通过 graph.LocateSpan 确定实体在句子中的位置，找不到时返回 false。
+*/
+func makeMention(content, name, category string, begin, end *int, confidence *float64, extractorID uint) (Mention, bool) {
+	span := graph.LocateSpan(content, name, begin, end)
+	if span == nil {
+		return Mention{}, false
+	}
+
+	return Mention{
+		Name:        name,
+		Category:    category,
+		Begin:       span.Begin,
+		End:         span.End,
+		Confidence:  confidence,
+		ExtractorID: extractorID,
+	}, true
+}
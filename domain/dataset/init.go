@@ -0,0 +1,22 @@
+package dataset
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type DatasetSetting struct {
+	GetMetadataDatabase func() *gorm.DB
+	Logger              *logrus.Logger
+}
+
+var globalSetting DatasetSetting
+
+func Init(setting *DatasetSetting) {
+	globalSetting = *setting
+}
+
+func Export(ctx context.Context, config *DatasetConfig, writer Writer) error {
+	return exportDataset(&globalSetting, ctx, config, writer)
+}
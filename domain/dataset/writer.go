@@ -0,0 +1,189 @@
+package dataset
+
+import (
+	"autograph-backend-controller/domain/extractorcall"
+	"autograph-backend-controller/utils"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"unicode"
+)
+
+const (
+	FormatJSONL = "jsonl"
+	FormatBIO   = "bio"
+)
+
+// bioDefaultCategory 实体类别为空时 BIO 标签中使用的类别
+const bioDefaultCategory = "ENT"
+
+var ErrUnknownFormat = errors.New("unknown dataset format")
+
+/*
+NewWriter 通过格式名创建 Writer，格式名不区分大小写。
+*/
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch strings.ToLower(format) {
+	case FormatJSONL:
+		return newJSONLWriter(w), nil
+	case FormatBIO:
+		return newBIOWriter(w), nil
+	}
+
+	return nil, utils.WrapErrorf(ErrUnknownFormat, "format %#v", format)
+}
+
+/*
+jsonlWriter 每行输出一个与 extractorcall.ReceiveSchema 结构相同的 JSON，Offset 固定为 0，位置均相对于整个句子。
+该结构只能表示关系，不属于任何关系的实体不会输出。
+*/
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func newJSONLWriter(w io.Writer) *jsonlWriter {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return &jsonlWriter{encoder: encoder}
+}
+
+func (j *jsonlWriter) ContentType() string {
+	return "application/x-ndjson; charset=utf-8"
+}
+
+func (j *jsonlWriter) Extension() string {
+	return "jsonl"
+}
+
+func (j *jsonlWriter) Sentence(sentence *Sentence) error {
+	return j.encoder.Encode(makeReceiveSchema(sentence))
+}
+
+func (j *jsonlWriter) End() error {
+	return nil
+}
+
+func makeReceiveSchema(sentence *Sentence) *extractorcall.ReceiveSchema {
+	ret := extractorcall.ReceiveSchema{
+		Text:    sentence.Content,
+		TextID:  sentence.TextID,
+		TaskID:  sentence.TaskID,
+		Offset:  0,
+		SPOList: make([]extractorcall.ReceiveSchemaSPO, 0, len(sentence.Relations)),
+	}
+
+	for _, relation := range sentence.Relations {
+		ret.SPOList = append(ret.SPOList, extractorcall.ReceiveSchemaSPO{
+			ExtractorId: relation.ExtractorID,
+			Relation:    relation.Name,
+			Confidence:  relation.Confidence,
+			HeadEntity:  makeReceiveSchemaEntity(&relation.Head),
+			TailEntity:  makeReceiveSchemaEntity(&relation.Tail),
+		})
+	}
+
+	return &ret
+}
+
+func makeReceiveSchemaEntity(mention *Mention) extractorcall.ReceiveSchemaEntity {
+	return extractorcall.ReceiveSchemaEntity{
+		Name:       mention.Name,
+		Begin:      mention.Begin,
+		End:        mention.End,
+		Category:   mention.Category,
+		Confidence: mention.Confidence,
+	}
+}
+
+/*
+bioWriter 以字符为单位输出 BIO 标注，每行为 "字符\t标签"，句子之间以空行分隔。
+标签为 B-类别、I-类别 或 O，类别转为大写，为空时使用 bioDefaultCategory。空白字符不输出；
+实体互相重叠时保留起始位置靠前的，起始位置相同时保留较长的。
+*/
+type bioWriter struct {
+	w     *bufio.Writer
+	first bool
+}
+
+func newBIOWriter(w io.Writer) *bioWriter {
+	return &bioWriter{w: bufio.NewWriter(w), first: true}
+}
+
+func (b *bioWriter) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+func (b *bioWriter) Extension() string {
+	return "bio"
+}
+
+func (b *bioWriter) Sentence(sentence *Sentence) error {
+	content := []rune(sentence.Content)
+	tags := bioTags(content, sentence.Entities)
+
+	written := false
+	for i, r := range content {
+		if unicode.IsSpace(r) {
+			continue
+		}
+
+		if !written && !b.first {
+			if err := b.w.WriteByte('\n'); err != nil {
+				return err
+			}
+		}
+		written, b.first = true, false
+
+		if _, err := b.w.WriteString(string(r) + "\t" + tags[i] + "\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *bioWriter) End() error {
+	return b.w.Flush()
+}
+
+/*
+bioTags 为句子中的每个字符生成 BIO 标签，entities 需按 Begin 升序、Begin 相同时按长度降序排列。
+*/
+func bioTags(content []rune, entities []Mention) []string {
+	ret := make([]string, len(content))
+	for i := range ret {
+		ret[i] = "O"
+	}
+
+	end := 0 // 已标注的实体的最大结束位置
+	for _, entity := range entities {
+		if entity.Begin < end || entity.Begin < 0 || entity.End > len(content) || entity.Begin >= entity.End {
+			continue
+		}
+
+		category := bioCategory(entity.Category)
+		prefix := "B-"
+		for i := entity.Begin; i < entity.End; i++ {
+			// 实体开头的空白字符不输出，B 标签落在第一个非空白字符上
+			if unicode.IsSpace(content[i]) {
+				continue
+			}
+			ret[i] = prefix + category
+			prefix = "I-"
+		}
+		end = entity.End
+	}
+
+	return ret
+}
+
+func bioCategory(category string) string {
+	category = strings.TrimSpace(category)
+	if len(category) == 0 {
+		return bioDefaultCategory
+	}
+
+	return strings.ToUpper(strings.Join(strings.Fields(category), "_"))
+}
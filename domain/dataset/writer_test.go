@@ -0,0 +1,95 @@
+package dataset
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func testSentence() *Sentence {
+	confidence := 0.9
+	head := Mention{Name: "小明", Category: "person", Begin: 0, End: 2, ExtractorID: 1}
+	tail := Mention{Name: "北京大学", Category: "org unit", Begin: 5, End: 9, ExtractorID: 1}
+	taskID := uint(3)
+	return &Sentence{
+		TextID:  7,
+		TaskID:  &taskID,
+		Content: "小明就读于北京大学 。",
+		Entities: []Mention{
+			head,
+			tail,
+			{Name: "北京", Begin: 5, End: 7, ExtractorID: 1}, // 与北京大学重叠
+		},
+		Relations: []RelationMention{
+			{Name: "就读于", Confidence: &confidence, ExtractorID: 1, Head: head, Tail: tail},
+		},
+	}
+}
+
+func TestBIOWriter(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := NewWriter("BIO", &buf)
+	require.Nil(t, err)
+
+	require.Nil(t, writer.Sentence(testSentence()))
+	require.Nil(t, writer.Sentence(&Sentence{Content: "好 的", Entities: []Mention{{Name: "好", Begin: 0, End: 1}}}))
+	require.Nil(t, writer.End())
+
+	expected := strings.Join([]string{
+		"小\tB-PERSON",
+		"明\tI-PERSON",
+		"就\tO",
+		"读\tO",
+		"于\tO",
+		"北\tB-ORG_UNIT",
+		"京\tI-ORG_UNIT",
+		"大\tI-ORG_UNIT",
+		"学\tI-ORG_UNIT",
+		"。\tO",
+		"",
+		"好\tB-ENT",
+		"的\tO",
+		"",
+	}, "\n")
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestJSONLWriter(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := NewWriter("jsonl", &buf)
+	require.Nil(t, err)
+
+	require.Nil(t, writer.Sentence(testSentence()))
+	require.Nil(t, writer.Sentence(&Sentence{TextID: 8, Content: "无关系"}))
+	require.Nil(t, writer.End())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var first map[string]interface{}
+	require.Nil(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, "小明就读于北京大学 。", first["text"])
+	assert.EqualValues(t, 7, first["text_id"])
+	assert.EqualValues(t, 3, first["task_id"])
+	assert.EqualValues(t, 0, first["offset"])
+
+	spoList := first["spo_list"].([]interface{})
+	require.Len(t, spoList, 1)
+	spo := spoList[0].(map[string]interface{})
+	assert.Equal(t, "就读于", spo["relation"])
+	assert.EqualValues(t, 0.9, spo["confidence"])
+	tail := spo["tail_entity"].(map[string]interface{})
+	assert.Equal(t, "北京大学", tail["name"])
+	assert.EqualValues(t, 5, tail["begin"])
+	assert.EqualValues(t, 9, tail["end"])
+
+	assert.Contains(t, lines[1], `"spo_list":[]`)
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := NewWriter("conll", &bytes.Buffer{})
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
@@ -0,0 +1,56 @@
+package export
+
+import (
+	"autograph-backend-controller/domain/graph"
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+/*
+csvExporter 导出 RFC 4180 格式的 CSV，每一行为一个节点或一条边，列为 kind,name,category,head,relation,tail,confidence。
+节点行只填写 name 与 category，边行只填写 head、relation、tail 与 confidence，旧版本构建的边没有置信度。
+*/
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func newCSVExporter(w io.Writer) *csvExporter {
+	return &csvExporter{w: csv.NewWriter(w)}
+}
+
+func (e *csvExporter) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+func (e *csvExporter) Extension() string {
+	return "csv"
+}
+
+func (e *csvExporter) Begin() error {
+	return e.w.Write([]string{"kind", "name", "category", "head", "relation", "tail", "confidence"})
+}
+
+func (e *csvExporter) Node(node *graph.KGNode) error {
+	if err := e.w.Write([]string{"node", node.Name, node.Category, "", "", "", ""}); err != nil {
+		return err
+	}
+
+	for _, edge := range node.Edges {
+		confidence := ""
+		if edge.Confidence != nil {
+			confidence = strconv.FormatFloat(*edge.Confidence, 'f', -1, 64)
+		}
+
+		if err := e.w.Write([]string{"edge", "", "", node.Name, edge.Relation, edge.Tail, confidence}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *csvExporter) End() error {
+	e.w.Flush()
+	return e.w.Error()
+}
@@ -0,0 +1,117 @@
+package export
+
+import (
+	"autograph-backend-controller/domain/graph"
+	"autograph-backend-controller/utils"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+)
+
+const (
+	FormatCSV      = "csv"
+	FormatNTriples = "ntriples"
+	FormatTurtle   = "turtle"
+	FormatJSONLD   = "jsonld"
+	FormatGraphML  = "graphml"
+)
+
+// DefaultNamespace 未指定命名空间时，RDF 类格式中实体、关系、类别的 IRI 前缀
+const DefaultNamespace = "http://autograph.local/kg/"
+
+var (
+	ErrUnknownFormat    = errors.New("unknown export format")
+	ErrInvalidNamespace = errors.New("invalid namespace")
+)
+
+/*
+Option 导出的选项。
+
+	Version 导出的版本号，用于图的标识；
+	Namespace RDF 类格式使用的命名空间，为空时使用 DefaultNamespace；
+*/
+type Option struct {
+	Version   uint
+	Namespace string
+}
+
+/*
+Exporter 将图谱以某种格式写入 io.Writer，依次调用 Begin、对每个节点调用 Node、End。
+节点的出边可能指向尚未写入的节点，各格式都需要允许这种情况。
+*/
+type Exporter interface {
+	ContentType() string
+	Extension() string
+	Begin() error
+	Node(node *graph.KGNode) error
+	End() error
+}
+
+/*
+NewExporter 通过格式名创建 Exporter，格式名不区分大小写。
+*/
+func NewExporter(format string, w io.Writer, option *Option) (Exporter, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return newCSVExporter(w), nil
+	case FormatGraphML:
+		return newGraphMLExporter(w, option.Version), nil
+	}
+
+	namespace, err := normalizeNamespace(option.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(format) {
+	case FormatNTriples:
+		return newNTriplesExporter(w, namespace), nil
+	case FormatTurtle:
+		return newTurtleExporter(w, namespace), nil
+	case FormatJSONLD:
+		return newJSONLDExporter(w, namespace), nil
+	}
+
+	return nil, utils.WrapErrorf(ErrUnknownFormat, "format %#v", format)
+}
+
+/*
+normalizeNamespace 检查命名空间是否为绝对 IRI，并保证以 / 或 # 结尾。
+*/
+func normalizeNamespace(namespace string) (string, error) {
+	if len(namespace) == 0 {
+		return DefaultNamespace, nil
+	}
+
+	u, err := url.Parse(namespace)
+	if err != nil || !u.IsAbs() || strings.ContainsAny(namespace, " <>\"{}|^`\\") {
+		return "", utils.WrapErrorf(ErrInvalidNamespace, "namespace %#v is not an absolute IRI", namespace)
+	}
+
+	if !strings.HasSuffix(namespace, "/") && !strings.HasSuffix(namespace, "#") {
+		namespace += "/"
+	}
+
+	return namespace, nil
+}
+
+/*
+Export 分批读取一次构建的所有节点并交给 exporter 写出，不会一次性将整个图谱读入内存。
+*/
+func Export(ctx context.Context, buildID uint, exporter Exporter) error {
+	if err := exporter.Begin(); err != nil {
+		return utils.WrapError(err, "begin export fail")
+	}
+
+	if err := graph.WalkKG(ctx, buildID, exporter.Node); err != nil {
+		return utils.WrapErrorf(err, "export nodes of build [%d] fail", buildID)
+	}
+
+	if err := exporter.End(); err != nil {
+		return utils.WrapError(err, "end export fail")
+	}
+
+	return nil
+}
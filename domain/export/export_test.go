@@ -0,0 +1,140 @@
+package export
+
+import (
+	"autograph-backend-controller/domain/graph"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func testNodes() []graph.KGNode {
+	confidence := 0.75
+	return []graph.KGNode{
+		{
+			Name:     `小明 "明哥"`,
+			Category: "person",
+			Edges: []graph.KGOutEdge{
+				{Relation: "就读于", Tail: "北京<航空>航天大学", Confidence: &confidence},
+				{Relation: "认识", Tail: "小红,\n小绿"},
+			},
+		},
+		{Name: "北京<航空>航天大学", Category: "organization", Edges: []graph.KGOutEdge{}},
+		{Name: "小红,\n小绿", Edges: []graph.KGOutEdge{}},
+	}
+}
+
+func exportNodes(t *testing.T, format string, option *Option) string {
+	var buf bytes.Buffer
+	exporter, err := NewExporter(format, &buf, option)
+	require.Nil(t, err)
+
+	require.Nil(t, exporter.Begin())
+	nodes := testNodes()
+	for i := range nodes {
+		require.Nil(t, exporter.Node(&nodes[i]))
+	}
+	require.Nil(t, exporter.End())
+
+	return buf.String()
+}
+
+func TestCSVExporter(t *testing.T) {
+	rows, err := csv.NewReader(strings.NewReader(exportNodes(t, FormatCSV, &Option{}))).ReadAll()
+	require.Nil(t, err)
+
+	assert.Equal(t, [][]string{
+		{"kind", "name", "category", "head", "relation", "tail", "confidence"},
+		{"node", `小明 "明哥"`, "person", "", "", "", ""},
+		{"edge", "", "", `小明 "明哥"`, "就读于", "北京<航空>航天大学", "0.75"},
+		{"edge", "", "", `小明 "明哥"`, "认识", "小红,\n小绿", ""},
+		{"node", "北京<航空>航天大学", "organization", "", "", "", ""},
+		{"node", "小红,\n小绿", "", "", "", "", ""},
+	}, rows)
+}
+
+func TestNTriplesExporter(t *testing.T) {
+	out := exportNodes(t, "NTriples", &Option{Namespace: "http://example.org/kg"})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 7)
+
+	subject := "<http://example.org/kg/entity/%E5%B0%8F%E6%98%8E%20%22%E6%98%8E%E5%93%A5%22>"
+	assert.Equal(t, subject+" <"+rdfType+"> <http://example.org/kg/category/person> .", lines[0])
+	assert.Equal(t, subject+` <`+rdfsLabel+`> "小明 \"明哥\"" .`, lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], subject+" <http://example.org/kg/relation/"))
+	assert.Equal(t, `<http://example.org/kg/entity/%E5%B0%8F%E7%BA%A2%2C%0A%E5%B0%8F%E7%BB%BF> <`+rdfsLabel+`> "小红,\n小绿" .`, lines[6])
+
+	for _, line := range lines {
+		assert.True(t, strings.HasSuffix(line, " ."), line)
+		assert.NotContains(t, line, "<北京")
+	}
+}
+
+func TestTurtleExporter(t *testing.T) {
+	out := exportNodes(t, FormatTurtle, &Option{})
+	assert.True(t, strings.HasPrefix(out, "@prefix rdfs: <http://www.w3.org/2000/01/rdf-schema#> .\n"))
+	assert.Contains(t, out, "\ta <"+DefaultNamespace+"category/person> ;\n\trdfs:label \"小明 \\\"明哥\\\"\" ;\n")
+	assert.Equal(t, 3, strings.Count(out, " .\n")-1)
+}
+
+func TestJSONLDExporter(t *testing.T) {
+	var doc struct {
+		Context map[string]interface{}   `json:"@context"`
+		Graph   []map[string]interface{} `json:"@graph"`
+	}
+	require.Nil(t, json.Unmarshal([]byte(exportNodes(t, FormatJSONLD, &Option{})), &doc))
+
+	require.Len(t, doc.Graph, 3)
+	assert.Equal(t, `小明 "明哥"`, doc.Graph[0]["name"])
+	assert.Equal(t, DefaultNamespace+"category/person", doc.Graph[0]["@type"])
+	assert.Len(t, doc.Graph[0], 5)
+	assert.Nil(t, doc.Graph[2]["@type"])
+}
+
+func TestGraphMLExporter(t *testing.T) {
+	var doc struct {
+		Graph struct {
+			ID    string `xml:"id,attr"`
+			Nodes []struct {
+				ID string `xml:"id,attr"`
+			} `xml:"node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+				Data   []struct {
+					Key   string `xml:"key,attr"`
+					Value string `xml:",chardata"`
+				} `xml:"data"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+	require.Nil(t, xml.Unmarshal([]byte(exportNodes(t, FormatGraphML, &Option{Version: 3})), &doc))
+
+	assert.Equal(t, "kg-3", doc.Graph.ID)
+	require.Len(t, doc.Graph.Nodes, 3)
+	assert.Equal(t, `小明 "明哥"`, doc.Graph.Nodes[0].ID)
+	require.Len(t, doc.Graph.Edges, 2)
+	assert.Equal(t, "北京<航空>航天大学", doc.Graph.Edges[0].Target)
+	assert.Len(t, doc.Graph.Edges[0].Data, 2)
+	assert.Len(t, doc.Graph.Edges[1].Data, 1)
+}
+
+func TestNewExporter(t *testing.T) {
+	var buf bytes.Buffer
+
+	_, err := NewExporter("xlsx", &buf, &Option{})
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	for _, namespace := range []string{"kg/", "http://example.org/a b"} {
+		_, err = NewExporter(FormatTurtle, &buf, &Option{Namespace: namespace})
+		assert.ErrorIs(t, err, ErrInvalidNamespace, namespace)
+	}
+
+	namespace, err := normalizeNamespace("http://example.org/kg#")
+	assert.Nil(t, err)
+	assert.Equal(t, "http://example.org/kg#", namespace)
+}
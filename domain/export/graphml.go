@@ -0,0 +1,78 @@
+package export
+
+import (
+	"autograph-backend-controller/domain/graph"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+graphmlExporter 导出 GraphML，节点的 id 为实体名，节点有 name、category 属性，边有 relation、confidence 属性。
+*/
+type graphmlExporter struct {
+	w       *bufio.Writer
+	version uint
+}
+
+func newGraphMLExporter(w io.Writer, version uint) *graphmlExporter {
+	return &graphmlExporter{w: bufio.NewWriter(w), version: version}
+}
+
+func (e *graphmlExporter) ContentType() string {
+	return "application/graphml+xml; charset=utf-8"
+}
+
+func (e *graphmlExporter) Extension() string {
+	return "graphml"
+}
+
+func (e *graphmlExporter) Begin() error {
+	e.w.WriteString(xml.Header)
+	e.w.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	e.w.WriteString(`  <key id="name" for="node" attr.name="name" attr.type="string"/>` + "\n")
+	e.w.WriteString(`  <key id="category" for="node" attr.name="category" attr.type="string"/>` + "\n")
+	e.w.WriteString(`  <key id="relation" for="edge" attr.name="relation" attr.type="string"/>` + "\n")
+	e.w.WriteString(`  <key id="confidence" for="edge" attr.name="confidence" attr.type="double"/>` + "\n")
+	_, err := e.w.WriteString(fmt.Sprintf(`  <graph id="kg-%d" edgedefault="directed">`+"\n", e.version))
+	return err
+}
+
+func (e *graphmlExporter) Node(node *graph.KGNode) error {
+	id := xmlEscape(node.Name)
+
+	e.w.WriteString(`    <node id="` + id + `">`)
+	e.w.WriteString(`<data key="name">` + id + `</data>`)
+	if len(node.Category) != 0 {
+		e.w.WriteString(`<data key="category">` + xmlEscape(node.Category) + `</data>`)
+	}
+	e.w.WriteString("</node>\n")
+
+	for _, edge := range node.Edges {
+		e.w.WriteString(`    <edge source="` + id + `" target="` + xmlEscape(edge.Tail) + `">`)
+		e.w.WriteString(`<data key="relation">` + xmlEscape(edge.Relation) + `</data>`)
+		if edge.Confidence != nil {
+			e.w.WriteString(`<data key="confidence">` + strconv.FormatFloat(*edge.Confidence, 'f', -1, 64) + `</data>`)
+		}
+		e.w.WriteString("</edge>\n")
+	}
+
+	return nil
+}
+
+func (e *graphmlExporter) End() error {
+	e.w.WriteString("  </graph>\n</graphml>\n")
+	return e.w.Flush()
+}
+
+/*
+xmlEscape 转义 XML 的特殊字符，结果可以用于元素内容和属性值
+*/
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,80 @@
+package export
+
+import (
+	"autograph-backend-controller/domain/graph"
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+/*
+jsonldExporter 导出 JSON-LD，每个实体为 @graph 中的一个对象：@type 为类别，name 为 rdfs:label，出边为以关系 IRI 为 key 的属性。
+与 RDF 三元组相同，不导出边的置信度。
+*/
+type jsonldExporter struct {
+	w     *bufio.Writer
+	terms rdfTerms
+	count int
+}
+
+func newJSONLDExporter(w io.Writer, namespace string) *jsonldExporter {
+	return &jsonldExporter{w: bufio.NewWriter(w), terms: rdfTerms{namespace: namespace}}
+}
+
+func (e *jsonldExporter) ContentType() string {
+	return "application/ld+json; charset=utf-8"
+}
+
+func (e *jsonldExporter) Extension() string {
+	return "jsonld"
+}
+
+func (e *jsonldExporter) Begin() error {
+	context, err := json.Marshal(map[string]interface{}{
+		"name": map[string]string{"@id": rdfsLabel},
+	})
+	if err != nil {
+		return err
+	}
+
+	e.w.WriteString(`{"@context":`)
+	e.w.Write(context)
+	_, err = e.w.WriteString(`,"@graph":[`)
+	return err
+}
+
+func (e *jsonldExporter) Node(node *graph.KGNode) error {
+	object := map[string]interface{}{
+		"@id":  e.terms.entity(node.Name),
+		"name": node.Name,
+	}
+
+	if len(node.Category) != 0 {
+		object["@type"] = e.terms.category(node.Category)
+	}
+
+	for _, edge := range node.Edges {
+		key := e.terms.relation(edge.Relation)
+		tails, _ := object[key].([]map[string]string)
+		object[key] = append(tails, map[string]string{"@id": e.terms.entity(edge.Tail)})
+	}
+
+	data, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+
+	if e.count != 0 {
+		e.w.WriteString(",")
+	}
+	e.count++
+
+	e.w.WriteString("\n")
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonldExporter) End() error {
+	e.w.WriteString("\n]}\n")
+	return e.w.Flush()
+}
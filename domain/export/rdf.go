@@ -0,0 +1,161 @@
+package export
+
+import (
+	"autograph-backend-controller/domain/graph"
+	"bufio"
+	"io"
+	"net/url"
+	"strings"
+)
+
+const (
+	rdfType   = "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
+	rdfsLabel = "http://www.w3.org/2000/01/rdf-schema#label"
+)
+
+/*
+rdfTerms 生成实体、关系、类别的 IRI：分别为 命名空间 + entity/、relation/、category/ + 百分号编码的名字。
+*/
+type rdfTerms struct {
+	namespace string
+}
+
+func (t *rdfTerms) entity(name string) string {
+	return t.namespace + "entity/" + url.PathEscape(name)
+}
+
+func (t *rdfTerms) relation(name string) string {
+	return t.namespace + "relation/" + url.PathEscape(name)
+}
+
+func (t *rdfTerms) category(name string) string {
+	return t.namespace + "category/" + url.PathEscape(name)
+}
+
+func iriRef(iri string) string {
+	return "<" + iri + ">"
+}
+
+var literalReplacer = strings.NewReplacer(
+	"\\", "\\\\",
+	"\"", "\\\"",
+	"\n", "\\n",
+	"\r", "\\r",
+	"\t", "\\t",
+	"\b", "\\b",
+	"\f", "\\f",
+)
+
+/*
+literal 生成 N-Triples 与 Turtle 通用的字符串字面量
+*/
+func literal(s string) string {
+	return "\"" + literalReplacer.Replace(s) + "\""
+}
+
+/*
+ntriplesExporter 导出 N-Triples，每个实体有 rdfs:label，有类别时有 rdf:type，每条边为一个三元组。
+RDF 三元组无法直接表示边的置信度，因此不导出置信度。
+*/
+type ntriplesExporter struct {
+	w     *bufio.Writer
+	terms rdfTerms
+}
+
+func newNTriplesExporter(w io.Writer, namespace string) *ntriplesExporter {
+	return &ntriplesExporter{w: bufio.NewWriter(w), terms: rdfTerms{namespace: namespace}}
+}
+
+func (e *ntriplesExporter) ContentType() string {
+	return "application/n-triples; charset=utf-8"
+}
+
+func (e *ntriplesExporter) Extension() string {
+	return "nt"
+}
+
+func (e *ntriplesExporter) Begin() error {
+	return nil
+}
+
+func (e *ntriplesExporter) triple(subject, predicate, object string) {
+	e.w.WriteString(subject)
+	e.w.WriteString(" ")
+	e.w.WriteString(predicate)
+	e.w.WriteString(" ")
+	e.w.WriteString(object)
+	e.w.WriteString(" .\n")
+}
+
+func (e *ntriplesExporter) Node(node *graph.KGNode) error {
+	subject := iriRef(e.terms.entity(node.Name))
+
+	if len(node.Category) != 0 {
+		e.triple(subject, iriRef(rdfType), iriRef(e.terms.category(node.Category)))
+	}
+	e.triple(subject, iriRef(rdfsLabel), literal(node.Name))
+
+	for _, edge := range node.Edges {
+		e.triple(subject, iriRef(e.terms.relation(edge.Relation)), iriRef(e.terms.entity(edge.Tail)))
+	}
+
+	return nil
+}
+
+func (e *ntriplesExporter) End() error {
+	return e.w.Flush()
+}
+
+/*
+turtleExporter 导出 Turtle，内容与 ntriplesExporter 相同，同一个实体的三元组合并为一组。
+*/
+type turtleExporter struct {
+	w     *bufio.Writer
+	terms rdfTerms
+}
+
+func newTurtleExporter(w io.Writer, namespace string) *turtleExporter {
+	return &turtleExporter{w: bufio.NewWriter(w), terms: rdfTerms{namespace: namespace}}
+}
+
+func (e *turtleExporter) ContentType() string {
+	return "text/turtle; charset=utf-8"
+}
+
+func (e *turtleExporter) Extension() string {
+	return "ttl"
+}
+
+func (e *turtleExporter) Begin() error {
+	_, err := e.w.WriteString("@prefix rdfs: <http://www.w3.org/2000/01/rdf-schema#> .\n")
+	return err
+}
+
+func (e *turtleExporter) Node(node *graph.KGNode) error {
+	e.w.WriteString("\n")
+	e.w.WriteString(iriRef(e.terms.entity(node.Name)))
+	e.w.WriteString("\n")
+
+	if len(node.Category) != 0 {
+		e.w.WriteString("\ta ")
+		e.w.WriteString(iriRef(e.terms.category(node.Category)))
+		e.w.WriteString(" ;\n")
+	}
+
+	e.w.WriteString("\trdfs:label ")
+	e.w.WriteString(literal(node.Name))
+
+	for _, edge := range node.Edges {
+		e.w.WriteString(" ;\n\t")
+		e.w.WriteString(iriRef(e.terms.relation(edge.Relation)))
+		e.w.WriteString(" ")
+		e.w.WriteString(iriRef(e.terms.entity(edge.Tail)))
+	}
+
+	_, err := e.w.WriteString(" .\n")
+	return err
+}
+
+func (e *turtleExporter) End() error {
+	return e.w.Flush()
+}
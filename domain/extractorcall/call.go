@@ -7,12 +7,65 @@ import (
 	"errors"
 	"github.com/streadway/amqp"
 	"gorm.io/gorm"
+	"math"
+	"strings"
 )
 
 func Send(info SendSchema) error {
 	return globalMQManager.SendObjectByJSON(QueueExtractorInput, info)
 }
 
+/*
+normalizeCategory 将抽取器输出的实体类别统一为小写、去除首尾空白。
+*/
+func normalizeCategory(category string) string {
+	return strings.ToLower(strings.TrimSpace(category))
+}
+
+/*
+normalizeConfidence 将抽取器输出的置信度限制在 [0, 1] 内，为空时保持为空。
+*/
+func normalizeConfidence(confidence *float64) *float64 {
+	if confidence == nil {
+		return nil
+	}
+
+	ret := math.Max(0, math.Min(1, *confidence))
+	return &ret
+}
+
+/*
+entitySpan 返回实体在 Text.Content 中的位置。抽取器给出的位置相对于发送的文本，需要加上 offset；位置不合法时返回空。
+*/
+func entitySpan(offset int, entity *ReceiveSchemaEntity) (*int, *int) {
+	if entity.Begin < 0 || entity.End <= entity.Begin {
+		return nil, nil
+	}
+
+	return utils.IntToPtr(offset + entity.Begin), utils.IntToPtr(offset + entity.End)
+}
+
+/*
+relationSpan 返回覆盖头尾实体的最小范围，任意一个实体的位置未知时返回空。
+*/
+func relationSpan(head, tail *metadata.Entity) (*int, *int) {
+	if head.Begin == nil || head.End == nil || tail.Begin == nil || tail.End == nil {
+		return nil, nil
+	}
+
+	begin := *head.Begin
+	if *tail.Begin < begin {
+		begin = *tail.Begin
+	}
+
+	end := *head.End
+	if *tail.End > end {
+		end = *tail.End
+	}
+
+	return utils.IntToPtr(begin), utils.IntToPtr(end)
+}
+
 type rangeTuple struct {
 	begin int
 	end   int
@@ -31,9 +84,14 @@ func saveReceivedData(tx *gorm.DB, data ReceiveSchema) error {
 		}
 
 		if entityIndex[headKey] == nil {
+			begin, end := entitySpan(data.Offset, &headOrigin)
 			head := metadata.Entity{
 				Name:        headOrigin.Name,
 				Type:        metadata.EntityTypeAdd,
+				Category:    normalizeCategory(headOrigin.Category),
+				Confidence:  normalizeConfidence(headOrigin.Confidence),
+				Begin:       begin,
+				End:         end,
 				ExtractorID: spo.ExtractorId,
 				TextID:      utils.UintToPtr(data.TextID),
 				TaskID:      data.TaskID,
@@ -54,9 +112,14 @@ func saveReceivedData(tx *gorm.DB, data ReceiveSchema) error {
 		}
 
 		if entityIndex[tailKey] == nil {
+			begin, end := entitySpan(data.Offset, &tailOrigin)
 			tail := metadata.Entity{
 				Name:        tailOrigin.Name,
 				Type:        metadata.EntityTypeAdd,
+				Category:    normalizeCategory(tailOrigin.Category),
+				Confidence:  normalizeConfidence(tailOrigin.Confidence),
+				Begin:       begin,
+				End:         end,
 				ExtractorID: spo.ExtractorId,
 				TextID:      utils.UintToPtr(data.TextID),
 				TaskID:      data.TaskID,
@@ -88,10 +151,14 @@ func saveReceivedData(tx *gorm.DB, data ReceiveSchema) error {
 
 		head := entityIndex[headKey]
 		tail := entityIndex[tailKey]
+		begin, end := relationSpan(head, tail)
 
 		relation := metadata.Relation{
 			Name:        spo.Relation,
 			Type:        metadata.EntityTypeAdd,
+			Confidence:  normalizeConfidence(spo.Confidence),
+			Begin:       begin,
+			End:         end,
 			ExtractorID: spo.ExtractorId,
 			TextID:      utils.UintToPtr(data.TextID),
 			HeadID:      head.ID,
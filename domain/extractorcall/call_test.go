@@ -0,0 +1,44 @@
+package extractorcall
+
+import (
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/utils"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEntitySpan(t *testing.T) {
+	span := func(offset, begin, end int) []*int {
+		b, e := entitySpan(offset, &ReceiveSchemaEntity{Name: "实体", Begin: begin, End: end})
+		return []*int{b, e}
+	}
+
+	// 位置加上句子在文本中的偏移
+	assert.Equal(t, []*int{utils.IntToPtr(2), utils.IntToPtr(4)}, span(0, 2, 4))
+	assert.Equal(t, []*int{utils.IntToPtr(12), utils.IntToPtr(14)}, span(10, 2, 4))
+
+	// 非法的范围视为未知
+	assert.Equal(t, []*int{nil, nil}, span(10, -1, 4))
+	assert.Equal(t, []*int{nil, nil}, span(10, 4, 4))
+	assert.Equal(t, []*int{nil, nil}, span(10, 4, 2))
+}
+
+func TestRelationSpan(t *testing.T) {
+	entity := func(begin, end *int) *metadata.Entity {
+		return &metadata.Entity{Begin: begin, End: end}
+	}
+
+	begin, end := relationSpan(entity(utils.IntToPtr(2), utils.IntToPtr(4)), entity(utils.IntToPtr(6), utils.IntToPtr(9)))
+	assert.Equal(t, utils.IntToPtr(2), begin)
+	assert.Equal(t, utils.IntToPtr(9), end)
+
+	// 尾实体在头实体之前
+	begin, end = relationSpan(entity(utils.IntToPtr(6), utils.IntToPtr(9)), entity(utils.IntToPtr(2), utils.IntToPtr(4)))
+	assert.Equal(t, utils.IntToPtr(2), begin)
+	assert.Equal(t, utils.IntToPtr(9), end)
+
+	// 任意一个实体的位置未知
+	begin, end = relationSpan(entity(utils.IntToPtr(2), utils.IntToPtr(4)), entity(nil, nil))
+	assert.Nil(t, begin)
+	assert.Nil(t, end)
+}
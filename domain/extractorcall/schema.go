@@ -8,14 +8,17 @@ type SendSchema struct {
 }
 
 type ReceiveSchemaEntity struct {
-	Name  string `json:"name"`
-	Begin int    `json:"begin"`
-	End   int    `json:"end"`
+	Name       string   `json:"name"`
+	Begin      int      `json:"begin"`
+	End        int      `json:"end"`
+	Category   string   `json:"category,omitempty"`   // 可选，实体类别，如 person、organization、location
+	Confidence *float64 `json:"confidence,omitempty"` // 可选，置信度，取值为 [0, 1]
 }
 
 type ReceiveSchemaSPO struct {
 	ExtractorId uint                `json:"extractor_id"`
 	Relation    string              `json:"relation"`
+	Confidence  *float64            `json:"confidence,omitempty"` // 可选，置信度，取值为 [0, 1]
 	HeadEntity  ReceiveSchemaEntity `json:"head_entity"`
 	TailEntity  ReceiveSchemaEntity `json:"tail_entity"`
 }
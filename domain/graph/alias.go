@@ -0,0 +1,99 @@
+package graph
+
+import (
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/utils"
+	"gorm.io/gorm"
+	"sort"
+)
+
+/*
+loadAliases 读取所有未删除的 EntityAlias，填充 b.aliases 和 b.aliasesOf。
+*/
+func (b *kgBuilder) loadAliases() error {
+	var rows []metadata.EntityAlias
+	if err := b.tx.Select("canonical_name", "alias").Find(&rows).Error; err != nil {
+		return utils.WrapError(err, "select aliases fail")
+	}
+
+	b.aliases = make(map[string]string, len(rows))
+	b.aliasesOf = make(map[string][]string)
+	for _, row := range rows {
+		b.aliases[row.Alias] = row.CanonicalName
+		b.aliasesOf[row.CanonicalName] = append(b.aliasesOf[row.CanonicalName], row.Alias)
+	}
+
+	b.setting.Logger.Infof("loaded aliases(len=%d)", len(rows))
+	return nil
+}
+
+/*
+canonicalName 返回 name 的规范名，name 不是别名时返回 name 本身。
+*/
+func (b *kgBuilder) canonicalName(name string) string {
+	if canonical, ok := b.aliases[name]; ok {
+		return canonical
+	}
+	return name
+}
+
+func (b *kgBuilder) canonicalizeEntities(entities []entityRow) {
+	for i := 0; i < len(entities); i++ {
+		entities[i].OriginName = entities[i].Name
+		entities[i].Name = b.canonicalName(entities[i].Name)
+	}
+}
+
+func (b *kgBuilder) canonicalizeRelations(relations []relationRow) {
+	for i := 0; i < len(relations); i++ {
+		relations[i].TailName = b.canonicalName(relations[i].TailName)
+	}
+}
+
+/*
+expandAliases 返回 names 以及 names 中所有规范名的别名，用于按规范名查询原始的 Entity。
+*/
+func (b *kgBuilder) expandAliases(names []string) []string {
+	ret := make([]string, 0, len(names))
+	for _, name := range names {
+		ret = append(ret, name)
+		ret = append(ret, b.aliasesOf[name]...)
+	}
+	return ret
+}
+
+/*
+pluckCanonicalNames 查询 query 结果中 column 列的所有不重复的值，转换为规范名后加入 into。
+*/
+func (b *kgBuilder) pluckCanonicalNames(query *gorm.DB, column string, into map[string]struct{}) error {
+	raw := make(map[string]struct{})
+	if err := pluckNames(query, column, raw); err != nil {
+		return err
+	}
+
+	for name := range raw {
+		into[b.canonicalName(name)] = struct{}{}
+	}
+
+	return nil
+}
+
+/*
+aliasesForNode 返回归并到该 Node 的别名，即 Node 的 Entity 中与规范名不同的原始名字。
+*/
+func aliasesForNode(entities []*entityRow) []string {
+	set := make(map[string]struct{})
+	for _, entity := range entities {
+		if entity.OriginName != entity.Name {
+			set[entity.OriginName] = struct{}{}
+		}
+	}
+
+	ret := make([]string, 0, len(set))
+	for name := range set {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
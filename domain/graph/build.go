@@ -5,9 +5,26 @@ import (
 	"time"
 )
 
+type KGBuildStage uint
+
+const (
+	KGBuildStageSnapshot KGBuildStage = iota + 1
+	KGBuildStageCollectEntities
+	KGBuildStageCreateNodes
+)
+
+/*
+KGProgressReporter 用于报告构建的进度，finished 与 total 分别表示当前阶段已完成和总共的工作量。
+*/
+type KGProgressReporter func(stage KGBuildStage, finished, total int)
+
 type KGBuildConfig struct {
-	ExtractorIDList []uint
-	Desc            string
+	ExtractorIDList  []uint
+	Desc             string
+	BaseBuildID      uint               // 非零时基于该构建进行增量构建
+	RelationResolver RelationResolver   // 为空时使用 majority 策略
+	MinConfidence    float64            // 非零时忽略置信度低于该值的 Entity 和 Relation
+	Progress         KGProgressReporter // 可为空
 }
 
 type KGBuildResult struct {
@@ -15,16 +32,21 @@ type KGBuildResult struct {
 	ExtractorSnapshotMap map[uint]uint // Extractor.ID -> BuildExtractor.ID
 	DeletedEntities      map[string]struct{}
 	SelectedEntities     map[string]struct{}
+	ReusedNodes          int // 增量构建时直接复用的 Node 数量
 	StartTime            time.Time
 	FinishTime           time.Time
 }
 
 func buildKG(setting *KGSetting, ctx context.Context, config *KGBuildConfig) (*KGBuildResult, error) {
 	builder := kgBuilder{
-		config:  config,
-		result:  &KGBuildResult{},
-		ctx:     ctx,
-		setting: setting,
+		config:   config,
+		result:   &KGBuildResult{},
+		ctx:      ctx,
+		setting:  setting,
+		resolver: config.RelationResolver,
+	}
+	if builder.resolver == nil {
+		builder.resolver = &majorityResolver{}
 	}
 	err := setting.GetMetadataDatabase().WithContext(ctx).Transaction(builder.build)
 	return builder.result, err
@@ -6,10 +6,15 @@ import (
 	"context"
 	"crypto/md5"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
 )
 
@@ -314,7 +319,8 @@ func TestBuildKG(t *testing.T) {
 				continue
 			}
 
-			info := outSchema.NextNodes[nextNodeName]
+			require.Len(t, outSchema.NextNodes[nextNodeName], 1)
+			info := outSchema.NextNodes[nextNodeName][0]
 			require.Equal(t, "R2", info.Name)
 
 			if i >= 80 {
@@ -366,7 +372,8 @@ func TestBuildKG(t *testing.T) {
 			continue
 		}
 
-		info := outSchema.NextNodes[nextNodeName]
+		require.Len(t, outSchema.NextNodes[nextNodeName], 1)
+		info := outSchema.NextNodes[nextNodeName][0]
 		require.Equal(t, "R1", info.Name)
 
 		if i >= 80 {
@@ -439,3 +446,737 @@ func TestBuildKG(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildKGCancelled(t *testing.T) {
+	logging.SetDefaultConfig(logging.GenerateTestConfig(t))
+
+	database, err := metadata.CreateDatabase(metadata.GenerateTestConfig())
+	require.Nil(t, err)
+
+	setting := KGSetting{
+		GetMetadataDatabase: func() *gorm.DB {
+			return database
+		},
+		Logger: logging.NewLogger(),
+	}
+
+	var buildCountBefore int64
+	err = database.Model(&metadata.Build{}).Count(&buildCountBefore).Error
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = buildKG(&setting, ctx, &KGBuildConfig{
+		ExtractorIDList: []uint{1},
+		Desc:            "TestBuildCancelled",
+	})
+	require.NotNil(t, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	var buildCountAfter int64
+	err = database.Model(&metadata.Build{}).Count(&buildCountAfter).Error
+	require.Nil(t, err)
+	assert.Equal(t, buildCountBefore, buildCountAfter)
+}
+
+/*
+seedChainCorpus 构造一个链式语料：size 个实体名，每个实体名由 2 个抽取器各抽取一次，相邻实体之间有关系 R，
+每个句子来自同一个文件。返回参与构建的 Extractor.ID 列表。
+*/
+func seedChainCorpus(tb testing.TB, database *gorm.DB, size int) []uint {
+	hash := md5.Sum([]byte(fmt.Sprintf("Chain%d", size)))
+	file := metadata.File{
+		Type: "txt",
+		URL:  fmt.Sprintf("/Chain%d", size),
+		Name: fmt.Sprintf("Chain%d", size),
+		Hash: hash[:],
+	}
+	require.Nil(tb, database.Create(&file).Error)
+
+	text := make([]metadata.Text, size)
+	for i := 0; i < size; i++ {
+		text[i] = metadata.Text{
+			Content: fmt.Sprintf("ChainText%d", i),
+			FileID:  &file.ID,
+		}
+	}
+	require.Nil(tb, database.CreateInBatches(&text, 512).Error)
+
+	extractor := make([]metadata.Extractor, 2)
+	for i := 0; i < len(extractor); i++ {
+		extractor[i] = metadata.Extractor{
+			Name: fmt.Sprintf("ChainExtractor%d", i),
+			Type: metadata.ExtractorTypeModel,
+		}
+	}
+	require.Nil(tb, database.Create(&extractor).Error)
+
+	entity := make([]metadata.Entity, 0, size*len(extractor))
+	for j := 0; j < len(extractor); j++ {
+		for i := 0; i < size; i++ {
+			entity = append(entity, metadata.Entity{
+				Name:        fmt.Sprintf("Chain%d-Node%d", size, i),
+				Type:        metadata.EntityTypeAdd,
+				ExtractorID: extractor[j].ID,
+				TextID:      &text[i].ID,
+			})
+		}
+	}
+	require.Nil(tb, database.CreateInBatches(&entity, 512).Error)
+
+	relation := make([]metadata.Relation, 0, (size-1)*len(extractor))
+	for j := 0; j < len(extractor); j++ {
+		for i := 0; i+1 < size; i++ {
+			relation = append(relation, metadata.Relation{
+				Name:        "R",
+				Type:        metadata.EntityTypeAdd,
+				ExtractorID: extractor[j].ID,
+				TextID:      &text[i].ID,
+				HeadID:      entity[j*size+i].ID,
+				TailID:      entity[j*size+i+1].ID,
+			})
+		}
+	}
+	require.Nil(tb, database.CreateInBatches(&relation, 512).Error)
+
+	return []uint{extractor[0].ID, extractor[1].ID}
+}
+
+/*
+buildDigest 将一次构建的所有 Node 转换为与构建号无关的描述，BuildExtractor.ID 被替换为抽取器名，用于比较两次构建的结果。
+*/
+func buildDigest(t *testing.T, database *gorm.DB, buildID uint) map[string]string {
+	var snapshots []metadata.BuildExtractor
+	require.Nil(t, database.Where(&metadata.BuildExtractor{BuildID: buildID}).Find(&snapshots).Error)
+
+	snapshotName := make(map[uint]string, len(snapshots))
+	for _, snapshot := range snapshots {
+		snapshotName[snapshot.ID] = snapshot.Name
+	}
+
+	names := func(ids []uint) []string {
+		ret := make([]string, 0, len(ids))
+		for _, id := range ids {
+			ret = append(ret, snapshotName[id])
+		}
+		sort.Strings(ret)
+		return ret
+	}
+
+	var nodes []metadata.Node
+	require.Nil(t, database.Where(&metadata.Node{BuildID: buildID}).Find(&nodes).Error)
+
+	ret := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		var source metadata.SchemaNodeSource
+		require.Nil(t, json.Unmarshal([]byte(node.SourceJSON), &source))
+
+		var out metadata.SchemaNodeOut
+		require.Nil(t, json.Unmarshal([]byte(node.OutJSON), &out))
+
+		type edge struct {
+			Name       string
+			Files      []metadata.FileInfo
+			Extractors []string
+		}
+		edges := make(map[string][]edge, len(out.NextNodes))
+		for tail, infos := range out.NextNodes {
+			for _, info := range infos {
+				edges[tail] = append(edges[tail], edge{
+					Name:       info.Name,
+					Files:      info.Files,
+					Extractors: names(info.Extractors),
+				})
+			}
+		}
+
+		digest, err := json.Marshal(map[string]interface{}{
+			"files":      source.Files,
+			"extractors": names(source.Extractors),
+			"out":        edges,
+		})
+		require.Nil(t, err)
+
+		ret[node.Name] = string(digest)
+	}
+
+	return ret
+}
+
+func TestBuildKGIncremental(t *testing.T) {
+	logging.SetDefaultConfig(logging.GenerateTestConfig(t))
+
+	database, err := metadata.CreateDatabase(metadata.GenerateTestConfig())
+	require.Nil(t, err)
+
+	setting := KGSetting{
+		GetMetadataDatabase: func() *gorm.DB {
+			return database
+		},
+		Logger: logging.NewLogger(),
+	}
+
+	const size = 30
+	nodeName := func(i int) string {
+		return fmt.Sprintf("Chain%d-Node%d", size, i)
+	}
+
+	extractorIDList := seedChainCorpus(t, database, size)
+
+	base, err := buildKG(&setting, context.TODO(), &KGBuildConfig{
+		ExtractorIDList: extractorIDList,
+		Desc:            "TestBuildIncrementalBase",
+	})
+	require.Nil(t, err)
+
+	/*
+		人为干预：
+			ADD --> NewNode
+			ADD --> Node5 --R--> NewNode
+			DEL --> Node10
+	*/
+	intervention := metadata.Extractor{
+		Name: "ChainIntervention",
+		Type: metadata.ExtractorTypeHumanIntervention,
+	}
+	require.Nil(t, database.Create(&intervention).Error)
+
+	newName := fmt.Sprintf("Chain%d-NewNode", size)
+	entities := []metadata.Entity{
+		{Name: newName, Type: metadata.EntityTypeAdd, ExtractorID: intervention.ID},
+		{Name: nodeName(5), Type: metadata.EntityTypeTmp, ExtractorID: intervention.ID},
+		{Name: nodeName(10), Type: metadata.EntityTypeDel, ExtractorID: intervention.ID},
+	}
+	require.Nil(t, database.Create(&entities).Error)
+	require.Nil(t, database.Create(&metadata.Relation{
+		Name:        "R",
+		Type:        metadata.EntityTypeAdd,
+		ExtractorID: intervention.ID,
+		HeadID:      entities[1].ID,
+		TailID:      entities[0].ID,
+	}).Error)
+
+	extractorIDList = append(extractorIDList, intervention.ID)
+
+	incremental, err := buildKG(&setting, context.TODO(), &KGBuildConfig{
+		ExtractorIDList: extractorIDList,
+		Desc:            "TestBuildIncremental",
+		BaseBuildID:     base.BuildID,
+	})
+	require.Nil(t, err)
+
+	full, err := buildKG(&setting, context.TODO(), &KGBuildConfig{
+		ExtractorIDList: extractorIDList,
+		Desc:            "TestBuildIncrementalFull",
+	})
+	require.Nil(t, err)
+
+	assert.Equal(t, full.SelectedEntities, incremental.SelectedEntities)
+	assert.Equal(t, full.DeletedEntities, incremental.DeletedEntities)
+
+	// Node5（新增出边）、Node9（出边指向被删除的 Node10）、Node10（被删除）、NewNode（新增）需要重新构建
+	assert.Equal(t, size-3, incremental.ReusedNodes)
+	assert.Equal(t, buildDigest(t, database, full.BuildID), buildDigest(t, database, incremental.BuildID))
+
+	var build metadata.Build
+	require.Nil(t, database.Take(&build, incremental.BuildID).Error)
+	require.NotNil(t, build.BaseBuildID)
+	assert.Equal(t, base.BuildID, *build.BaseBuildID)
+}
+
+func TestBuildKGMultiEdge(t *testing.T) {
+	logging.SetDefaultConfig(logging.GenerateTestConfig(t))
+
+	database, err := metadata.CreateDatabase(metadata.GenerateTestConfig())
+	require.Nil(t, err)
+
+	setting := KGSetting{
+		GetMetadataDatabase: func() *gorm.DB {
+			return database
+		},
+		Logger: logging.NewLogger(),
+	}
+
+	extractor := metadata.Extractor{
+		Name: "MultiEdgeExtractor",
+		Type: metadata.ExtractorTypeModel,
+	}
+	require.Nil(t, database.Create(&extractor).Error)
+
+	entity := []metadata.Entity{
+		{Name: "MultiEdge-A", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID},
+		{Name: "MultiEdge-B", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID},
+	}
+	require.Nil(t, database.Create(&entity).Error)
+
+	/*
+		A --创立--> B （2次）
+		A --董事长--> B （1次）
+	*/
+	relation := []metadata.Relation{
+		{Name: "创立", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID, HeadID: entity[0].ID, TailID: entity[1].ID},
+		{Name: "创立", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID, HeadID: entity[0].ID, TailID: entity[1].ID},
+		{Name: "董事长", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID, HeadID: entity[0].ID, TailID: entity[1].ID},
+	}
+	require.Nil(t, database.Create(&relation).Error)
+
+	edgeNames := func(resolver RelationResolver) []string {
+		res, err := buildKG(&setting, context.TODO(), &KGBuildConfig{
+			ExtractorIDList:  []uint{extractor.ID},
+			Desc:             "TestBuildKGMultiEdge",
+			RelationResolver: resolver,
+		})
+		require.Nil(t, err)
+
+		var node metadata.Node
+		require.Nil(t, database.Where(metadata.Node{BuildID: res.BuildID, Name: "MultiEdge-A"}).Take(&node).Error)
+
+		var out metadata.SchemaNodeOut
+		require.Nil(t, json.Unmarshal([]byte(node.OutJSON), &out))
+
+		ret := make([]string, 0)
+		for _, info := range out.NextNodes["MultiEdge-B"] {
+			ret = append(ret, info.Name)
+		}
+
+		_, relationCSV, err := transKGToCSV(&setting, res.BuildID)
+		require.Nil(t, err)
+		for _, name := range ret {
+			assert.Contains(t, string(relationCSV), fmt.Sprintf("%d,MultiEdge-A,%s,MultiEdge-B,", res.BuildID, name))
+		}
+
+		return ret
+	}
+
+	assert.Equal(t, []string{"创立"}, edgeNames(nil))
+	assert.Equal(t, []string{"创立", "董事长"}, edgeNames(&keepAllResolver{}))
+}
+
+func TestBuildKGAlias(t *testing.T) {
+	logging.SetDefaultConfig(logging.GenerateTestConfig(t))
+
+	database, err := metadata.CreateDatabase(metadata.GenerateTestConfig())
+	require.Nil(t, err)
+
+	setting := KGSetting{
+		GetMetadataDatabase: func() *gorm.DB {
+			return database
+		},
+		Logger: logging.NewLogger(),
+	}
+
+	extractor := metadata.Extractor{
+		Name: "AliasExtractor",
+		Type: metadata.ExtractorTypeModel,
+	}
+	require.Nil(t, database.Create(&extractor).Error)
+
+	/*
+		学生 --就读--> 北航
+		北京航空航天大学 --位于--> 北京
+		别名：北航 -> 北京航空航天大学
+	*/
+	entity := []metadata.Entity{
+		{Name: "Alias-学生", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID},
+		{Name: "Alias-北航", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID},
+		{Name: "Alias-北京航空航天大学", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID},
+		{Name: "Alias-北京", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID},
+	}
+	require.Nil(t, database.Create(&entity).Error)
+
+	relation := []metadata.Relation{
+		{Name: "就读", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID, HeadID: entity[0].ID, TailID: entity[1].ID},
+		{Name: "位于", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID, HeadID: entity[2].ID, TailID: entity[3].ID},
+	}
+	require.Nil(t, database.Create(&relation).Error)
+
+	require.Nil(t, database.Where("alias = ?", "Alias-北航").Delete(&metadata.EntityAlias{}).Error)
+
+	alias := metadata.EntityAlias{CanonicalName: "Alias-北京航空航天大学", Alias: "Alias-北航"}
+	require.Nil(t, database.Create(&alias).Error)
+
+	base, err := buildKG(&setting, context.TODO(), &KGBuildConfig{
+		ExtractorIDList: []uint{extractor.ID},
+		Desc:            "TestBuildKGAlias",
+	})
+	require.Nil(t, err)
+
+	assert.Equal(t, map[string]struct{}{
+		"Alias-学生":       {},
+		"Alias-北京航空航天大学": {},
+		"Alias-北京":       {},
+	}, base.SelectedEntities)
+
+	var node metadata.Node
+	require.Nil(t, database.Where(metadata.Node{BuildID: base.BuildID, Name: "Alias-北京航空航天大学"}).Take(&node).Error)
+
+	var source metadata.SchemaNodeSource
+	require.Nil(t, json.Unmarshal([]byte(node.SourceJSON), &source))
+	assert.Equal(t, []string{"Alias-北航"}, source.Aliases)
+
+	var student metadata.Node
+	require.Nil(t, database.Where(metadata.Node{BuildID: base.BuildID, Name: "Alias-学生"}).Take(&student).Error)
+
+	var out metadata.SchemaNodeOut
+	require.Nil(t, json.Unmarshal([]byte(student.OutJSON), &out))
+	require.Len(t, out.NextNodes["Alias-北京航空航天大学"], 1)
+	assert.Equal(t, "就读", out.NextNodes["Alias-北京航空航天大学"][0].Name)
+
+	// 删除别名后增量构建，北航重新成为独立的 Node
+	require.Nil(t, database.Delete(&alias).Error)
+
+	incremental, err := buildKG(&setting, context.TODO(), &KGBuildConfig{
+		ExtractorIDList: []uint{extractor.ID},
+		Desc:            "TestBuildKGAliasIncremental",
+		BaseBuildID:     base.BuildID,
+	})
+	require.Nil(t, err)
+
+	full, err := buildKG(&setting, context.TODO(), &KGBuildConfig{
+		ExtractorIDList: []uint{extractor.ID},
+		Desc:            "TestBuildKGAliasFull",
+	})
+	require.Nil(t, err)
+
+	assert.Contains(t, full.SelectedEntities, "Alias-北航")
+	assert.Equal(t, full.SelectedEntities, incremental.SelectedEntities)
+	assert.Equal(t, buildDigest(t, database, full.BuildID), buildDigest(t, database, incremental.BuildID))
+}
+
+func TestBuildKGCategory(t *testing.T) {
+	logging.SetDefaultConfig(logging.GenerateTestConfig(t))
+
+	database, err := metadata.CreateDatabase(metadata.GenerateTestConfig())
+	require.Nil(t, err)
+
+	setting := KGSetting{
+		GetMetadataDatabase: func() *gorm.DB {
+			return database
+		},
+		Logger: logging.NewLogger(),
+	}
+
+	extractor := []metadata.Extractor{
+		{Name: "CategoryModel", Type: metadata.ExtractorTypeModel},
+		{Name: "CategoryIntervention", Type: metadata.ExtractorTypeHumanIntervention},
+	}
+	require.Nil(t, database.Create(&extractor).Error)
+
+	/*
+		Category-A：person 2 次，organization 1 次 --> person
+		Category-B：location 1 次，人为干预 organization --> organization
+		Category-C：没有类别 --> 空
+	*/
+	entity := []metadata.Entity{
+		{Name: "Category-A", Type: metadata.EntityTypeAdd, Category: metadata.EntityCategoryPerson, ExtractorID: extractor[0].ID},
+		{Name: "Category-A", Type: metadata.EntityTypeAdd, Category: metadata.EntityCategoryOrganization, ExtractorID: extractor[0].ID},
+		{Name: "Category-A", Type: metadata.EntityTypeAdd, Category: metadata.EntityCategoryPerson, ExtractorID: extractor[0].ID},
+		{Name: "Category-B", Type: metadata.EntityTypeAdd, Category: metadata.EntityCategoryLocation, ExtractorID: extractor[0].ID},
+		{Name: "Category-B", Type: metadata.EntityTypeAdd, Category: metadata.EntityCategoryOrganization, ExtractorID: extractor[1].ID},
+		{Name: "Category-C", Type: metadata.EntityTypeAdd, ExtractorID: extractor[0].ID},
+	}
+	require.Nil(t, database.Create(&entity).Error)
+
+	res, err := buildKG(&setting, context.TODO(), &KGBuildConfig{
+		ExtractorIDList: []uint{extractor[0].ID, extractor[1].ID},
+		Desc:            "TestBuildKGCategory",
+	})
+	require.Nil(t, err)
+
+	var nodes []metadata.Node
+	require.Nil(t, database.Where("build_id = ?", res.BuildID).Order("name").Find(&nodes).Error)
+
+	categories := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		categories[node.Name] = node.Category
+	}
+	assert.Equal(t, map[string]string{
+		"Category-A": metadata.EntityCategoryPerson,
+		"Category-B": metadata.EntityCategoryOrganization,
+		"Category-C": "",
+	}, categories)
+
+	entityCSV, _, err := transKGToCSV(&setting, res.BuildID)
+	require.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(entityCSV), "version,name,category,source"))
+	assert.Contains(t, string(entityCSV), fmt.Sprintf("%d,Category-A,person,", res.BuildID))
+}
+
+func TestBuildKGConfidence(t *testing.T) {
+	logging.SetDefaultConfig(logging.GenerateTestConfig(t))
+
+	database, err := metadata.CreateDatabase(metadata.GenerateTestConfig())
+	require.Nil(t, err)
+
+	setting := KGSetting{
+		GetMetadataDatabase: func() *gorm.DB {
+			return database
+		},
+		Logger: logging.NewLogger(),
+	}
+
+	confidence := func(c float64) *float64 {
+		return &c
+	}
+
+	extractor := metadata.Extractor{
+		Name: "ConfidenceExtractor",
+		Type: metadata.ExtractorTypeModel,
+	}
+	require.Nil(t, database.Create(&extractor).Error)
+
+	/*
+		A --R(0.5)--> B
+		A --R(0.5)--> B
+		A --R(0.2)--> C
+		D(0.1)
+	*/
+	entity := []metadata.Entity{
+		{Name: "Confidence-A", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID},
+		{Name: "Confidence-B", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID, Confidence: confidence(0.9)},
+		{Name: "Confidence-C", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID},
+		{Name: "Confidence-D", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID, Confidence: confidence(0.1)},
+	}
+	require.Nil(t, database.Create(&entity).Error)
+
+	relation := []metadata.Relation{
+		{Name: "R", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID, HeadID: entity[0].ID, TailID: entity[1].ID, Confidence: confidence(0.5)},
+		{Name: "R", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID, HeadID: entity[0].ID, TailID: entity[1].ID, Confidence: confidence(0.5)},
+		{Name: "R", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID, HeadID: entity[0].ID, TailID: entity[2].ID, Confidence: confidence(0.2)},
+	}
+	require.Nil(t, database.Create(&relation).Error)
+
+	edges := func(minConfidence float64) (map[string]struct{}, map[string]float64) {
+		res, err := buildKG(&setting, context.TODO(), &KGBuildConfig{
+			ExtractorIDList: []uint{extractor.ID},
+			Desc:            "TestBuildKGConfidence",
+			MinConfidence:   minConfidence,
+		})
+		require.Nil(t, err)
+
+		var build metadata.Build
+		require.Nil(t, database.Take(&build, res.BuildID).Error)
+		assert.Equal(t, minConfidence, build.MinConfidence)
+
+		var node metadata.Node
+		require.Nil(t, database.Where(metadata.Node{BuildID: res.BuildID, Name: "Confidence-A"}).Take(&node).Error)
+
+		var out metadata.SchemaNodeOut
+		require.Nil(t, json.Unmarshal([]byte(node.OutJSON), &out))
+
+		ret := make(map[string]float64)
+		for tail, infos := range out.NextNodes {
+			require.Len(t, infos, 1)
+			require.NotNil(t, infos[0].Confidence)
+			ret[tail] = *infos[0].Confidence
+		}
+
+		return res.SelectedEntities, ret
+	}
+
+	selected, confidences := edges(0)
+	assert.Len(t, selected, 4)
+	assert.Len(t, confidences, 2)
+	assert.InDelta(t, 0.75, confidences["Confidence-B"], 1e-9)
+	assert.InDelta(t, 0.2, confidences["Confidence-C"], 1e-9)
+
+	selected, confidences = edges(0.3)
+	assert.Equal(t, map[string]struct{}{
+		"Confidence-A": {},
+		"Confidence-B": {},
+		"Confidence-C": {},
+	}, selected)
+	assert.Len(t, confidences, 1)
+	assert.InDelta(t, 0.75, confidences["Confidence-B"], 1e-9)
+}
+
+/*
+BenchmarkBuildKG 测试构建的耗时，并通过 queries/op 报告每次构建访问数据库的次数。
+loader=bulk 为批量读取的构建，queries/op 只随 loadBatchSize 与 nodeBatchSize 增长；
+loader=per-file 为对照，与批量读取之前的构建相同，完整地创建构建和快照、逐个节点查询实体与出边、逐条关系查询尾实体、逐个实体查询 Text 与 File 并逐个写入 Node，
+queries/op 随实体数线性增长，两者的 ns/op 可以直接比较。
+*/
+func BenchmarkBuildKG(b *testing.B) {
+	logging.SetDefaultConfig(logging.GenerateTestConfig(nil))
+
+	for _, size := range []int{100, 1000} {
+		database, err := metadata.CreateDatabase(metadata.GenerateTestConfig())
+		require.Nil(b, err)
+		database.Logger = database.Logger.LogMode(logger.Silent)
+
+		extractorIDList := seedChainCorpus(b, database, size)
+
+		var queries int64
+		count := func(*gorm.DB) {
+			atomic.AddInt64(&queries, 1)
+		}
+		require.Nil(b, database.Callback().Query().After("gorm:query").Register("benchmark:count_query", count))
+		require.Nil(b, database.Callback().Row().After("gorm:row").Register("benchmark:count_row", count))
+		require.Nil(b, database.Callback().Create().After("gorm:create").Register("benchmark:count_create", count))
+
+		setting := KGSetting{
+			GetMetadataDatabase: func() *gorm.DB {
+				return database
+			},
+			Logger: logging.NewLogger(),
+		}
+
+		b.Run(fmt.Sprintf("entities=%d/loader=bulk", size), func(b *testing.B) {
+			atomic.StoreInt64(&queries, 0)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, err := buildKG(&setting, context.TODO(), &KGBuildConfig{
+					ExtractorIDList: extractorIDList,
+					Desc:            "BenchmarkBuild",
+				})
+				require.Nil(b, err)
+			}
+
+			b.StopTimer()
+			b.ReportMetric(float64(atomic.LoadInt64(&queries))/float64(b.N), "queries/op")
+		})
+
+		b.Run(fmt.Sprintf("entities=%d/loader=per-file", size), func(b *testing.B) {
+			atomic.StoreInt64(&queries, 0)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				require.Nil(b, perFileBuild(database, extractorIDList))
+			}
+
+			b.StopTimer()
+			b.ReportMetric(float64(atomic.LoadInt64(&queries))/float64(b.N), "queries/op")
+		})
+	}
+}
+
+/*
+perFileBuild 按批量读取之前的构建方式逐条查询并写入，只用于 BenchmarkBuildKG 的对照：
+逐个节点查询实体和出边，每条出边查询尾实体和 Text，每个实体查询 Text，每个来源文件查询一次 File，并逐个写入 Node。
+*/
+func perFileBuild(database *gorm.DB, extractorIDList []uint) error {
+	return database.Transaction(func(tx *gorm.DB) error {
+		build := metadata.Build{Desc: "BenchmarkBuildPerFile"}
+		if err := tx.Create(&build).Error; err != nil {
+			return err
+		}
+
+		var extractors []metadata.Extractor
+		if err := tx.Find(&extractors, extractorIDList).Error; err != nil {
+			return err
+		}
+		snapshots := make([]metadata.BuildExtractor, 0, len(extractors))
+		for _, extractor := range extractors {
+			snapshots = append(snapshots, metadata.BuildExtractor{BuildID: build.ID, Name: extractor.Name, Type: extractor.Type})
+		}
+		if err := tx.Create(&snapshots).Error; err != nil {
+			return err
+		}
+
+		var names []string
+		err := tx.Model(&metadata.Entity{}).
+			Distinct("name").
+			Where("extractor_id in ? and type != ?", extractorIDList, metadata.EntityTypeTmp).
+			Pluck("name", &names).Error
+		if err != nil {
+			return err
+		}
+
+		fileIDOfText := func(textID *uint) (*uint, error) {
+			if textID == nil {
+				return nil, nil
+			}
+
+			var text metadata.Text
+			if err := tx.Take(&text, *textID).Error; err != nil {
+				return nil, err
+			}
+			return text.FileID, nil
+		}
+		fileInfo := func(fileID uint) (metadata.FileInfo, error) {
+			var file metadata.File
+			if err := tx.Take(&file, fileID).Error; err != nil {
+				return metadata.FileInfo{}, err
+			}
+			return metadata.FileInfo{FileID: file.ID, FileName: file.Name + "." + file.Type, FileType: file.Type}, nil
+		}
+
+		for _, name := range names {
+			var entities []metadata.Entity
+			if err := tx.Where("name = ? and extractor_id in ?", name, extractorIDList).Find(&entities).Error; err != nil {
+				return err
+			}
+
+			ids := make([]uint, 0, len(entities))
+			for _, entity := range entities {
+				ids = append(ids, entity.ID)
+			}
+
+			// 出边：每条 Relation 查询尾实体与来源文件
+			var relations []metadata.Relation
+			if err := tx.Where("head_id in ?", ids).Find(&relations).Error; err != nil {
+				return err
+			}
+
+			out := metadata.SchemaNodeOut{NextNodes: make(map[string][]metadata.RelationInfo)}
+			for _, relation := range relations {
+				var tail metadata.Entity
+				if err := tx.Take(&tail, relation.TailID).Error; err != nil {
+					return err
+				}
+
+				fileID, err := fileIDOfText(relation.TextID)
+				if err != nil {
+					return err
+				}
+
+				info := metadata.RelationInfo{Name: relation.Name, Files: make([]metadata.FileInfo, 0)}
+				if fileID != nil {
+					file, err := fileInfo(*fileID)
+					if err != nil {
+						return err
+					}
+					info.Files = append(info.Files, file)
+				}
+				out.NextNodes[tail.Name] = append(out.NextNodes[tail.Name], info)
+			}
+
+			// 溯源：每个实体查询 Text，每个节点中首次出现的 File 再查询一次
+			source := metadata.SchemaNodeSource{Files: make([]metadata.FileInfo, 0)}
+			checked := make(map[uint]struct{})
+			for _, entity := range entities {
+				fileID, err := fileIDOfText(entity.TextID)
+				if err != nil {
+					return err
+				}
+				if fileID == nil {
+					continue
+				}
+				if _, ok := checked[*fileID]; ok {
+					continue
+				}
+
+				file, err := fileInfo(*fileID)
+				if err != nil {
+					return err
+				}
+				checked[*fileID] = struct{}{}
+				source.Files = append(source.Files, file)
+			}
+
+			node := metadata.Node{
+				BuildID:    build.ID,
+				Name:       name,
+				OutJSON:    out.ToJSON(),
+				SourceJSON: source.ToJSON(),
+			}
+			if err := tx.Create(&node).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -4,17 +4,29 @@ import (
 	"autograph-backend-controller/repository/metadata"
 	"autograph-backend-controller/utils"
 	"context"
-	"fmt"
 	"gorm.io/gorm"
+	"sort"
 	"time"
 )
 
+// nodeBatchSize 批量写入 Node 的大小
+const nodeBatchSize = 128
+
 type kgBuilder struct {
 	config  *KGBuildConfig
 	result  *KGBuildResult
 	ctx     context.Context
 	tx      *gorm.DB
 	setting *KGSetting
+
+	resolver          RelationResolver
+	humanIntervention map[uint]struct{}   // 人为干预的 Extractor.ID
+	aliases           map[string]string   // 别名 -> 规范名
+	aliasesOf         map[string][]string // 规范名 -> 别名
+
+	// 构建 Node 的进度
+	nodesTotal   int
+	nodesCreated int
 }
 
 func (b *kgBuilder) build(tx *gorm.DB) error {
@@ -24,6 +36,8 @@ func (b *kgBuilder) build(tx *gorm.DB) error {
 		b.result.FinishTime = time.Now()
 	}()
 
+	b.reportProgress(KGBuildStageSnapshot, 0, 1)
+
 	err := b.createBuild()
 	if err != nil {
 		return utils.WrapError(err, "create build fail")
@@ -34,12 +48,63 @@ func (b *kgBuilder) build(tx *gorm.DB) error {
 		return utils.WrapError(err, "create snapshot of extractor fail")
 	}
 
-	entities, err := b.collectEntities(humanInterventionExtractorIDList)
+	b.humanIntervention = make(map[uint]struct{}, len(humanInterventionExtractorIDList))
+	for _, id := range humanInterventionExtractorIDList {
+		b.humanIntervention[id] = struct{}{}
+	}
+
+	err = b.loadAliases()
 	if err != nil {
-		return utils.WrapError(err, "collect entities fail")
+		return utils.WrapError(err, "load aliases fail")
+	}
+
+	b.reportProgress(KGBuildStageSnapshot, 1, 1)
+
+	if b.config.BaseBuildID != 0 {
+		err = b.buildIncrementally(humanInterventionExtractorIDList)
+		if err != nil {
+			return utils.WrapErrorf(err, "build incrementally from build [%d] fail", b.config.BaseBuildID)
+		}
+
+		return nil
 	}
 
-	err = b.createNodes(entities)
+	return b.buildFully()
+}
+
+/*
+buildFully 读取所有实体和关系，重新计算全部 Node。
+*/
+func (b *kgBuilder) buildFully() error {
+	b.reportProgress(KGBuildStageCollectEntities, 0, 3)
+
+	entities, err := b.loadEntities()
+	if err != nil {
+		return utils.WrapError(err, "load entities fail")
+	}
+
+	b.reportProgress(KGBuildStageCollectEntities, 1, 3)
+
+	relations, err := b.loadRelations()
+	if err != nil {
+		return utils.WrapError(err, "load relations fail")
+	}
+
+	b.reportProgress(KGBuildStageCollectEntities, 2, 3)
+
+	files, err := b.loadFiles(entities, relations)
+	if err != nil {
+		return utils.WrapError(err, "load files fail")
+	}
+
+	b.reportProgress(KGBuildStageCollectEntities, 3, 3)
+
+	b.collectEntities(entities)
+
+	b.nodesTotal = len(b.result.SelectedEntities)
+	b.reportProgress(KGBuildStageCreateNodes, 0, b.nodesTotal)
+
+	err = b.createNodes(entities, relations, files)
 	if err != nil {
 		return utils.WrapError(err, "create nodes fail")
 	}
@@ -47,14 +112,32 @@ func (b *kgBuilder) build(tx *gorm.DB) error {
 	return nil
 }
 
+func (b *kgBuilder) reportProgress(stage KGBuildStage, finished, total int) {
+	if b.config.Progress != nil {
+		b.config.Progress(stage, finished, total)
+	}
+}
+
 ////////////// 构建的基本信息 /////////////////
 
 /*
 createBuild 创建一条构建记录，并填充 b.result 的 BuildID 和 StartTime 字段
 */
 func (b *kgBuilder) createBuild() error {
+	param, err := b.resolver.Param()
+	if err != nil {
+		return utils.WrapError(err, "get relation resolver param fail")
+	}
+
 	build := metadata.Build{
-		Desc: b.config.Desc,
+		Desc:                  b.config.Desc,
+		RelationResolver:      b.resolver.Name(),
+		RelationResolverParam: param,
+		MinConfidence:         b.config.MinConfidence,
+		Status:                metadata.BuildStatusDraft,
+	}
+	if b.config.BaseBuildID != 0 {
+		build.BaseBuildID = utils.UintToPtr(b.config.BaseBuildID)
 	}
 
 	if err := b.tx.Create(&build).Error; err != nil {
@@ -79,10 +162,11 @@ func (b *kgBuilder) createExtractorSnapshot() ([]uint, error) {
 	buildExtractors := make([]metadata.BuildExtractor, len(extractors))
 	for i := 0; i < len(buildExtractors); i++ {
 		buildExtractors[i] = metadata.BuildExtractor{
-			BuildID: b.result.BuildID,
-			Name:    extractors[i].Name,
-			Desc:    extractors[i].Desc,
-			Type:    extractors[i].Type,
+			BuildID:     b.result.BuildID,
+			Name:        extractors[i].Name,
+			Desc:        extractors[i].Desc,
+			Type:        extractors[i].Type,
+			ExtractorID: extractors[i].ID,
 		}
 	}
 
@@ -109,144 +193,104 @@ func (b *kgBuilder) createExtractorSnapshot() ([]uint, error) {
 ////////////// 确定要构建的 Node 的范围 //////////////
 
 /*
-collectDeletedEntities 获取被删除实体的集合，即收集所有 Type=Del 的 Entity 的 Name。
+collectEntities 获取所有未被删除的实体的集合：收集所有 Type!=Tmp 的 Entity 的规范名，然后排除人为干预中 Type=Del 的 Entity 的规范名。
+填充 b.result.DeletedEntities 和 b.result.SelectedEntities。
 */
-func (b *kgBuilder) collectDeletedEntities(humanInterventionExtractorIDList []uint) (del map[string]struct{}, err error) {
-	rows, err := b.tx.Model(&metadata.Entity{}).
-		Distinct("name").
-		Select("name").
-		Where("extractor_id in ? and type = ?", humanInterventionExtractorIDList, metadata.EntityTypeDel).
-		Rows()
-	if err != nil {
-		return nil, utils.WrapError(err, "select from database fail")
-	}
-	defer rows.Close()
-
-	del = make(map[string]struct{})
-	for rows.Next() {
-		var name string
-		err = rows.Scan(&name)
-		if err != nil {
-			return nil, utils.WrapError(err, "scan result fail")
+func (b *kgBuilder) collectEntities(entities []entityRow) {
+	del := make(map[string]struct{})
+	for i := 0; i < len(entities); i++ {
+		if entities[i].Type != metadata.EntityTypeDel {
+			continue
 		}
-		del[name] = struct{}{}
-	}
-
-	return del, nil
-}
 
-/*
-collectEntities 获取所有未被删除的实体的列表，收集所有 Entity 的 Name，然后排除 Type=Del 的 Entity 的 Name。
-填充 b.result.DeletedEntities 和 b.result.SelectedEntities。
-*/
-func (b *kgBuilder) collectEntities(humanInterventionExtractorIDList []uint) (map[string]struct{}, error) {
-	del, err := b.collectDeletedEntities(humanInterventionExtractorIDList)
-	if err != nil {
-		return nil, utils.WrapError(err, "collect deleted entities fail")
+		if _, ok := b.humanIntervention[entities[i].ExtractorID]; ok {
+			del[entities[i].Name] = struct{}{}
+		}
 	}
 
 	b.result.DeletedEntities = del
 	b.setting.Logger.Infof("deleted node set=%v", del)
 
-	rows, err := b.tx.Model(&metadata.Entity{}).
-		Distinct("name").
-		Select("name").
-		Where("extractor_id in ? and type != ?", b.config.ExtractorIDList, metadata.EntityTypeTmp).
-		Rows()
-	if err != nil {
-		return nil, utils.WrapError(err, "select entities from db fail")
-	}
-	defer rows.Close()
-
-	ret := make(map[string]struct{}, 0)
-	for rows.Next() {
-		var name string
-		err = rows.Scan(&name)
-		if err != nil {
-			return nil, utils.WrapError(err, "scan result fail")
+	ret := make(map[string]struct{})
+	for i := 0; i < len(entities); i++ {
+		if entities[i].Type == metadata.EntityTypeTmp {
+			continue
 		}
 
 		// 排除删除的实体
-		_, ok := del[name]
-		if ok {
+		if _, ok := del[entities[i].Name]; ok {
 			continue
 		}
 
-		ret[name] = struct{}{}
+		ret[entities[i].Name] = struct{}{}
 	}
 
 	b.result.SelectedEntities = ret
-	b.setting.Logger.Infof("selected node set(len=%d)=%v", len(ret), ret)
-
-	return ret, nil
+	b.setting.Logger.Infof("selected node set(len=%d)", len(ret))
 }
 
 ////////////// 构建 Node ///////////////
 
 /*
-getFileIDByTextID 通过 Text.ID 获取 File.ID。
+categoryForNode 通过构建 Node 的 Entity 列表，选出 Node 的类别：
+人为干预给出的类别优先，取最新的一条；否则取出现次数最多的类别，次数相同时取字典序最小的。没有类别时返回空。
 */
-func (b *kgBuilder) getFileIDByTextID(textID *uint) (*uint, error) {
-	if textID == nil {
-		return nil, nil
-	}
+func (b *kgBuilder) categoryForNode(entities []*entityRow) string {
+	var latest *entityRow
+	count := make(map[string]int)
 
-	var text metadata.Text
-	if err := b.tx.Take(&text, *textID).Error; err != nil {
-		return nil, utils.WrapError(err, fmt.Sprintf("select text with id=[%d] fail", *textID))
+	for _, entity := range entities {
+		if len(entity.Category) == 0 || entity.Type == metadata.EntityTypeDel {
+			continue
+		}
+
+		if _, ok := b.humanIntervention[entity.ExtractorID]; ok {
+			if latest == nil || entity.ID > latest.ID {
+				latest = entity
+			}
+			continue
+		}
+
+		count[entity.Category]++
 	}
 
-	return text.FileID, nil
-}
+	if latest != nil {
+		return latest.Category
+	}
 
-func (b *kgBuilder) getFileNameAndTypeByID(fileID uint) (string, string, error) {
-	var file metadata.File
-	if err := b.tx.Take(&file, fileID).Error; err != nil {
-		return "", "", utils.WrapError(err, fmt.Sprintf("select file with id=[%d] fail", fileID))
+	ret := ""
+	for category, c := range count {
+		if c > count[ret] || (c == count[ret] && category < ret) {
+			ret = category
+		}
 	}
 
-	return fmt.Sprintf("%s.%s", file.Name, file.Type), file.Type, nil
+	return ret
 }
 
 /*
-buildSourceForNode 通过构建 Node 的 Entity 列表，构建 Node 的来源信息
+buildSourceForNode 通过构建 Node 的 Entity 列表，构建 Node 的来源信息，包括归并到该 Node 的别名
 */
-func (b *kgBuilder) buildSourceForNode(entities []metadata.Entity) (metadata.SchemaNodeSource, error) {
+func (b *kgBuilder) buildSourceForNode(entities []*entityRow, files map[uint]metadata.FileInfo) metadata.SchemaNodeSource {
 	ret := metadata.SchemaNodeSource{}
 
 	// files
 
-	files := make([]metadata.FileInfo, 0)
+	fileInfos := make([]metadata.FileInfo, 0)
 	checked := make(map[uint]struct{})
 
-	for i := 0; i < len(entities); i++ {
-		textID := entities[i].TextID
-
-		fileID, err := b.getFileIDByTextID(textID)
-		if err != nil {
-			return ret, utils.WrapError(err, "get fileID with textID fail")
-		}
-
-		if fileID == nil {
+	for _, entity := range entities {
+		if entity.FileID == nil {
 			continue
 		}
 
-		_, ok := checked[*fileID]
-		if ok {
+		fileID := *entity.FileID
+		if _, ok := checked[fileID]; ok {
 			continue
 		}
 
-		fileName, fileType, err := b.getFileNameAndTypeByID(*fileID)
-		if err != nil {
-			return ret, utils.WrapError(err, "get fileName with fileID fail")
-		}
-
-		checked[*fileID] = struct{}{}
-		files = append(files, metadata.FileInfo{
-			FileID:   *fileID,
-			FileName: fileName,
-			FileType: fileType,
-		})
+		checked[fileID] = struct{}{}
+		fileInfos = append(fileInfos, files[fileID])
 	}
 
 	// extractors
@@ -254,11 +298,10 @@ func (b *kgBuilder) buildSourceForNode(entities []metadata.Entity) (metadata.Sch
 	extractors := make([]uint, 0)
 	checked = make(map[uint]struct{})
 
-	for i := 0; i < len(entities); i++ {
-		extractorID := entities[i].ExtractorID
+	for _, entity := range entities {
+		extractorID := entity.ExtractorID
 
-		_, ok := checked[extractorID]
-		if ok {
+		if _, ok := checked[extractorID]; ok {
 			continue
 		}
 
@@ -266,225 +309,292 @@ func (b *kgBuilder) buildSourceForNode(entities []metadata.Entity) (metadata.Sch
 		extractors = append(extractors, b.result.ExtractorSnapshotMap[extractorID])
 	}
 
-	ret.Files = files
+	ret.Files = fileInfos
 	ret.Extractors = extractors
+	ret.Aliases = aliasesForNode(entities)
 
-	return ret, nil
+	return ret
 }
 
-type relationTuple struct {
-	files      []*uint
-	extractors []uint
-	isDel      bool
-}
+/*
+relationInfo 统计同一对头尾实体之间的所有 Relation，key 为关系名。
+*/
+type relationInfo map[string][]RelationRecord
 
-type relationInfo map[string]*relationTuple
+func (i relationInfo) add(relationName string, record RelationRecord) {
+	i[relationName] = append(i[relationName], record)
+}
 
-func (i relationInfo) add(relationName string, file *uint, extractor uint, isAdd bool) {
-	val, ok := i[relationName]
-	if !ok {
-		val = &relationTuple{}
-		i[relationName] = val
+/*
+candidates 返回按关系名升序排列的候选关系。
+*/
+func (i relationInfo) candidates() []RelationCandidate {
+	ret := make([]RelationCandidate, 0, len(i))
+	for name, records := range i {
+		ret = append(ret, RelationCandidate{
+			Name:    name,
+			Records: records,
+		})
 	}
-
-	val.files = append(val.files, file)
-	val.isDel = val.isDel || !isAdd
-	val.extractors = append(val.extractors, extractor)
+	sort.Slice(ret, func(a, b int) bool { return ret[a].Name < ret[b].Name })
+	return ret
 }
 
-func (i relationInfo) maxCountNotDeleteName() string {
-	maxCount := 0
-	maxCountName := ""
-
-	for name, tuple := range i {
-
-		if tuple.isDel {
-			continue
-		}
+func (i relationInfo) files(relationName string) map[uint]struct{} {
+	raw := i[relationName]
+	ret := make(map[uint]struct{}, len(raw))
 
-		if len(tuple.files) > maxCount {
-			maxCount = len(tuple.files)
-			maxCountName = name
+	for _, record := range raw {
+		if record.FileID != nil {
+			ret[*record.FileID] = struct{}{}
 		}
 	}
-	return maxCountName
+
+	return ret
 }
 
-func (i relationInfo) files(relationName string) map[uint]struct{} {
-	raw := i[relationName].files
+func (i relationInfo) texts(relationName string) map[uint]struct{} {
+	raw := i[relationName]
 	ret := make(map[uint]struct{}, len(raw))
 
-	for _, ptr := range raw {
-		if ptr != nil {
-			ret[*ptr] = struct{}{}
+	for _, record := range raw {
+		if record.TextID != nil {
+			ret[*record.TextID] = struct{}{}
 		}
 	}
 
 	return ret
 }
 
+/*
+confidence 聚合关系名为 relationName 的所有增加记录的置信度：各记录视为独立的证据，1 - Π(1 - c)。
+*/
+func (i relationInfo) confidence(relationName string) float64 {
+	disbelief := 1.0
+	for _, record := range i[relationName] {
+		if record.IsAdd {
+			disbelief *= 1 - record.Confidence
+		}
+	}
+	return 1 - disbelief
+}
+
 func (i relationInfo) extractors(relationName string) map[uint]struct{} {
-	target := i[relationName]
-	ret := make(map[uint]struct{}, len(target.extractors))
-	for _, eid := range target.extractors {
-		ret[eid] = struct{}{}
+	raw := i[relationName]
+	ret := make(map[uint]struct{}, len(raw))
+	for _, record := range raw {
+		ret[record.ExtractorID] = struct{}{}
 	}
 	return ret
 }
 
 /*
-collectRelations 统计某个实体的所有出（入）边。返回值的 key 为相邻节点的名字，value为与改变匹配的 Relation 的统计信息。
+collectRelations 统计所有节点的出边。返回值的 key 为头节点的名字，value 的 key 为相邻节点的名字，value 的 value 为与该边匹配的 Relation 的统计信息。
 */
-func (b *kgBuilder) collectRelations(relations []metadata.Relation, nodeIDs []uint) (map[string]relationInfo, error) {
-	ret := make(map[string]relationInfo)
+func (b *kgBuilder) collectRelations(entities []entityRow, relations []relationRow) (map[string]map[string]relationInfo, error) {
+	entityName := make(map[uint]string, len(entities))
+	for i := 0; i < len(entities); i++ {
+		entityName[entities[i].ID] = entities[i].Name
+	}
+
+	ret := make(map[string]map[string]relationInfo)
 
 	for i := 0; i < len(relations); i++ {
+		if i%nodeBatchSize == 0 {
+			if err := b.ctx.Err(); err != nil {
+				return nil, utils.WrapError(err, "build cancelled")
+			}
+		}
+
+		relation := &relations[i]
 
-		var targetEntity metadata.Entity
-		if err := b.tx.Take(&targetEntity, nodeIDs[i]).Error; err != nil {
-			return nil, utils.WrapError(err, fmt.Sprintf("select entity with id=[%d] fail", nodeIDs[i]))
+		headName := entityName[relation.HeadID]
+		if _, ok := b.result.SelectedEntities[headName]; !ok {
+			continue
 		}
 
-		_, ok := b.result.SelectedEntities[targetEntity.Name]
-		if !ok {
+		if _, ok := b.result.SelectedEntities[relation.TailName]; !ok {
 			continue
 		}
 
-		info, ok := ret[targetEntity.Name]
+		out, ok := ret[headName]
 		if !ok {
-			info = make(relationInfo)
-			ret[targetEntity.Name] = info
+			out = make(map[string]relationInfo)
+			ret[headName] = out
 		}
 
-		fileID, err := b.getFileIDByTextID(relations[i].TextID)
-		if err != nil {
-			return nil, utils.WrapError(err, "get fileID with textID fail")
+		info, ok := out[relation.TailName]
+		if !ok {
+			info = make(relationInfo)
+			out[relation.TailName] = info
 		}
 
-		extractorID := b.result.ExtractorSnapshotMap[relations[i].ExtractorID]
+		_, isIntervention := b.humanIntervention[relation.ExtractorID]
+
+		confidence := 1.0
+		if relation.Confidence != nil {
+			confidence = *relation.Confidence
+		}
 
-		info.add(relations[i].Name, fileID, extractorID, relations[i].Type == metadata.EntityTypeAdd)
+		info.add(relation.Name, RelationRecord{
+			RelationID:     relation.ID,
+			ExtractorID:    relation.ExtractorID,
+			TextID:         relation.TextID,
+			FileID:         relation.FileID,
+			IsAdd:          relation.Type == metadata.EntityTypeAdd,
+			IsIntervention: isIntervention,
+			Confidence:     confidence,
+			CreatedAt:      relation.CreatedAt,
+		})
 	}
 
 	return ret, nil
 }
 
 /*
-buildOutForNode 通过同一个 Node 的 Entity 列表，构建出边信息。
+buildOutForNode 通过同一个 Node 的出边统计信息，构建出边信息。每对头尾实体之间保留哪些关系名由 b.resolver 决定。
 */
-func (b *kgBuilder) buildOutForNode(entities []metadata.Entity) (metadata.SchemaNodeOut, error) {
+func (b *kgBuilder) buildOutForNode(out map[string]relationInfo, files map[uint]metadata.FileInfo) metadata.SchemaNodeOut {
 	ret := metadata.SchemaNodeOut{}
 
-	ids := make([]uint, len(entities))
-	for i := 0; i < len(ids); i++ {
-		ids[i] = entities[i].ID
-	}
-
-	var outRelations []metadata.Relation
-	err := b.tx.
-		Where("head_id in ?", ids).
-		Find(&outRelations).Error
-	if err != nil {
-		return ret, utils.WrapError(err, "select relations with headIDs fail")
-	}
-
-	next := make([]uint, len(outRelations))
-	for i := 0; i < len(outRelations); i++ {
-		next[i] = outRelations[i].TailID
-	}
-
-	out, err := b.collectRelations(outRelations, next)
-	if err != nil {
-		return ret, utils.WrapError(err, "collect relations fail")
-	}
-
-	nextNodes := map[string]metadata.RelationInfo{}
+	nextNodes := map[string][]metadata.RelationInfo{}
 	for nextName, info := range out {
-		relationName := info.maxCountNotDeleteName()
+		resolved := b.resolver.Resolve(info.candidates())
 
-		if len(relationName) == 0 {
+		if len(resolved) == 0 {
 			continue
 		}
 
-		fids := info.files(relationName)
-		files := make([]metadata.FileInfo, 0, len(fids))
-		for fid := range fids {
-			name, typ, err := b.getFileNameAndTypeByID(fid)
-			if err != nil {
-				return ret, utils.WrapError(err, "get fileName with id fail")
-			}
-			files = append(files, metadata.FileInfo{
-				FileID:   fid,
-				FileName: name,
-				FileType: typ,
-			})
-		}
-
-		eids := info.extractors(relationName)
-		extractors := make([]uint, 0, len(eids))
-		for eid := range eids {
-			extractors = append(extractors, eid)
+		edges := make([]metadata.RelationInfo, 0, len(resolved))
+		for _, relationName := range resolved {
+			edges = append(edges, b.buildRelationInfo(info, relationName, files))
 		}
 
-		nextNodes[nextName] = metadata.RelationInfo{
-			Name:       relationName,
-			Files:      files,
-			Extractors: extractors,
-		}
+		nextNodes[nextName] = edges
 	}
 
 	ret.NextNodes = nextNodes
 
-	return ret, nil
+	return ret
 }
 
-func (b *kgBuilder) createNode(name string) error {
-	var entities []metadata.Entity
-	err := b.tx.
-		Where("name = ? and extractor_id in ?", name, b.config.ExtractorIDList).
-		Find(&entities).Error
-	if err != nil {
-		return utils.WrapError(err, "select entities with name and extractorIds fail")
+/*
+buildRelationInfo 构建一条出边的信息，包括关系名、来源文件、来源句子和抽取器快照。
+*/
+func (b *kgBuilder) buildRelationInfo(info relationInfo, relationName string, files map[uint]metadata.FileInfo) metadata.RelationInfo {
+	fids := info.files(relationName)
+	fileIDs := make([]uint, 0, len(fids))
+	for fid := range fids {
+		fileIDs = append(fileIDs, fid)
 	}
+	sort.Slice(fileIDs, func(i, j int) bool { return fileIDs[i] < fileIDs[j] })
 
-	// 出边
-	nodeOut, err := b.buildOutForNode(entities)
-	if err != nil {
-		return utils.WrapError(err, "build outs for node fail")
+	fileInfos := make([]metadata.FileInfo, 0, len(fileIDs))
+	for _, fid := range fileIDs {
+		fileInfos = append(fileInfos, files[fid])
 	}
 
-	// 溯源
-	nodeSource, err := b.buildSourceForNode(entities)
+	eids := info.extractors(relationName)
+	extractors := make([]uint, 0, len(eids))
+	for eid := range eids {
+		extractors = append(extractors, b.result.ExtractorSnapshotMap[eid])
+	}
+	sort.Slice(extractors, func(i, j int) bool { return extractors[i] < extractors[j] })
+
+	tids := info.texts(relationName)
+	textIDs := make([]uint, 0, len(tids))
+	for tid := range tids {
+		textIDs = append(textIDs, tid)
+	}
+	sort.Slice(textIDs, func(i, j int) bool { return textIDs[i] < textIDs[j] })
+
+	confidence := info.confidence(relationName)
+
+	return metadata.RelationInfo{
+		Name:       relationName,
+		Files:      fileInfos,
+		Extractors: extractors,
+		Texts:      textIDs,
+		Confidence: &confidence,
+	}
+}
+
+/*
+createNodes 在内存中完成所有 Node 的构建，然后分批写入数据库。
+*/
+func (b *kgBuilder) createNodes(entities []entityRow, relations []relationRow, files map[uint]metadata.FileInfo) error {
+	nodeEntities := make(map[string][]*entityRow, len(b.result.SelectedEntities))
+	for i := 0; i < len(entities); i++ {
+		name := entities[i].Name
+		if _, ok := b.result.SelectedEntities[name]; !ok {
+			continue
+		}
+
+		nodeEntities[name] = append(nodeEntities[name], &entities[i])
+	}
+
+	outs, err := b.collectRelations(entities, relations)
 	if err != nil {
-		return utils.WrapError(err, "build source for node fail")
+		return utils.WrapError(err, "collect relations fail")
 	}
 
-	// 创建 node
-	node := metadata.Node{
-		Model:      gorm.Model{},
-		Extra:      metadata.Extra{},
-		BuildID:    b.result.BuildID,
-		Name:       name,
-		OutJSON:    nodeOut.ToJSON(),
-		SourceJSON: nodeSource.ToJSON(),
+	names := make([]string, 0, len(nodeEntities))
+	for name := range nodeEntities {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+
+	total := len(names)
+
+	for begin := 0; begin < total; begin += nodeBatchSize {
+		if err := b.ctx.Err(); err != nil {
+			return utils.WrapError(err, "build cancelled")
+		}
+
+		end := begin + nodeBatchSize
+		if end > total {
+			end = total
+		}
+
+		nodes := make([]metadata.Node, 0, end-begin)
+		for _, name := range names[begin:end] {
+			// 出边
+			nodeOut := b.buildOutForNode(outs[name], files)
+
+			// 溯源
+			nodeSource := b.buildSourceForNode(nodeEntities[name], files)
 
-	if err := b.tx.Create(&node).Error; err != nil {
-		return utils.WrapError(err, "insert node fail")
+			nodes = append(nodes, metadata.Node{
+				BuildID:    b.result.BuildID,
+				Name:       name,
+				Category:   b.categoryForNode(nodeEntities[name]),
+				OutJSON:    nodeOut.ToJSON(),
+				SourceJSON: nodeSource.ToJSON(),
+			})
+		}
+
+		if err := b.insertNodes(nodes); err != nil {
+			return utils.WrapError(err, "insert nodes fail")
+		}
 	}
 
 	return nil
 }
 
-func (b *kgBuilder) createNodes(entities map[string]struct{}) error {
-
-	for entity := range entities {
+/*
+insertNodes 写入一批 Node，并报告构建 Node 的进度。
+*/
+func (b *kgBuilder) insertNodes(nodes []metadata.Node) error {
+	if len(nodes) == 0 {
+		return nil
+	}
 
-		err := b.createNode(entity)
-		if err != nil {
-			return utils.WrapError(err, "create node fail")
-		}
+	if err := b.tx.CreateInBatches(&nodes, nodeBatchSize).Error; err != nil {
+		return err
 	}
 
+	b.nodesCreated += len(nodes)
+	b.reportProgress(KGBuildStageCreateNodes, b.nodesCreated, b.nodesTotal)
+
 	return nil
 }
@@ -4,10 +4,11 @@ import (
 	"autograph-backend-controller/repository/metadata"
 	"autograph-backend-controller/utils"
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
-	"fmt"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"strconv"
 )
 
 func transKGToCSV(setting *KGSetting, buildID uint) ([]byte, []byte, error) {
@@ -18,14 +19,29 @@ func transKGToCSV(setting *KGSetting, buildID uint) ([]byte, []byte, error) {
 		db:      db,
 		logger:  setting.Logger,
 	}
+	builder.entityWriter = csv.NewWriter(&builder.entityCSV)
+	builder.relationWriter = csv.NewWriter(&builder.relationCSV)
 
 	if err := builder.buildCSV(); err != nil {
 		return nil, nil, utils.WrapError(err, "build csv fail")
 	}
 
+	builder.entityWriter.Flush()
+	if err := builder.entityWriter.Error(); err != nil {
+		return nil, nil, utils.WrapError(err, "flush entity csv fail")
+	}
+
+	builder.relationWriter.Flush()
+	if err := builder.relationWriter.Error(); err != nil {
+		return nil, nil, utils.WrapError(err, "flush relation csv fail")
+	}
+
 	return builder.entityCSV.Bytes(), builder.relationCSV.Bytes(), nil
 }
 
+/*
+csvBuilder 生成 RFC 4180 格式的实体和关系 CSV，字段由 csv.Writer 负责转义。
+*/
 type csvBuilder struct {
 	// input
 	buildID uint
@@ -33,8 +49,10 @@ type csvBuilder struct {
 	logger  *logrus.Logger
 
 	// output
-	entityCSV   bytes.Buffer
-	relationCSV bytes.Buffer
+	entityCSV      bytes.Buffer
+	relationCSV    bytes.Buffer
+	entityWriter   *csv.Writer
+	relationWriter *csv.Writer
 }
 
 func (b *csvBuilder) buildCSV() error {
@@ -48,8 +66,12 @@ func (b *csvBuilder) buildCSV() error {
 	}
 
 	// 写文件头
-	b.entityCSV.WriteString("version,name,source")
-	b.relationCSV.WriteString("version,head,rel,tail")
+	if err := b.entityWriter.Write([]string{"version", "name", "category", "source"}); err != nil {
+		return utils.WrapError(err, "write entity header fail")
+	}
+	if err := b.relationWriter.Write([]string{"version", "head", "rel", "tail", "confidence"}); err != nil {
+		return utils.WrapError(err, "write relation header fail")
+	}
 
 	// 写各个csv文件内容
 	for _, node := range nodes {
@@ -74,7 +96,7 @@ func (b *csvBuilder) produceNode(node *metadata.Node) error {
 }
 
 func (b *csvBuilder) recordEntity(node *metadata.Node) error {
-	_, err := b.entityCSV.WriteString(fmt.Sprintf("\n%d,%#v,%#v", node.BuildID, node.Name, node.SourceJSON))
+	err := b.entityWriter.Write([]string{strconv.FormatUint(uint64(node.BuildID), 10), node.Name, node.Category, node.SourceJSON})
 	if err != nil {
 		return utils.WrapErrorf(err, "record entity [%#v] fail", node.Name)
 	}
@@ -88,14 +110,22 @@ func (b *csvBuilder) recordRelation(node *metadata.Node) error {
 		return utils.WrapErrorf(err, "json unmarshal out-rel of node [%#v] fail", node.Name)
 	}
 
-	for tail, rel := range outSchema.NextNodes {
+	for tail, edges := range outSchema.NextNodes {
 		if node.Name == tail {
 			continue
 		}
 
-		_, err := b.relationCSV.WriteString(fmt.Sprintf("\n%d,%#v,%#v,%#v", node.BuildID, node.Name, rel.Name, tail))
-		if err != nil {
-			return utils.WrapErrorf(err, "record spo <%#v, %#v, %#v> error", node.Name, rel.Name, tail)
+		for _, rel := range edges {
+			// 旧版本构建的边没有置信度，导入 Neo4j 时为 null
+			confidence := ""
+			if rel.Confidence != nil {
+				confidence = strconv.FormatFloat(*rel.Confidence, 'f', -1, 64)
+			}
+
+			err := b.relationWriter.Write([]string{strconv.FormatUint(uint64(node.BuildID), 10), node.Name, rel.Name, tail, confidence})
+			if err != nil {
+				return utils.WrapErrorf(err, "record spo <%#v, %#v, %#v> error", node.Name, rel.Name, tail)
+			}
 		}
 	}
 
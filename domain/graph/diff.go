@@ -0,0 +1,314 @@
+package graph
+
+import (
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/utils"
+	"context"
+	"encoding/json"
+	"gorm.io/gorm"
+	"sort"
+)
+
+/*
+KGDiff 描述了两次构建之间的差异，From 为旧版本，To 为新版本。
+*/
+type KGDiff struct {
+	From           uint
+	To             uint
+	AddedNodes     []string
+	RemovedNodes   []string
+	AddedEdges     []KGEdge
+	RemovedEdges   []KGEdge
+	RenamedEdges   []KGRenamedEdge
+	ChangedSources []KGSourceChange
+}
+
+type KGEdge struct {
+	Head     string
+	Relation string
+	Tail     string
+}
+
+/*
+KGRenamedEdge 同一对头尾实体之间的关系名由 OldRelation 变为 NewRelation。
+*/
+type KGRenamedEdge struct {
+	Head        string
+	Tail        string
+	OldRelation string
+	NewRelation string
+}
+
+/*
+KGSourceChange 两次构建中都存在的节点的来源变化，抽取器以名字表示。
+*/
+type KGSourceChange struct {
+	Name              string
+	AddedFiles        []metadata.FileInfo
+	RemovedFiles      []metadata.FileInfo
+	AddedExtractors   []string
+	RemovedExtractors []string
+}
+
+/*
+diffNode 为计算差异所需的节点信息。
+*/
+type diffNode struct {
+	out        map[string][]string // 相邻节点名 -> 关系名
+	files      map[uint]metadata.FileInfo
+	extractors map[string]struct{}
+}
+
+func diffKG(setting *KGSetting, ctx context.Context, from, to uint) (*KGDiff, error) {
+	db := setting.GetMetadataDatabase().WithContext(ctx)
+
+	fromNodes, err := loadDiffNodes(db, from)
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "load nodes of build [%d] fail", from)
+	}
+
+	toNodes, err := loadDiffNodes(db, to)
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "load nodes of build [%d] fail", to)
+	}
+
+	ret := KGDiff{
+		From:           from,
+		To:             to,
+		AddedNodes:     make([]string, 0),
+		RemovedNodes:   make([]string, 0),
+		AddedEdges:     make([]KGEdge, 0),
+		RemovedEdges:   make([]KGEdge, 0),
+		RenamedEdges:   make([]KGRenamedEdge, 0),
+		ChangedSources: make([]KGSourceChange, 0),
+	}
+
+	for _, name := range sortedNodeNames(fromNodes, toNodes) {
+		fromNode, inFrom := fromNodes[name]
+		toNode, inTo := toNodes[name]
+
+		switch {
+		case !inFrom:
+			ret.AddedNodes = append(ret.AddedNodes, name)
+		case !inTo:
+			ret.RemovedNodes = append(ret.RemovedNodes, name)
+		default:
+			if change, ok := diffSource(name, fromNode, toNode); ok {
+				ret.ChangedSources = append(ret.ChangedSources, change)
+			}
+		}
+
+		ret.diffEdges(name, fromNode, toNode)
+	}
+
+	return &ret, nil
+}
+
+/*
+loadDiffNodes 分批读取一次构建的所有 Node，返回 Node.Name -> diffNode。构建不存在时返回 gorm.ErrRecordNotFound。
+*/
+func loadDiffNodes(db *gorm.DB, buildID uint) (map[string]*diffNode, error) {
+	var build metadata.Build
+	if err := db.Take(&build, buildID).Error; err != nil {
+		return nil, utils.WrapErrorf(err, "select build [%d] fail", buildID)
+	}
+
+	var snapshots []metadata.BuildExtractor
+	if err := db.Where("build_id = ?", buildID).Find(&snapshots).Error; err != nil {
+		return nil, utils.WrapError(err, "select extractor snapshots fail")
+	}
+
+	extractorNames := make(map[uint]string, len(snapshots))
+	for _, snapshot := range snapshots {
+		extractorNames[snapshot.ID] = snapshot.Name
+	}
+
+	ret := make(map[string]*diffNode)
+
+	var batch []metadata.Node
+	err := db.Select("id", "name", "out_json", "source_json").
+		Where("build_id = ?", buildID).
+		FindInBatches(&batch, nodeBatchSize, func(tx *gorm.DB, batchNum int) error {
+			for i := 0; i < len(batch); i++ {
+				node, err := parseDiffNode(&batch[i], extractorNames)
+				if err != nil {
+					return utils.WrapErrorf(err, "parse node [%s] fail", batch[i].Name)
+				}
+
+				ret[batch[i].Name] = node
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func parseDiffNode(node *metadata.Node, extractorNames map[uint]string) (*diffNode, error) {
+	var out metadata.SchemaNodeOut
+	if err := json.Unmarshal([]byte(node.OutJSON), &out); err != nil {
+		return nil, utils.WrapError(err, "unmarshal out json fail")
+	}
+
+	var source metadata.SchemaNodeSource
+	if err := json.Unmarshal([]byte(node.SourceJSON), &source); err != nil {
+		return nil, utils.WrapError(err, "unmarshal source json fail")
+	}
+
+	ret := diffNode{
+		out:        make(map[string][]string, len(out.NextNodes)),
+		files:      make(map[uint]metadata.FileInfo, len(source.Files)),
+		extractors: make(map[string]struct{}, len(source.Extractors)),
+	}
+
+	for tail, edges := range out.NextNodes {
+		for _, edge := range edges {
+			ret.out[tail] = append(ret.out[tail], edge.Name)
+		}
+	}
+
+	for _, file := range source.Files {
+		ret.files[file.FileID] = file
+	}
+
+	for _, snapshotID := range source.Extractors {
+		ret.extractors[extractorNames[snapshotID]] = struct{}{}
+	}
+
+	return &ret, nil
+}
+
+func sortedNodeNames(a, b map[string]*diffNode) []string {
+	set := make(map[string]struct{}, len(a)+len(b))
+	for name := range a {
+		set[name] = struct{}{}
+	}
+	for name := range b {
+		set[name] = struct{}{}
+	}
+
+	ret := make([]string, 0, len(set))
+	for name := range set {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
+/*
+diffEdges 比较头实体为 head 的出边。同一对头尾实体之间，旧版本独有的关系名与新版本独有的关系名按字典序依次配对为重命名，
+其余的为新增或删除。fromNode 或 toNode 可以为空。
+*/
+func (d *KGDiff) diffEdges(head string, fromNode, toNode *diffNode) {
+	fromOut := map[string][]string{}
+	if fromNode != nil {
+		fromOut = fromNode.out
+	}
+
+	toOut := map[string][]string{}
+	if toNode != nil {
+		toOut = toNode.out
+	}
+
+	tails := make(map[string]struct{}, len(fromOut)+len(toOut))
+	for tail := range fromOut {
+		tails[tail] = struct{}{}
+	}
+	for tail := range toOut {
+		tails[tail] = struct{}{}
+	}
+
+	sortedTails := make([]string, 0, len(tails))
+	for tail := range tails {
+		sortedTails = append(sortedTails, tail)
+	}
+	sort.Strings(sortedTails)
+
+	for _, tail := range sortedTails {
+		removed := subtractNames(fromOut[tail], toOut[tail])
+		added := subtractNames(toOut[tail], fromOut[tail])
+
+		for len(removed) != 0 && len(added) != 0 {
+			d.RenamedEdges = append(d.RenamedEdges, KGRenamedEdge{
+				Head:        head,
+				Tail:        tail,
+				OldRelation: removed[0],
+				NewRelation: added[0],
+			})
+			removed = removed[1:]
+			added = added[1:]
+		}
+
+		for _, name := range removed {
+			d.RemovedEdges = append(d.RemovedEdges, KGEdge{Head: head, Relation: name, Tail: tail})
+		}
+
+		for _, name := range added {
+			d.AddedEdges = append(d.AddedEdges, KGEdge{Head: head, Relation: name, Tail: tail})
+		}
+	}
+}
+
+/*
+subtractNames 返回在 a 中但不在 b 中的名字，按字典序排列。
+*/
+func subtractNames(a, b []string) []string {
+	set := make(map[string]struct{}, len(b))
+	for _, name := range b {
+		set[name] = struct{}{}
+	}
+
+	ret := make([]string, 0)
+	for _, name := range a {
+		if _, ok := set[name]; !ok {
+			ret = append(ret, name)
+		}
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
+func diffSource(name string, fromNode, toNode *diffNode) (KGSourceChange, bool) {
+	ret := KGSourceChange{
+		Name:              name,
+		AddedFiles:        make([]metadata.FileInfo, 0),
+		RemovedFiles:      make([]metadata.FileInfo, 0),
+		AddedExtractors:   make([]string, 0),
+		RemovedExtractors: make([]string, 0),
+	}
+
+	for id, file := range toNode.files {
+		if _, ok := fromNode.files[id]; !ok {
+			ret.AddedFiles = append(ret.AddedFiles, file)
+		}
+	}
+	for id, file := range fromNode.files {
+		if _, ok := toNode.files[id]; !ok {
+			ret.RemovedFiles = append(ret.RemovedFiles, file)
+		}
+	}
+	sort.Slice(ret.AddedFiles, func(i, j int) bool { return ret.AddedFiles[i].FileID < ret.AddedFiles[j].FileID })
+	sort.Slice(ret.RemovedFiles, func(i, j int) bool { return ret.RemovedFiles[i].FileID < ret.RemovedFiles[j].FileID })
+
+	for extractor := range toNode.extractors {
+		if _, ok := fromNode.extractors[extractor]; !ok {
+			ret.AddedExtractors = append(ret.AddedExtractors, extractor)
+		}
+	}
+	for extractor := range fromNode.extractors {
+		if _, ok := toNode.extractors[extractor]; !ok {
+			ret.RemovedExtractors = append(ret.RemovedExtractors, extractor)
+		}
+	}
+	sort.Strings(ret.AddedExtractors)
+	sort.Strings(ret.RemovedExtractors)
+
+	changed := len(ret.AddedFiles) != 0 || len(ret.RemovedFiles) != 0 ||
+		len(ret.AddedExtractors) != 0 || len(ret.RemovedExtractors) != 0
+
+	return ret, changed
+}
@@ -0,0 +1,96 @@
+package graph
+
+import (
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/repository/metadata"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestDiffKG(t *testing.T) {
+	logging.SetDefaultConfig(logging.GenerateTestConfig(t))
+
+	database, err := metadata.CreateDatabase(metadata.GenerateTestConfig())
+	require.Nil(t, err)
+
+	setting := KGSetting{
+		GetMetadataDatabase: func() *gorm.DB {
+			return database
+		},
+		Logger: logging.NewLogger(),
+	}
+
+	/*
+		版本 1（抽取器 1）：A --父亲--> B，A --朋友--> C，D
+		版本 2（抽取器 2）：A --老师--> B，B --同事--> C，E
+	*/
+	build := func(name string, entityNames []string, relations [][3]int) uint {
+		extractor := metadata.Extractor{
+			Name: name,
+			Type: metadata.ExtractorTypeModel,
+		}
+		require.Nil(t, database.Create(&extractor).Error)
+
+		entity := make([]metadata.Entity, 0, len(entityNames))
+		for _, entityName := range entityNames {
+			entity = append(entity, metadata.Entity{Name: "Diff-" + entityName, Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID})
+		}
+		require.Nil(t, database.Create(&entity).Error)
+
+		relationNames := []string{"父亲", "朋友", "老师", "同事"}
+		relation := make([]metadata.Relation, 0, len(relations))
+		for _, r := range relations {
+			relation = append(relation, metadata.Relation{
+				Name:        relationNames[r[1]],
+				Type:        metadata.EntityTypeAdd,
+				ExtractorID: extractor.ID,
+				HeadID:      entity[r[0]].ID,
+				TailID:      entity[r[2]].ID,
+			})
+		}
+		require.Nil(t, database.Create(&relation).Error)
+
+		res, err := buildKG(&setting, context.TODO(), &KGBuildConfig{
+			ExtractorIDList: []uint{extractor.ID},
+			Desc:            "TestDiffKG",
+		})
+		require.Nil(t, err)
+
+		return res.BuildID
+	}
+
+	from := build("DiffExtractor-1", []string{"A", "B", "C", "D"}, [][3]int{{0, 0, 1}, {0, 1, 2}})
+	to := build("DiffExtractor-2", []string{"A", "B", "C", "E"}, [][3]int{{0, 2, 1}, {1, 3, 2}})
+
+	diff, err := diffKG(&setting, context.TODO(), from, to)
+	require.Nil(t, err)
+
+	assert.Equal(t, []string{"Diff-E"}, diff.AddedNodes)
+	assert.Equal(t, []string{"Diff-D"}, diff.RemovedNodes)
+	assert.Equal(t, []KGEdge{{Head: "Diff-B", Relation: "同事", Tail: "Diff-C"}}, diff.AddedEdges)
+	assert.Equal(t, []KGEdge{{Head: "Diff-A", Relation: "朋友", Tail: "Diff-C"}}, diff.RemovedEdges)
+	assert.Equal(t, []KGRenamedEdge{{Head: "Diff-A", Tail: "Diff-B", OldRelation: "父亲", NewRelation: "老师"}}, diff.RenamedEdges)
+
+	require.Len(t, diff.ChangedSources, 3)
+	for i, name := range []string{"Diff-A", "Diff-B", "Diff-C"} {
+		assert.Equal(t, name, diff.ChangedSources[i].Name)
+		assert.Equal(t, []string{"DiffExtractor-2"}, diff.ChangedSources[i].AddedExtractors)
+		assert.Equal(t, []string{"DiffExtractor-1"}, diff.ChangedSources[i].RemovedExtractors)
+	}
+
+	// 与自身比较没有差异
+	same, err := diffKG(&setting, context.TODO(), to, to)
+	require.Nil(t, err)
+	assert.Empty(t, same.AddedNodes)
+	assert.Empty(t, same.RemovedNodes)
+	assert.Empty(t, same.AddedEdges)
+	assert.Empty(t, same.RemovedEdges)
+	assert.Empty(t, same.RenamedEdges)
+	assert.Empty(t, same.ChangedSources)
+
+	_, err = diffKG(&setting, context.TODO(), from, to+1000000)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
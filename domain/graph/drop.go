@@ -0,0 +1,29 @@
+package graph
+
+import (
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/utils"
+	"context"
+	"gorm.io/gorm"
+)
+
+/*
+dropKG 删除一次构建在 MySQL 中的全部数据，包括 Node、BuildExtractor 和 Build 记录。
+*/
+func dropKG(setting *KGSetting, ctx context.Context, buildID uint) error {
+	return setting.GetMetadataDatabase().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("build_id = ?", buildID).Delete(&metadata.Node{}).Error; err != nil {
+			return utils.WrapErrorf(err, "delete nodes of build [%d] fail", buildID)
+		}
+
+		if err := tx.Unscoped().Where("build_id = ?", buildID).Delete(&metadata.BuildExtractor{}).Error; err != nil {
+			return utils.WrapErrorf(err, "delete extractor snapshots of build [%d] fail", buildID)
+		}
+
+		if err := tx.Unscoped().Delete(&metadata.Build{}, buildID).Error; err != nil {
+			return utils.WrapErrorf(err, "delete build [%d] fail", buildID)
+		}
+
+		return nil
+	})
+}
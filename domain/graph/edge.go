@@ -0,0 +1,366 @@
+package graph
+
+import (
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/utils"
+	"context"
+	"encoding/json"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+kgEdgeInfo 从头实体的 Node 中读取边的信息，返回 KGEdge -> RelationInfo。构建中不存在的边不在结果中。
+*/
+func kgEdgeInfo(setting *KGSetting, ctx context.Context, buildID uint, edges []KGEdge) (map[KGEdge]*metadata.RelationInfo, error) {
+	ret := make(map[KGEdge]*metadata.RelationInfo, len(edges))
+	if len(edges) == 0 {
+		return ret, nil
+	}
+
+	headSet := make(map[string]struct{}, len(edges))
+	for _, edge := range edges {
+		headSet[edge.Head] = struct{}{}
+	}
+
+	heads := make([]string, 0, len(headSet))
+	for head := range headSet {
+		heads = append(heads, head)
+	}
+
+	var nodes []metadata.Node
+	err := setting.GetMetadataDatabase().WithContext(ctx).
+		Select("id", "name", "out_json").
+		Where("build_id = ? and name in ?", buildID, heads).
+		Find(&nodes).Error
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "select nodes of build [%d] fail", buildID)
+	}
+
+	outs := make(map[string]*metadata.SchemaNodeOut, len(nodes))
+	for i := range nodes {
+		var out metadata.SchemaNodeOut
+		if err := json.Unmarshal([]byte(nodes[i].OutJSON), &out); err != nil {
+			return nil, utils.WrapErrorf(err, "json unmarshal out-rel of node [%#v] fail", nodes[i].Name)
+		}
+		outs[nodes[i].Name] = &out
+	}
+
+	for _, edge := range edges {
+		out, ok := outs[edge.Head]
+		if !ok {
+			continue
+		}
+
+		infos := out.NextNodes[edge.Tail]
+		for i := range infos {
+			if infos[i].Name == edge.Relation {
+				ret[edge] = &infos[i]
+				break
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// edgeProvenanceMaxSentences 每条边最多返回的来源句子数
+const edgeProvenanceMaxSentences = 10
+
+/*
+KGSpan 实体在句子中的位置，以字符（rune）为单位，End 不包含在内。
+*/
+type KGSpan struct {
+	Begin int
+	End   int
+}
+
+/*
+KGSentence 边的一个来源句子，Head 与 Tail 为抽取时记录的头尾实体的位置，未记录时为头尾实体（或其别名）在句子中第一次出现的位置，找不到时为空。
+*/
+type KGSentence struct {
+	TextID  uint
+	Content string
+	Head    *KGSpan
+	Tail    *KGSpan
+}
+
+/*
+KGExtractor 构建中的抽取器快照，ID 为 BuildExtractor.ID。
+*/
+type KGExtractor struct {
+	ID   uint
+	Name string
+	Type uint
+}
+
+/*
+KGProvenance 一条边的来源：来源文件、抽取器快照，以及最多 edgeProvenanceMaxSentences 个来源句子。
+*/
+type KGProvenance struct {
+	Files      []metadata.FileInfo
+	Extractors []KGExtractor
+	Sentences  []KGSentence
+}
+
+/*
+kgEdgeProvenance 读取边的来源，返回 KGEdge -> KGProvenance。构建中不存在的边不在结果中。
+*/
+func kgEdgeProvenance(setting *KGSetting, ctx context.Context, buildID uint, edges []KGEdge) (map[KGEdge]*KGProvenance, error) {
+	infos, err := kgEdgeInfo(setting, ctx, buildID, edges)
+	if err != nil {
+		return nil, utils.WrapError(err, "get edge info fail")
+	}
+
+	ret := make(map[KGEdge]*KGProvenance, len(infos))
+	if len(infos) == 0 {
+		return ret, nil
+	}
+
+	db := setting.GetMetadataDatabase().WithContext(ctx)
+
+	// 抽取器快照与来源句子
+	extractorSet := make(map[uint]struct{})
+	textSet := make(map[uint]struct{})
+	nameSet := make(map[string]struct{})
+	for edge, info := range infos {
+		for _, id := range info.Extractors {
+			extractorSet[id] = struct{}{}
+		}
+		for i, id := range info.Texts {
+			if i >= edgeProvenanceMaxSentences {
+				break
+			}
+			textSet[id] = struct{}{}
+		}
+		nameSet[edge.Head] = struct{}{}
+		nameSet[edge.Tail] = struct{}{}
+	}
+
+	var buildExtractors []metadata.BuildExtractor
+	if len(extractorSet) != 0 {
+		err = db.Select("id", "name", "type").
+			Where("build_id = ? and id in ?", buildID, setKeys(extractorSet)).
+			Find(&buildExtractors).Error
+		if err != nil {
+			return nil, utils.WrapErrorf(err, "select extractor snapshots of build [%d] fail", buildID)
+		}
+	}
+
+	extractors := make(map[uint]KGExtractor, len(buildExtractors))
+	for _, extractor := range buildExtractors {
+		extractors[extractor.ID] = KGExtractor{
+			ID:   extractor.ID,
+			Name: extractor.Name,
+			Type: extractor.Type,
+		}
+	}
+
+	var texts []metadata.Text
+	if len(textSet) != 0 {
+		if err := db.Select("id", "content").Find(&texts, setKeys(textSet)).Error; err != nil {
+			return nil, utils.WrapError(err, "select texts fail")
+		}
+	}
+
+	contents := make(map[uint]string, len(texts))
+	for _, text := range texts {
+		contents[text.ID] = text.Content
+	}
+
+	// 头尾实体的别名，用于在句子中定位实体
+	var nodes []metadata.Node
+	names := make([]string, 0, len(nameSet))
+	for name := range nameSet {
+		names = append(names, name)
+	}
+	err = db.Select("id", "name", "source_json").
+		Where("build_id = ? and name in ?", buildID, names).
+		Find(&nodes).Error
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "select nodes of build [%d] fail", buildID)
+	}
+
+	surfaces := make(map[string][]string, len(nodes)) // 实体名 -> 实体名及其别名
+	for i := range nodes {
+		var source metadata.SchemaNodeSource
+		if err := json.Unmarshal([]byte(nodes[i].SourceJSON), &source); err != nil {
+			return nil, utils.WrapErrorf(err, "json unmarshal source of node [%#v] fail", nodes[i].Name)
+		}
+		surfaces[nodes[i].Name] = append([]string{nodes[i].Name}, source.Aliases...)
+	}
+
+	spans, err := loadSentenceSpans(db, setKeys(textSet), infos)
+	if err != nil {
+		return nil, utils.WrapError(err, "load sentence spans fail")
+	}
+
+	for edge, info := range infos {
+		provenance := KGProvenance{
+			Files:      info.Files,
+			Extractors: make([]KGExtractor, 0, len(info.Extractors)),
+			Sentences:  make([]KGSentence, 0),
+		}
+		if provenance.Files == nil {
+			provenance.Files = make([]metadata.FileInfo, 0)
+		}
+
+		for _, id := range info.Extractors {
+			if extractor, ok := extractors[id]; ok {
+				provenance.Extractors = append(provenance.Extractors, extractor)
+			}
+		}
+
+		for i, id := range info.Texts {
+			if i >= edgeProvenanceMaxSentences {
+				break
+			}
+
+			content, ok := contents[id]
+			if !ok {
+				continue
+			}
+
+			sentence := KGSentence{
+				TextID:  id,
+				Content: content,
+			}
+
+			// 优先使用抽取时记录的位置，找不到句子中的关系时再查找实体名及其别名
+			if row := findSentenceSpan(spans[sentenceKey{textID: id, relation: edge.Relation}], surfaces, edge); row != nil {
+				sentence.Head = LocateSpan(content, row.HeadName, row.HeadBegin, row.HeadEnd)
+				sentence.Tail = LocateSpan(content, row.TailName, row.TailBegin, row.TailEnd)
+			} else {
+				sentence.Head = findSpan(content, surfaces[edge.Head])
+				sentence.Tail = findSpan(content, surfaces[edge.Tail])
+			}
+
+			provenance.Sentences = append(provenance.Sentences, sentence)
+		}
+
+		ret[edge] = &provenance
+	}
+
+	return ret, nil
+}
+
+/*
+sentenceKey 句子中的一种关系
+*/
+type sentenceKey struct {
+	textID   uint
+	relation string
+}
+
+/*
+sentenceSpan 句子中一个 Relation 的头尾实体名及其记录的位置
+*/
+type sentenceSpan struct {
+	TextID    uint
+	Relation  string
+	HeadName  string
+	HeadBegin *int
+	HeadEnd   *int
+	TailName  string
+	TailBegin *int
+	TailEnd   *int
+}
+
+/*
+loadSentenceSpans 读取来源句子中与边同名的 Relation 的头尾实体位置，返回 sentenceKey -> 句子中的 Relation
+*/
+func loadSentenceSpans(db *gorm.DB, textIDs []uint, infos map[KGEdge]*metadata.RelationInfo) (map[sentenceKey][]sentenceSpan, error) {
+	ret := make(map[sentenceKey][]sentenceSpan)
+	if len(textIDs) == 0 {
+		return ret, nil
+	}
+
+	relationSet := make(map[string]struct{}, len(infos))
+	for edge := range infos {
+		relationSet[edge.Relation] = struct{}{}
+	}
+	relations := make([]string, 0, len(relationSet))
+	for relation := range relationSet {
+		relations = append(relations, relation)
+	}
+
+	var rows []sentenceSpan
+	err := db.Model(&metadata.Relation{}).
+		Select("relations.text_id, relations.name as relation, "+
+			"heads.name as head_name, heads.begin as head_begin, heads.end as head_end, "+
+			"tails.name as tail_name, tails.begin as tail_begin, tails.end as tail_end").
+		Joins("join entities heads on heads.id = relations.head_id and heads.deleted_at is null").
+		Joins("join entities tails on tails.id = relations.tail_id and tails.deleted_at is null").
+		Where("relations.text_id in ? and relations.name in ? and relations.type = ?",
+			textIDs, relations, metadata.EntityTypeAdd).
+		Order("relations.id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, utils.WrapError(err, "select relations of texts fail")
+	}
+
+	for _, row := range rows {
+		key := sentenceKey{textID: row.TextID, relation: row.Relation}
+		ret[key] = append(ret[key], row)
+	}
+
+	return ret, nil
+}
+
+/*
+findSentenceSpan 返回句子中头尾实体为边的头尾节点名或其别名的第一个 Relation，没有时返回空
+*/
+func findSentenceSpan(rows []sentenceSpan, surfaces map[string][]string, edge KGEdge) *sentenceSpan {
+	contains := func(names []string, name string) bool {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := range rows {
+		if contains(surfaces[edge.Head], rows[i].HeadName) && contains(surfaces[edge.Tail], rows[i].TailName) {
+			return &rows[i]
+		}
+	}
+
+	return nil
+}
+
+/*
+findSpan 返回 names 中最早出现在 content 中的一个的位置，位置相同时取较长的，都不出现时返回空。
+*/
+func findSpan(content string, names []string) *KGSpan {
+	var ret *KGSpan
+	for _, name := range names {
+		if len(name) == 0 {
+			continue
+		}
+
+		index := strings.Index(content, name)
+		if index < 0 {
+			continue
+		}
+
+		begin := utf8.RuneCountInString(content[:index])
+		end := begin + utf8.RuneCountInString(name)
+		if ret == nil || begin < ret.Begin || (begin == ret.Begin && end > ret.End) {
+			ret = &KGSpan{Begin: begin, End: end}
+		}
+	}
+
+	return ret
+}
+
+func setKeys(set map[uint]struct{}) []uint {
+	ret := make([]uint, 0, len(set))
+	for key := range set {
+		ret = append(ret, key)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
@@ -0,0 +1,20 @@
+package graph
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFindSpan(t *testing.T) {
+	content := "小明毕业于北京航空航天大学，现居北京。"
+
+	assert.Equal(t, &KGSpan{Begin: 0, End: 2}, findSpan(content, []string{"小明"}))
+	assert.Equal(t, &KGSpan{Begin: 5, End: 13}, findSpan(content, []string{"北航", "北京航空航天大学"}))
+
+	// 位置相同时取较长的，位置不同时取最早的
+	assert.Equal(t, &KGSpan{Begin: 5, End: 13}, findSpan(content, []string{"北京", "北京航空航天大学"}))
+	assert.Equal(t, &KGSpan{Begin: 5, End: 7}, findSpan(content, []string{"现居北京", "北京"}))
+
+	assert.Nil(t, findSpan(content, []string{"小红", ""}))
+	assert.Nil(t, findSpan(content, nil))
+}
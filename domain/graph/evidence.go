@@ -0,0 +1,249 @@
+package graph
+
+import (
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/utils"
+	"context"
+	"encoding/json"
+	"gorm.io/gorm"
+)
+
+// evidenceContext 证据片段中高亮部分前后保留的字符数
+const evidenceContext = 32
+
+/*
+KGEvidence 实体或关系在一个句子中的证据。
+
+	TextID、FileID 来源的句子与文件，FileID 可能为空；
+	Extractor 抽取出该实体或关系的抽取器快照；
+	Snippet 句子中包含高亮部分及其上下文的片段，Offset 为片段在句子中的起始位置，以字符为单位；
+	Highlights 需要高亮的实体在 Snippet 中的位置，位置未知且在句子中找不到的实体不高亮；
+*/
+type KGEvidence struct {
+	TextID     uint
+	FileID     *uint
+	Extractor  KGExtractor
+	Snippet    string
+	Offset     int
+	Highlights []KGSpan
+}
+
+/*
+evidenceRow 为查询证据所需的 Entity 或 Relation 信息，头尾实体的位置只在查询关系时填充。
+*/
+type evidenceRow struct {
+	ExtractorID uint
+	TextID      uint
+	FileID      *uint
+	Content     string
+	HeadName    string
+	HeadBegin   *int
+	HeadEnd     *int
+	TailName    string
+	TailBegin   *int
+	TailEnd     *int
+}
+
+/*
+evidenceScope 构建中的抽取器及节点的别名，用于将 Entity、Relation 限定在构建的范围内。
+*/
+type evidenceScope struct {
+	extractors map[uint]KGExtractor // Extractor.ID -> 快照
+	surfaces   map[string][]string  // 实体名 -> 实体名及其别名
+}
+
+func loadEvidenceScope(db *gorm.DB, buildID uint, names []string) (*evidenceScope, error) {
+	var buildExtractors []metadata.BuildExtractor
+	err := db.Select("id", "name", "type", "extractor_id").
+		Where("build_id = ?", buildID).
+		Find(&buildExtractors).Error
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "select extractor snapshots of build [%d] fail", buildID)
+	}
+
+	ret := evidenceScope{
+		extractors: make(map[uint]KGExtractor, len(buildExtractors)),
+		surfaces:   make(map[string][]string, len(names)),
+	}
+
+	for _, extractor := range buildExtractors {
+		ret.extractors[extractor.ExtractorID] = KGExtractor{
+			ID:   extractor.ID,
+			Name: extractor.Name,
+			Type: extractor.Type,
+		}
+	}
+
+	var nodes []metadata.Node
+	err = db.Select("id", "name", "source_json").
+		Where("build_id = ? and name in ?", buildID, names).
+		Find(&nodes).Error
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "select nodes of build [%d] fail", buildID)
+	}
+
+	for i := range nodes {
+		var source metadata.SchemaNodeSource
+		if err := json.Unmarshal([]byte(nodes[i].SourceJSON), &source); err != nil {
+			return nil, utils.WrapErrorf(err, "json unmarshal source of node [%#v] fail", nodes[i].Name)
+		}
+		ret.surfaces[nodes[i].Name] = append([]string{nodes[i].Name}, source.Aliases...)
+	}
+
+	for _, name := range names {
+		if _, ok := ret.surfaces[name]; !ok {
+			return nil, utils.WrapErrorf(gorm.ErrRecordNotFound, "select node [%#v] of build [%d] fail", name, buildID)
+		}
+	}
+
+	return &ret, nil
+}
+
+func (s *evidenceScope) extractorIDs() []uint {
+	ret := make([]uint, 0, len(s.extractors))
+	for id := range s.extractors {
+		ret = append(ret, id)
+	}
+	return ret
+}
+
+/*
+kgNodeEvidence 查询构建中的节点在句子中的证据，即构建所用的抽取器抽取出的、名字为节点名或其别名的 Entity，最多返回 limit 条。
+节点不在构建中时返回 gorm.ErrRecordNotFound。
+*/
+func kgNodeEvidence(setting *KGSetting, ctx context.Context, buildID uint, name string, limit int) ([]KGEvidence, error) {
+	db := setting.GetMetadataDatabase().WithContext(ctx)
+
+	scope, err := loadEvidenceScope(db, buildID, []string{name})
+	if err != nil {
+		return nil, utils.WrapError(err, "load evidence scope fail")
+	}
+
+	var rows []evidenceRow
+	err = db.Model(&metadata.Entity{}).
+		Select("entities.extractor_id, entities.text_id, texts.file_id, texts.content, "+
+			"entities.name as head_name, entities.begin as head_begin, entities.end as head_end").
+		Joins("join texts on texts.id = entities.text_id and texts.deleted_at is null").
+		Where("entities.name in ? and entities.type = ? and entities.extractor_id in ?",
+			scope.surfaces[name], metadata.EntityTypeAdd, scope.extractorIDs()).
+		Order("entities.id").
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "select entities of node [%#v] fail", name)
+	}
+
+	ret := make([]KGEvidence, 0, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		ret = append(ret, makeEvidence(row, scope, []*KGSpan{
+			LocateSpan(row.Content, row.HeadName, row.HeadBegin, row.HeadEnd),
+		}))
+	}
+
+	return ret, nil
+}
+
+/*
+kgEdgeEvidence 查询构建中的边在句子中的证据，即构建所用的抽取器抽取出的、头尾实体名为节点名或其别名的同名 Relation，最多返回 limit 条。
+头尾节点不在构建中时返回 gorm.ErrRecordNotFound。
+*/
+func kgEdgeEvidence(setting *KGSetting, ctx context.Context, buildID uint, edge KGEdge, limit int) ([]KGEvidence, error) {
+	db := setting.GetMetadataDatabase().WithContext(ctx)
+
+	scope, err := loadEvidenceScope(db, buildID, []string{edge.Head, edge.Tail})
+	if err != nil {
+		return nil, utils.WrapError(err, "load evidence scope fail")
+	}
+
+	var rows []evidenceRow
+	err = db.Model(&metadata.Relation{}).
+		Select("relations.extractor_id, relations.text_id, texts.file_id, texts.content, "+
+			"heads.name as head_name, heads.begin as head_begin, heads.end as head_end, "+
+			"tails.name as tail_name, tails.begin as tail_begin, tails.end as tail_end").
+		Joins("join entities heads on heads.id = relations.head_id and heads.deleted_at is null").
+		Joins("join entities tails on tails.id = relations.tail_id and tails.deleted_at is null").
+		Joins("join texts on texts.id = relations.text_id and texts.deleted_at is null").
+		Where("relations.name = ? and relations.type = ? and relations.extractor_id in ?",
+			edge.Relation, metadata.EntityTypeAdd, scope.extractorIDs()).
+		Where("heads.name in ? and tails.name in ?", scope.surfaces[edge.Head], scope.surfaces[edge.Tail]).
+		Order("relations.id").
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "select relations of edge [%#v] fail", edge)
+	}
+
+	ret := make([]KGEvidence, 0, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		ret = append(ret, makeEvidence(row, scope, []*KGSpan{
+			LocateSpan(row.Content, row.HeadName, row.HeadBegin, row.HeadEnd),
+			LocateSpan(row.Content, row.TailName, row.TailBegin, row.TailEnd),
+		}))
+	}
+
+	return ret, nil
+}
+
+/*
+LocateSpan 返回实体在句子中的位置：优先使用抽取时记录的位置，位置未知或超出句子时在句子中查找实体名。
+*/
+func LocateSpan(content string, name string, begin, end *int) *KGSpan {
+	if begin != nil && end != nil && *begin >= 0 && *begin < *end && *end <= len([]rune(content)) {
+		return &KGSpan{Begin: *begin, End: *end}
+	}
+
+	return findSpan(content, []string{name})
+}
+
+/*
+makeEvidence 截取包含所有 spans 及其前后 evidenceContext 个字符的片段，并将 spans 转换为相对于片段的位置。
+*/
+func makeEvidence(row *evidenceRow, scope *evidenceScope, spans []*KGSpan) KGEvidence {
+	content := []rune(row.Content)
+
+	begin, end := -1, -1
+	highlights := make([]KGSpan, 0, len(spans))
+	for _, span := range spans {
+		if span == nil {
+			continue
+		}
+
+		highlights = append(highlights, *span)
+		if begin < 0 || span.Begin < begin {
+			begin = span.Begin
+		}
+		if span.End > end {
+			end = span.End
+		}
+	}
+
+	if begin < 0 {
+		begin, end = 0, 0
+	}
+
+	begin -= evidenceContext
+	if begin < 0 {
+		begin = 0
+	}
+
+	end += evidenceContext
+	if end > len(content) {
+		end = len(content)
+	}
+
+	for i := range highlights {
+		highlights[i].Begin -= begin
+		highlights[i].End -= begin
+	}
+
+	return KGEvidence{
+		TextID:     row.TextID,
+		FileID:     row.FileID,
+		Extractor:  scope.extractors[row.ExtractorID],
+		Snippet:    string(content[begin:end]),
+		Offset:     begin,
+		Highlights: highlights,
+	}
+}
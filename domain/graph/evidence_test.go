@@ -0,0 +1,41 @@
+package graph
+
+import (
+	"autograph-backend-controller/utils"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestMakeEvidence(t *testing.T) {
+	scope := &evidenceScope{
+		extractors: map[uint]KGExtractor{7: {ID: 70, Name: "model"}},
+	}
+
+	prefix := strings.Repeat("前", evidenceContext+5)
+	suffix := strings.Repeat("后", evidenceContext+5)
+	row := &evidenceRow{
+		ExtractorID: 7,
+		TextID:      1,
+		Content:     prefix + "小明认识小红" + suffix,
+	}
+
+	head := LocateSpan(row.Content, "小明", utils.IntToPtr(evidenceContext+5), utils.IntToPtr(evidenceContext+7))
+	tail := LocateSpan(row.Content, "小红", nil, nil)
+	assert.Equal(t, &KGSpan{Begin: evidenceContext + 9, End: evidenceContext + 11}, tail)
+
+	evidence := makeEvidence(row, scope, []*KGSpan{head, tail})
+	assert.Equal(t, 5, evidence.Offset)
+	assert.Equal(t, strings.Repeat("前", evidenceContext)+"小明认识小红"+strings.Repeat("后", evidenceContext), evidence.Snippet)
+	assert.Equal(t, []KGSpan{{Begin: evidenceContext, End: evidenceContext + 2}, {Begin: evidenceContext + 4, End: evidenceContext + 6}}, evidence.Highlights)
+	assert.Equal(t, "model", evidence.Extractor.Name)
+
+	// 记录的位置超出句子时在句子中查找
+	assert.Equal(t, head, LocateSpan(row.Content, "小明", utils.IntToPtr(1000), utils.IntToPtr(1002)))
+
+	// 没有可以高亮的实体时只截取句子开头
+	evidence = makeEvidence(row, scope, []*KGSpan{nil})
+	assert.Equal(t, 0, evidence.Offset)
+	assert.Equal(t, strings.Repeat("前", evidenceContext), evidence.Snippet)
+	assert.Empty(t, evidence.Highlights)
+}
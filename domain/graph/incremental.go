@@ -0,0 +1,441 @@
+package graph
+
+import (
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/utils"
+	"encoding/json"
+	"errors"
+	"gorm.io/gorm"
+	"sort"
+)
+
+var (
+	ErrBaseBuildWithoutLineage = errors.New("base build has no extractor lineage")
+	ErrSnapshotNotFound        = errors.New("extractor snapshot not found in current build")
+)
+
+/*
+baseBuildInfo 记录了增量构建所基于的构建的信息。
+*/
+type baseBuildInfo struct {
+	build              metadata.Build
+	snapshotExtractors map[uint]uint     // BuildExtractor.ID -> Extractor.ID
+	extractors         map[uint]struct{} // Extractor.ID
+	nodeNames          map[string]struct{}
+}
+
+/*
+buildIncrementally 基于 b.config.BaseBuildID 进行增量构建：只重新计算实体或关系在基础构建之后发生变化的 Node，其余 Node 直接复用基础构建的结果。
+*/
+func (b *kgBuilder) buildIncrementally(humanInterventionExtractorIDList []uint) error {
+	b.reportProgress(KGBuildStageCollectEntities, 0, 4)
+
+	base, err := b.loadBaseBuild()
+	if err != nil {
+		return utils.WrapError(err, "load base build fail")
+	}
+
+	// 关系消解策略或置信度阈值不同时，未变化的 Node 也可能不同，无法复用
+	compatible, err := b.compatibleWith(&base.build)
+	if err != nil {
+		return utils.WrapError(err, "compare build config fail")
+	}
+	if !compatible {
+		b.setting.Logger.Infof("build config differs from build [%d], fall back to full build", base.build.ID)
+		return b.buildFully()
+	}
+
+	dirty, err := b.collectDirtyNames(base)
+	if err != nil {
+		return utils.WrapError(err, "collect dirty names fail")
+	}
+
+	b.reportProgress(KGBuildStageCollectEntities, 1, 4)
+
+	err = b.collectEntitiesIncrementally(base, dirty, humanInterventionExtractorIDList)
+	if err != nil {
+		return utils.WrapError(err, "collect entities fail")
+	}
+
+	b.reportProgress(KGBuildStageCollectEntities, 2, 4)
+
+	rebuild := make([]string, 0)
+	for name := range dirty {
+		if _, ok := b.result.SelectedEntities[name]; ok {
+			rebuild = append(rebuild, name)
+		}
+	}
+	sort.Strings(rebuild)
+	b.setting.Logger.Infof("rebuild node set(len=%d) based on build [%d]", len(rebuild), base.build.ID)
+
+	entities, err := b.loadEntitiesByNames(rebuild)
+	if err != nil {
+		return utils.WrapError(err, "load entities fail")
+	}
+
+	relations, err := b.loadRelationsByHeadNames(rebuild)
+	if err != nil {
+		return utils.WrapError(err, "load relations fail")
+	}
+
+	b.reportProgress(KGBuildStageCollectEntities, 3, 4)
+
+	files, err := b.loadFiles(entities, relations)
+	if err != nil {
+		return utils.WrapError(err, "load files fail")
+	}
+
+	b.reportProgress(KGBuildStageCollectEntities, 4, 4)
+
+	b.nodesTotal = len(b.result.SelectedEntities)
+	b.reportProgress(KGBuildStageCreateNodes, 0, b.nodesTotal)
+
+	err = b.reuseNodes(base, dirty)
+	if err != nil {
+		return utils.WrapError(err, "reuse nodes fail")
+	}
+
+	err = b.createNodes(entities, relations, files)
+	if err != nil {
+		return utils.WrapError(err, "create nodes fail")
+	}
+
+	return nil
+}
+
+func (b *kgBuilder) loadBaseBuild() (*baseBuildInfo, error) {
+	ret := baseBuildInfo{
+		snapshotExtractors: make(map[uint]uint),
+		extractors:         make(map[uint]struct{}),
+		nodeNames:          make(map[string]struct{}),
+	}
+
+	if err := b.tx.Take(&ret.build, b.config.BaseBuildID).Error; err != nil {
+		return nil, utils.WrapErrorf(err, "select build [%d] fail", b.config.BaseBuildID)
+	}
+
+	var snapshots []metadata.BuildExtractor
+	if err := b.tx.Where("build_id = ?", ret.build.ID).Find(&snapshots).Error; err != nil {
+		return nil, utils.WrapError(err, "select extractor snapshots fail")
+	}
+
+	for _, snapshot := range snapshots {
+		if snapshot.ExtractorID == 0 {
+			return nil, utils.WrapErrorf(ErrBaseBuildWithoutLineage, "snapshot [%d] of build [%d]", snapshot.ID, ret.build.ID)
+		}
+
+		ret.snapshotExtractors[snapshot.ID] = snapshot.ExtractorID
+		ret.extractors[snapshot.ExtractorID] = struct{}{}
+	}
+
+	var names []string
+	if err := b.tx.Model(&metadata.Node{}).Where("build_id = ?", ret.build.ID).Pluck("name", &names).Error; err != nil {
+		return nil, utils.WrapError(err, "select node names fail")
+	}
+
+	for _, name := range names {
+		ret.nodeNames[name] = struct{}{}
+	}
+
+	return &ret, nil
+}
+
+/*
+compatibleWith 判断本次构建与 build 是否使用相同的关系消解策略和置信度阈值，Build.RelationResolver 为空时视为 majority 策略。
+*/
+func (b *kgBuilder) compatibleWith(build *metadata.Build) (bool, error) {
+	name := build.RelationResolver
+	if len(name) == 0 {
+		name = RelationResolverMajority
+	}
+
+	param, err := b.resolver.Param()
+	if err != nil {
+		return false, utils.WrapError(err, "get relation resolver param fail")
+	}
+
+	return name == b.resolver.Name() &&
+		build.RelationResolverParam == param &&
+		build.MinConfidence == b.config.MinConfidence, nil
+}
+
+/*
+collectDirtyNames 收集在基础构建之后发生变化的实体名（规范名），包括：
+新增或删除的 Entity 的名字、新增或移除的抽取器的 Entity 的名字、新增或删除的 Relation 的头实体的名字、
+来源的 Text 或 File 被删除的 Entity 的名字和 Relation 的头实体的名字、新增或删除的别名及其规范名。
+*/
+func (b *kgBuilder) collectDirtyNames(base *baseBuildInfo) (map[string]struct{}, error) {
+	since := base.build.CreatedAt
+	dirty := make(map[string]struct{})
+
+	current := make(map[uint]struct{}, len(b.config.ExtractorIDList))
+	changedExtractors := make([]uint, 0)
+	for _, id := range b.config.ExtractorIDList {
+		current[id] = struct{}{}
+		if _, ok := base.extractors[id]; !ok {
+			changedExtractors = append(changedExtractors, id)
+		}
+	}
+	for id := range base.extractors {
+		if _, ok := current[id]; !ok {
+			changedExtractors = append(changedExtractors, id)
+		}
+	}
+
+	// 新增或删除的 Entity
+	err := b.pluckCanonicalNames(b.tx.Unscoped().Model(&metadata.Entity{}).
+		Where("extractor_id in ? and (created_at >= ? or deleted_at >= ?)", b.config.ExtractorIDList, since, since),
+		"name", dirty)
+	if err != nil {
+		return nil, utils.WrapError(err, "select changed entities fail")
+	}
+
+	// 新增或移除的抽取器的 Entity
+	if len(changedExtractors) != 0 {
+		err = b.pluckCanonicalNames(b.tx.Unscoped().Model(&metadata.Entity{}).
+			Where("extractor_id in ?", changedExtractors),
+			"name", dirty)
+		if err != nil {
+			return nil, utils.WrapError(err, "select entities of changed extractors fail")
+		}
+	}
+
+	// 新增或删除的 Relation
+	err = b.pluckCanonicalNames(b.tx.Unscoped().Model(&metadata.Relation{}).
+		Joins("join entities heads on heads.id = relations.head_id").
+		Where("heads.extractor_id in ? and (relations.created_at >= ? or relations.deleted_at >= ?)", b.config.ExtractorIDList, since, since),
+		"heads.name", dirty)
+	if err != nil {
+		return nil, utils.WrapError(err, "select changed relations fail")
+	}
+
+	// 来源的 Text 或 File 被删除的 Entity 和 Relation，复用的 Node 中的 Texts、Files 会过期
+	err = b.pluckCanonicalNames(b.tx.Model(&metadata.Entity{}).
+		Joins("left join texts on texts.id = entities.text_id").
+		Joins("left join files on files.id = coalesce(texts.file_id, entities.file_id)").
+		Where("entities.extractor_id in ? and (texts.deleted_at >= ? or files.deleted_at >= ?)", b.config.ExtractorIDList, since, since),
+		"entities.name", dirty)
+	if err != nil {
+		return nil, utils.WrapError(err, "select entities of deleted texts and files fail")
+	}
+
+	err = b.pluckCanonicalNames(b.tx.Model(&metadata.Relation{}).
+		Joins("join entities heads on heads.id = relations.head_id").
+		Joins("left join texts on texts.id = relations.text_id").
+		Joins("left join files on files.id = coalesce(texts.file_id, relations.file_id)").
+		Where("heads.extractor_id in ? and (texts.deleted_at >= ? or files.deleted_at >= ?)", b.config.ExtractorIDList, since, since),
+		"heads.name", dirty)
+	if err != nil {
+		return nil, utils.WrapError(err, "select relations of deleted texts and files fail")
+	}
+
+	// 新增或删除的别名：别名原本或现在可能是独立的 Node，因此别名本身和规范名都需要重新构建
+	for _, column := range []string{"alias", "canonical_name"} {
+		changed := make(map[string]struct{})
+		err = pluckNames(b.tx.Unscoped().Model(&metadata.EntityAlias{}).
+			Where("created_at >= ? or deleted_at >= ?", since, since),
+			column, changed)
+		if err != nil {
+			return nil, utils.WrapErrorf(err, "select %s of changed aliases fail", column)
+		}
+
+		for name := range changed {
+			dirty[name] = struct{}{}
+			dirty[b.canonicalName(name)] = struct{}{}
+		}
+	}
+
+	return dirty, nil
+}
+
+/*
+collectEntitiesIncrementally 在基础构建的 Node 集合上，根据发生变化的实体名计算本次构建的实体集合。
+填充 b.result.DeletedEntities 和 b.result.SelectedEntities，并将入边指向选择状态发生变化的实体的头实体加入 dirty。
+*/
+func (b *kgBuilder) collectEntitiesIncrementally(base *baseBuildInfo, dirty map[string]struct{}, humanInterventionExtractorIDList []uint) error {
+	del := make(map[string]struct{})
+	err := b.pluckCanonicalNames(b.tx.Model(&metadata.Entity{}).
+		Where("extractor_id in ? and type = ?", humanInterventionExtractorIDList, metadata.EntityTypeDel),
+		"name", del)
+	if err != nil {
+		return utils.WrapError(err, "select deleted entities fail")
+	}
+
+	b.result.DeletedEntities = del
+	b.setting.Logger.Infof("deleted node set=%v", del)
+
+	dirtyNames := make([]string, 0, len(dirty))
+	for name := range dirty {
+		dirtyNames = append(dirtyNames, name)
+	}
+	dirtyNames = b.expandAliases(dirtyNames)
+
+	alive := make(map[string]struct{})
+	for begin := 0; begin < len(dirtyNames); begin += loadBatchSize {
+		end := begin + loadBatchSize
+		if end > len(dirtyNames) {
+			end = len(dirtyNames)
+		}
+
+		err = b.pluckCanonicalNames(b.tx.Model(&metadata.Entity{}).
+			Where("extractor_id in ? and type != ? and name in ?", b.config.ExtractorIDList, metadata.EntityTypeTmp, dirtyNames[begin:end]).
+			Scopes(b.confidenceScope("entities")),
+			"name", alive)
+		if err != nil {
+			return utils.WrapError(err, "select alive entities fail")
+		}
+	}
+
+	selected := make(map[string]struct{}, len(base.nodeNames)+len(alive))
+	for name := range base.nodeNames {
+		if _, ok := dirty[name]; !ok {
+			selected[name] = struct{}{}
+		}
+	}
+	for name := range alive {
+		selected[name] = struct{}{}
+	}
+	for name := range del {
+		delete(selected, name)
+	}
+
+	b.result.SelectedEntities = selected
+	b.setting.Logger.Infof("selected node set(len=%d)", len(selected))
+
+	// 选择状态发生变化的实体，其入边的头实体需要重新构建
+	changed := make([]string, 0)
+	for name := range selected {
+		if _, ok := base.nodeNames[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	for name := range base.nodeNames {
+		if _, ok := selected[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	changed = b.expandAliases(changed)
+
+	for begin := 0; begin < len(changed); begin += loadBatchSize {
+		end := begin + loadBatchSize
+		if end > len(changed) {
+			end = len(changed)
+		}
+
+		err = b.pluckCanonicalNames(b.tx.Model(&metadata.Relation{}).
+			Joins("join entities heads on heads.id = relations.head_id and heads.deleted_at is null").
+			Joins("join entities tails on tails.id = relations.tail_id and tails.deleted_at is null").
+			Where("heads.extractor_id in ? and tails.name in ?", b.config.ExtractorIDList, changed[begin:end]),
+			"heads.name", dirty)
+		if err != nil {
+			return utils.WrapError(err, "select heads of changed entities fail")
+		}
+	}
+
+	return nil
+}
+
+/*
+reuseNodes 复制基础构建中未发生变化的 Node，并将其中的 BuildExtractor.ID 替换为本次构建的快照。
+*/
+func (b *kgBuilder) reuseNodes(base *baseBuildInfo, dirty map[string]struct{}) error {
+	var batch []metadata.Node
+
+	return b.tx.Where("build_id = ?", base.build.ID).
+		FindInBatches(&batch, nodeBatchSize, func(tx *gorm.DB, batchNum int) error {
+			if err := b.ctx.Err(); err != nil {
+				return utils.WrapError(err, "build cancelled")
+			}
+
+			nodes := make([]metadata.Node, 0, len(batch))
+			for i := 0; i < len(batch); i++ {
+				name := batch[i].Name
+
+				if _, ok := dirty[name]; ok {
+					continue
+				}
+
+				if _, ok := b.result.SelectedEntities[name]; !ok {
+					continue
+				}
+
+				node, err := b.remapNode(base, &batch[i])
+				if err != nil {
+					return utils.WrapErrorf(err, "remap node [%s] fail", name)
+				}
+
+				nodes = append(nodes, node)
+			}
+
+			if err := b.insertNodes(nodes); err != nil {
+				return utils.WrapError(err, "insert nodes fail")
+			}
+
+			b.result.ReusedNodes += len(nodes)
+			return nil
+		}).Error
+}
+
+func (b *kgBuilder) remapNode(base *baseBuildInfo, node *metadata.Node) (metadata.Node, error) {
+	remap := func(snapshotIDs []uint) ([]uint, error) {
+		ret := make([]uint, 0, len(snapshotIDs))
+		for _, snapshotID := range snapshotIDs {
+			newSnapshotID, ok := b.result.ExtractorSnapshotMap[base.snapshotExtractors[snapshotID]]
+			if !ok {
+				return nil, utils.WrapErrorf(ErrSnapshotNotFound, "snapshot [%d] of build [%d]", snapshotID, base.build.ID)
+			}
+			ret = append(ret, newSnapshotID)
+		}
+		return ret, nil
+	}
+
+	var source metadata.SchemaNodeSource
+	if err := json.Unmarshal([]byte(node.SourceJSON), &source); err != nil {
+		return metadata.Node{}, utils.WrapError(err, "unmarshal source json fail")
+	}
+
+	extractors, err := remap(source.Extractors)
+	if err != nil {
+		return metadata.Node{}, utils.WrapError(err, "remap source extractors fail")
+	}
+	source.Extractors = extractors
+
+	var out metadata.SchemaNodeOut
+	if err := json.Unmarshal([]byte(node.OutJSON), &out); err != nil {
+		return metadata.Node{}, utils.WrapError(err, "unmarshal out json fail")
+	}
+
+	for tail, edges := range out.NextNodes {
+		for i := range edges {
+			extractors, err := remap(edges[i].Extractors)
+			if err != nil {
+				return metadata.Node{}, utils.WrapErrorf(err, "remap extractors of relation [%s] to [%s] fail", edges[i].Name, tail)
+			}
+			edges[i].Extractors = extractors
+		}
+	}
+
+	return metadata.Node{
+		BuildID:    b.result.BuildID,
+		Name:       node.Name,
+		Category:   node.Category,
+		OutJSON:    out.ToJSON(),
+		SourceJSON: source.ToJSON(),
+	}, nil
+}
+
+/*
+pluckNames 查询 query 结果中 column 列的所有不重复的值，并加入 into。
+*/
+func pluckNames(query *gorm.DB, column string, into map[string]struct{}) error {
+	var names []string
+	if err := query.Distinct().Pluck(column, &names).Error; err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		into[name] = struct{}{}
+	}
+
+	return nil
+}
@@ -1,6 +1,8 @@
 package graph
 
 import (
+	"autograph-backend-controller/repository/graphstore"
+	"autograph-backend-controller/repository/metadata"
 	"context"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
@@ -24,3 +26,55 @@ func BuildKG(ctx context.Context, buildInfo *KGBuildConfig) (*KGBuildResult, err
 func TransKGToCSV(buildID uint) ([]byte, []byte, error) {
 	return transKGToCSV(&globalSetting, buildID)
 }
+
+func DropKG(ctx context.Context, buildID uint) error {
+	return dropKG(&globalSetting, ctx, buildID)
+}
+
+func DiffKG(ctx context.Context, from, to uint) (*KGDiff, error) {
+	return diffKG(&globalSetting, ctx, from, to)
+}
+
+func PublishKG(ctx context.Context, buildID uint) error {
+	return publishKG(&globalSetting, ctx, buildID)
+}
+
+func ArchiveKG(ctx context.Context, buildID uint) error {
+	return archiveKG(&globalSetting, ctx, buildID)
+}
+
+func PublishedKG(ctx context.Context) (uint, error) {
+	return publishedKG(&globalSetting, ctx)
+}
+
+func CheckKGDeletable(ctx context.Context, buildID uint) (*metadata.Build, error) {
+	return checkKGDeletable(&globalSetting, ctx, buildID)
+}
+
+func SetKGFiles(ctx context.Context, buildID uint, entityFileURL, relationFileURL string) error {
+	return setKGFiles(&globalSetting, ctx, buildID, entityFileURL, relationFileURL)
+}
+
+func WalkKG(ctx context.Context, buildID uint, fn func(node *KGNode) error) error {
+	return walkKG(&globalSetting, ctx, buildID, fn)
+}
+
+func NewVersionSource(buildID uint) graphstore.VersionSource {
+	return &versionSource{setting: &globalSetting, buildID: buildID}
+}
+
+func KGEdgeInfo(ctx context.Context, buildID uint, edges []KGEdge) (map[KGEdge]*metadata.RelationInfo, error) {
+	return kgEdgeInfo(&globalSetting, ctx, buildID, edges)
+}
+
+func KGEdgeProvenance(ctx context.Context, buildID uint, edges []KGEdge) (map[KGEdge]*KGProvenance, error) {
+	return kgEdgeProvenance(&globalSetting, ctx, buildID, edges)
+}
+
+func KGNodeEvidence(ctx context.Context, buildID uint, name string, limit int) ([]KGEvidence, error) {
+	return kgNodeEvidence(&globalSetting, ctx, buildID, name, limit)
+}
+
+func KGEdgeEvidence(ctx context.Context, buildID uint, edge KGEdge, limit int) ([]KGEvidence, error) {
+	return kgEdgeEvidence(&globalSetting, ctx, buildID, edge, limit)
+}
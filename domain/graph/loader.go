@@ -0,0 +1,260 @@
+package graph
+
+import (
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/utils"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// loadBatchSize 批量读取 Entity、Relation、File 的大小
+const loadBatchSize = 1024
+
+/*
+entityRow 为构建所需的 Entity 信息，FileID 通过 Entity.TextID 关联 Text 得到，没有来源文本时为 Entity.FileID。
+Name 为规范名，OriginName 为 Entity.Name。
+*/
+type entityRow struct {
+	ID          uint
+	Name        string
+	OriginName  string `gorm:"-"`
+	Type        uint
+	Category    string
+	ExtractorID uint
+	FileID      *uint
+}
+
+/*
+relationRow 为构建所需的 Relation 信息，FileID 通过 Relation.TextID 关联 Text 得到，没有来源文本时为 Relation.FileID，TailName 为 Relation.TailID 关联的 Entity 的规范名。
+*/
+type relationRow struct {
+	ID          uint
+	Name        string
+	Type        uint
+	ExtractorID uint
+	TextID      *uint
+	FileID      *uint
+	HeadID      uint
+	TailName    string
+	Confidence  *float64
+	CreatedAt   time.Time
+}
+
+func (b *kgBuilder) entityQuery() *gorm.DB {
+	return b.tx.Model(&metadata.Entity{}).
+		Select("entities.id, entities.name, entities.type, entities.category, entities.extractor_id, coalesce(texts.file_id, entities.file_id) as file_id").
+		Joins("left join texts on texts.id = entities.text_id and texts.deleted_at is null").
+		Where("entities.extractor_id in ?", b.config.ExtractorIDList).
+		Scopes(b.confidenceScope("entities"))
+}
+
+func (b *kgBuilder) relationQuery() *gorm.DB {
+	return b.tx.Model(&metadata.Relation{}).
+		Select("relations.id, relations.name, relations.type, relations.extractor_id, relations.text_id, coalesce(texts.file_id, relations.file_id) as file_id, relations.head_id, tails.name as tail_name, relations.confidence, relations.created_at").
+		Joins("join entities heads on heads.id = relations.head_id and heads.deleted_at is null").
+		Joins("join entities tails on tails.id = relations.tail_id and tails.deleted_at is null").
+		Joins("left join texts on texts.id = relations.text_id and texts.deleted_at is null").
+		Where("heads.extractor_id in ?", b.config.ExtractorIDList).
+		Scopes(b.confidenceScope("relations"), b.confidenceScope("heads"), b.confidenceScope("tails"))
+}
+
+/*
+confidenceScope 排除 table 中置信度低于 b.config.MinConfidence 的行，没有置信度的行视为置信度为 1。
+*/
+func (b *kgBuilder) confidenceScope(table string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if b.config.MinConfidence <= 0 {
+			return db
+		}
+
+		return db.Where(fmt.Sprintf("(%s.confidence is null or %s.confidence >= ?)", table, table), b.config.MinConfidence)
+	}
+}
+
+/*
+loadEntities 按 ID 分批读取所有属于 b.config.ExtractorIDList 的 Entity。
+*/
+func (b *kgBuilder) loadEntities() ([]entityRow, error) {
+	ret := make([]entityRow, 0)
+	var lastID uint = 0
+
+	for {
+		if err := b.ctx.Err(); err != nil {
+			return nil, utils.WrapError(err, "build cancelled")
+		}
+
+		var batch []entityRow
+		err := b.entityQuery().
+			Where("entities.id > ?", lastID).
+			Order("entities.id").
+			Limit(loadBatchSize).
+			Scan(&batch).Error
+		if err != nil {
+			return nil, utils.WrapErrorf(err, "select entities after id=[%d] fail", lastID)
+		}
+
+		b.canonicalizeEntities(batch)
+		ret = append(ret, batch...)
+
+		if len(batch) < loadBatchSize {
+			break
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+
+	return ret, nil
+}
+
+/*
+loadRelations 按 ID 分批读取所有头实体属于 b.config.ExtractorIDList 的 Relation。
+*/
+func (b *kgBuilder) loadRelations() ([]relationRow, error) {
+	ret := make([]relationRow, 0)
+	var lastID uint = 0
+
+	for {
+		if err := b.ctx.Err(); err != nil {
+			return nil, utils.WrapError(err, "build cancelled")
+		}
+
+		var batch []relationRow
+		err := b.relationQuery().
+			Where("relations.id > ?", lastID).
+			Order("relations.id").
+			Limit(loadBatchSize).
+			Scan(&batch).Error
+		if err != nil {
+			return nil, utils.WrapErrorf(err, "select relations after id=[%d] fail", lastID)
+		}
+
+		b.canonicalizeRelations(batch)
+		ret = append(ret, batch...)
+
+		if len(batch) < loadBatchSize {
+			break
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+
+	return ret, nil
+}
+
+/*
+loadEntitiesByNames 按名字分批读取属于 b.config.ExtractorIDList 且规范名在 names 中的 Entity。
+*/
+func (b *kgBuilder) loadEntitiesByNames(names []string) ([]entityRow, error) {
+	ret := make([]entityRow, 0)
+	names = b.expandAliases(names)
+
+	for begin := 0; begin < len(names); begin += loadBatchSize {
+		if err := b.ctx.Err(); err != nil {
+			return nil, utils.WrapError(err, "build cancelled")
+		}
+
+		end := begin + loadBatchSize
+		if end > len(names) {
+			end = len(names)
+		}
+
+		var batch []entityRow
+		err := b.entityQuery().
+			Where("entities.name in ?", names[begin:end]).
+			Order("entities.id").
+			Scan(&batch).Error
+		if err != nil {
+			return nil, utils.WrapError(err, "select entities by names fail")
+		}
+
+		b.canonicalizeEntities(batch)
+		ret = append(ret, batch...)
+	}
+
+	return ret, nil
+}
+
+/*
+loadRelationsByHeadNames 按名字分批读取头实体属于 b.config.ExtractorIDList 且头实体规范名在 names 中的 Relation。
+*/
+func (b *kgBuilder) loadRelationsByHeadNames(names []string) ([]relationRow, error) {
+	ret := make([]relationRow, 0)
+	names = b.expandAliases(names)
+
+	for begin := 0; begin < len(names); begin += loadBatchSize {
+		if err := b.ctx.Err(); err != nil {
+			return nil, utils.WrapError(err, "build cancelled")
+		}
+
+		end := begin + loadBatchSize
+		if end > len(names) {
+			end = len(names)
+		}
+
+		var batch []relationRow
+		err := b.relationQuery().
+			Where("heads.name in ?", names[begin:end]).
+			Order("relations.id").
+			Scan(&batch).Error
+		if err != nil {
+			return nil, utils.WrapError(err, "select relations by head names fail")
+		}
+
+		b.canonicalizeRelations(batch)
+		ret = append(ret, batch...)
+	}
+
+	return ret, nil
+}
+
+/*
+loadFiles 读取 entities 与 relations 涉及的所有 File，返回 File.ID -> FileInfo。
+*/
+func (b *kgBuilder) loadFiles(entities []entityRow, relations []relationRow) (map[uint]metadata.FileInfo, error) {
+	fileIDSet := make(map[uint]struct{})
+	for i := 0; i < len(entities); i++ {
+		if entities[i].FileID != nil {
+			fileIDSet[*entities[i].FileID] = struct{}{}
+		}
+	}
+	for i := 0; i < len(relations); i++ {
+		if relations[i].FileID != nil {
+			fileIDSet[*relations[i].FileID] = struct{}{}
+		}
+	}
+
+	fileIDs := make([]uint, 0, len(fileIDSet))
+	for id := range fileIDSet {
+		fileIDs = append(fileIDs, id)
+	}
+
+	ret := make(map[uint]metadata.FileInfo, len(fileIDs))
+
+	for begin := 0; begin < len(fileIDs); begin += loadBatchSize {
+		end := begin + loadBatchSize
+		if end > len(fileIDs) {
+			end = len(fileIDs)
+		}
+
+		var files []metadata.File
+		err := b.tx.Select("id", "name", "type").Find(&files, fileIDs[begin:end]).Error
+		if err != nil {
+			return nil, utils.WrapError(err, "select files fail")
+		}
+
+		for _, file := range files {
+			ret[file.ID] = metadata.FileInfo{
+				FileID:   file.ID,
+				FileName: fmt.Sprintf("%s.%s", file.Name, file.Type),
+				FileType: file.Type,
+			}
+		}
+	}
+
+	for _, id := range fileIDs {
+		if _, ok := ret[id]; !ok {
+			return nil, utils.WrapErrorf(gorm.ErrRecordNotFound, "file with id=[%d] not found", id)
+		}
+	}
+
+	return ret, nil
+}
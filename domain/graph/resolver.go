@@ -0,0 +1,323 @@
+package graph
+
+import (
+	"autograph-backend-controller/utils"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+)
+
+const (
+	RelationResolverMajority           = "majority"
+	RelationResolverWeighted           = "weighted"
+	RelationResolverLatestIntervention = "latest_intervention"
+	RelationResolverKeepAll            = "keep_all"
+)
+
+var ErrUnknownRelationResolver = errors.New("unknown relation resolver")
+
+/*
+RelationRecord 是一条 Relation 对某个关系名的一次表态。
+
+	RelationID Relation.ID；
+	ExtractorID 抽取出该 Relation 的 Extractor.ID；
+	TextID 该 Relation 来源的句子，可能为空；
+	FileID 该 Relation 来源的文件，可能为空；
+	IsAdd 为 false 时表示删除该关系；
+	IsIntervention 抽取器是否为人为干预；
+	Confidence 置信度，Relation 没有置信度时为 1；
+	CreatedAt Relation 的创建时间；
+*/
+type RelationRecord struct {
+	RelationID     uint
+	ExtractorID    uint
+	TextID         *uint
+	FileID         *uint
+	IsAdd          bool
+	IsIntervention bool
+	Confidence     float64
+	CreatedAt      time.Time
+}
+
+/*
+RelationCandidate 是同一对头尾实体之间的一个候选关系名，以及所有与之相关的 RelationRecord。
+*/
+type RelationCandidate struct {
+	Name    string
+	Records []RelationRecord
+}
+
+/*
+RelationResolver 决定同一对头尾实体之间存在多个关系名时，最终保留哪些关系名。
+
+	Name 策略名，记录在 Build 中；
+	Param 策略参数的 JSON，记录在 Build 中，与 Name 一起用于复现构建结果，序列化失败时返回错误；
+	Resolve 返回保留的关系名，按优先级从高到低排列，全部舍弃时返回空。candidates 按 Name 升序排列。
+*/
+type RelationResolver interface {
+	Name() string
+	Param() (string, error)
+	Resolve(candidates []RelationCandidate) []string
+}
+
+/*
+NewRelationResolver 通过策略名和参数 JSON 创建 RelationResolver，name 为空时使用默认的 majority 策略。
+*/
+func NewRelationResolver(name string, param string) (RelationResolver, error) {
+	switch name {
+	case "", RelationResolverMajority:
+		return &majorityResolver{}, nil
+	case RelationResolverWeighted:
+		return newWeightedResolver(param)
+	case RelationResolverLatestIntervention:
+		return &latestInterventionResolver{}, nil
+	case RelationResolverKeepAll:
+		return &keepAllResolver{}, nil
+	}
+
+	return nil, ErrUnknownRelationResolver
+}
+
+/*
+scoredName 为关系名及其得分，用于排序。
+*/
+type scoredName struct {
+	name  string
+	score float64
+}
+
+/*
+rankNames 将得分大于零的关系名按得分降序排列，得分相同时按关系名升序排列。
+*/
+func rankNames(scored []scoredName) []string {
+	sort.SliceStable(scored, func(i, j int) bool {
+		if scored[i].score != scored[j].score {
+			return scored[i].score > scored[j].score
+		}
+		return scored[i].name < scored[j].name
+	})
+
+	ret := make([]string, 0, len(scored))
+	for _, s := range scored {
+		if s.score > 0 {
+			ret = append(ret, s.name)
+		}
+	}
+	return ret
+}
+
+func hasDelete(records []RelationRecord) bool {
+	for _, record := range records {
+		if !record.IsAdd {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+majorityVotes 统计未被删除的关系名的记录数（即文件数）并排序，出现过删除的关系名被排除。
+*/
+func majorityVotes(candidates []RelationCandidate) []string {
+	scored := make([]scoredName, 0, len(candidates))
+	for _, candidate := range candidates {
+		if hasDelete(candidate.Records) {
+			continue
+		}
+		scored = append(scored, scoredName{name: candidate.Name, score: float64(len(candidate.Records))})
+	}
+	return rankNames(scored)
+}
+
+/*
+majorityResolver 按文件数投票，保留票数最多的关系名，任何一次删除都会否决该关系名。
+*/
+type majorityResolver struct{}
+
+func (r *majorityResolver) Name() string {
+	return RelationResolverMajority
+}
+
+func (r *majorityResolver) Param() (string, error) {
+	return "", nil
+}
+
+func (r *majorityResolver) Resolve(candidates []RelationCandidate) []string {
+	ret := majorityVotes(candidates)
+	if len(ret) > 1 {
+		ret = ret[:1]
+	}
+	return ret
+}
+
+/*
+weightedResolverParam 为 weighted 策略的参数。
+
+	Weights Extractor.ID -> 该抽取器的可信度权重；
+	DefaultWeight 未在 Weights 中的抽取器的权重，为空时为 1；
+*/
+type weightedResolverParam struct {
+	Weights       map[uint]float64 `json:"weights"`
+	DefaultWeight *float64         `json:"default_weight,omitempty"`
+}
+
+/*
+weightedResolver 按抽取器的可信度与 Relation 的置信度的乘积加权投票，增加记录加分、删除记录减分，保留得分最高且为正的关系名。
+*/
+type weightedResolver struct {
+	param weightedResolverParam
+}
+
+func newWeightedResolver(param string) (*weightedResolver, error) {
+	ret := weightedResolver{}
+	if len(param) != 0 {
+		if err := json.Unmarshal([]byte(param), &ret.param); err != nil {
+			return nil, err
+		}
+	}
+
+	if ret.param.Weights == nil {
+		ret.param.Weights = make(map[uint]float64)
+	}
+	if ret.param.DefaultWeight == nil {
+		defaultWeight := 1.0
+		ret.param.DefaultWeight = &defaultWeight
+	}
+
+	return &ret, nil
+}
+
+func (r *weightedResolver) Name() string {
+	return RelationResolverWeighted
+}
+
+func (r *weightedResolver) Param() (string, error) {
+	return toJSON(&r.param)
+}
+
+func (r *weightedResolver) weight(extractorID uint) float64 {
+	if w, ok := r.param.Weights[extractorID]; ok {
+		return w
+	}
+	return *r.param.DefaultWeight
+}
+
+func (r *weightedResolver) Resolve(candidates []RelationCandidate) []string {
+	scored := make([]scoredName, 0, len(candidates))
+	for _, candidate := range candidates {
+		score := 0.0
+		for _, record := range candidate.Records {
+			if record.IsAdd {
+				score += r.weight(record.ExtractorID) * record.Confidence
+			} else {
+				score -= r.weight(record.ExtractorID) * record.Confidence
+			}
+		}
+		scored = append(scored, scoredName{name: candidate.Name, score: score})
+	}
+
+	ret := rankNames(scored)
+	if len(ret) > 1 {
+		ret = ret[:1]
+	}
+	return ret
+}
+
+/*
+latestInterventionResolver 以每个关系名最近一次人为干预为准：最近一次为删除时否决该关系名，为增加时该关系名胜出，
+多个关系名胜出时取干预时间最近的。没有关系名通过人为干预胜出时，在未被否决的关系名中按 majority 策略投票。
+*/
+type latestInterventionResolver struct{}
+
+func (r *latestInterventionResolver) Name() string {
+	return RelationResolverLatestIntervention
+}
+
+func (r *latestInterventionResolver) Param() (string, error) {
+	return "", nil
+}
+
+func (r *latestInterventionResolver) Resolve(candidates []RelationCandidate) []string {
+	var (
+		winner      string
+		winnerFound bool
+		winnerLast  RelationRecord
+	)
+	rest := make([]RelationCandidate, 0, len(candidates))
+
+	for _, candidate := range candidates {
+		var (
+			last  RelationRecord
+			found bool
+		)
+
+		for _, record := range candidate.Records {
+			if !record.IsIntervention {
+				continue
+			}
+
+			if !found || isLaterRecord(record, last) {
+				last = record
+				found = true
+			}
+		}
+
+		if !found {
+			rest = append(rest, candidate)
+			continue
+		}
+
+		if !last.IsAdd {
+			continue
+		}
+
+		if !winnerFound || isLaterRecord(last, winnerLast) {
+			winner = candidate.Name
+			winnerLast = last
+			winnerFound = true
+		}
+	}
+
+	if winnerFound {
+		return []string{winner}
+	}
+
+	ret := majorityVotes(rest)
+	if len(ret) > 1 {
+		ret = ret[:1]
+	}
+	return ret
+}
+
+func isLaterRecord(a, b RelationRecord) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.RelationID > b.RelationID
+}
+
+/*
+keepAllResolver 保留所有未被删除的关系名，作为头尾实体之间的多条边，按文件数降序排列。
+*/
+type keepAllResolver struct{}
+
+func (r *keepAllResolver) Name() string {
+	return RelationResolverKeepAll
+}
+
+func (r *keepAllResolver) Param() (string, error) {
+	return "", nil
+}
+
+func (r *keepAllResolver) Resolve(candidates []RelationCandidate) []string {
+	return majorityVotes(candidates)
+}
+
+func toJSON(v interface{}) (string, error) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return "", utils.WrapError(err, "json marshal fail")
+	}
+	return string(bytes), nil
+}
@@ -0,0 +1,107 @@
+package graph
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRelationResolver(t *testing.T) {
+	base := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var nextID uint = 0
+	record := func(extractorID uint, isAdd, isIntervention bool, minute int) RelationRecord {
+		nextID++
+		return RelationRecord{
+			RelationID:     nextID,
+			ExtractorID:    extractorID,
+			IsAdd:          isAdd,
+			IsIntervention: isIntervention,
+			Confidence:     1,
+			CreatedAt:      base.Add(time.Duration(minute) * time.Minute),
+		}
+	}
+
+	/*
+		同一对头尾实体之间：
+		"父亲" 由模型 1 抽取 3 次；
+		"老师" 由模型 2 抽取 2 次，之后被人为干预 3 删除，又被人为干预 3 增加；
+		"同事" 由模型 2 抽取 1 次，之后被人为干预 3 删除；
+	*/
+	candidates := []RelationCandidate{
+		{Name: "同事", Records: []RelationRecord{
+			record(2, true, false, 0),
+			record(3, false, true, 10),
+		}},
+		{Name: "父亲", Records: []RelationRecord{
+			record(1, true, false, 0),
+			record(1, true, false, 1),
+			record(1, true, false, 2),
+		}},
+		{Name: "老师", Records: []RelationRecord{
+			record(2, true, false, 0),
+			record(2, true, false, 1),
+			record(3, false, true, 5),
+			record(3, true, true, 20),
+		}},
+	}
+
+	t.Run("majority", func(t *testing.T) {
+		resolver, err := NewRelationResolver("", "")
+		require.Nil(t, err)
+		assert.Equal(t, RelationResolverMajority, resolver.Name())
+		assert.Equal(t, []string{"父亲"}, resolver.Resolve(candidates))
+	})
+
+	t.Run("weighted", func(t *testing.T) {
+		resolver, err := NewRelationResolver(RelationResolverWeighted, `{"weights":{"1":0.5,"3":10}}`)
+		require.Nil(t, err)
+		param, err := resolver.Param()
+		require.Nil(t, err)
+		assert.Equal(t, `{"weights":{"1":0.5,"3":10},"default_weight":1}`, param)
+
+		// 父亲=1.5，老师=2-10+10=2，同事=1-10=-9
+		assert.Equal(t, []string{"老师"}, resolver.Resolve(candidates))
+
+		// 只有被删除的关系名时全部舍弃
+		assert.Empty(t, resolver.Resolve(candidates[:1]))
+
+		// 置信度低的记录得分低：父亲=0.5*3*0.1=0.15，朋友=1*0.9=0.9
+		lowConfidence := RelationCandidate{Name: "父亲"}
+		for _, r := range candidates[1].Records {
+			r.Confidence = 0.1
+			lowConfidence.Records = append(lowConfidence.Records, r)
+		}
+		friend := RelationCandidate{Name: "朋友", Records: []RelationRecord{record(2, true, false, 0)}}
+		friend.Records[0].Confidence = 0.9
+		assert.Equal(t, []string{"朋友"}, resolver.Resolve([]RelationCandidate{lowConfidence, friend}))
+	})
+
+	t.Run("latest intervention", func(t *testing.T) {
+		resolver, err := NewRelationResolver(RelationResolverLatestIntervention, "")
+		require.Nil(t, err)
+		assert.Equal(t, []string{"老师"}, resolver.Resolve(candidates))
+
+		// 没有通过人为干预胜出的关系名时，按 majority 投票，被人为干预删除的关系名不参与
+		assert.Equal(t, []string{"父亲"}, resolver.Resolve(candidates[:2]))
+		assert.Empty(t, resolver.Resolve(candidates[:1]))
+	})
+
+	t.Run("keep all", func(t *testing.T) {
+		resolver, err := NewRelationResolver(RelationResolverKeepAll, "")
+		require.Nil(t, err)
+		assert.Equal(t, []string{"父亲"}, resolver.Resolve(candidates))
+
+		candidates := append([]RelationCandidate{{Name: "朋友", Records: []RelationRecord{record(1, true, false, 0)}}}, candidates...)
+		assert.Equal(t, []string{"父亲", "朋友"}, resolver.Resolve(candidates))
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := NewRelationResolver("random", "")
+		assert.ErrorIs(t, err, ErrUnknownRelationResolver)
+
+		_, err = NewRelationResolver(RelationResolverWeighted, "{")
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,168 @@
+package graph
+
+import (
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/utils"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrBuildNotReady    = errors.New("build is not ready")
+	ErrBuildPublished   = errors.New("build is published")
+	ErrNoPublishedBuild = errors.New("no published build")
+)
+
+/*
+checkBuildReady 检查构建是否已经完成。由构建任务产生的构建在任务完成前不能发布或删除，直接调用 BuildKG 产生的构建没有任务记录。
+*/
+func checkBuildReady(tx *gorm.DB, buildID uint) error {
+	var count int64
+	err := tx.Model(&metadata.BuildJob{}).
+		Where("build_id = ? and status <> ?", buildID, metadata.BuildJobStatusDone).
+		Count(&count).Error
+	if err != nil {
+		return utils.WrapErrorf(err, "count unfinished jobs of build [%d] fail", buildID)
+	}
+
+	if count != 0 {
+		return utils.WrapErrorf(ErrBuildNotReady, "build [%d] has %d unfinished job(s)", buildID, count)
+	}
+
+	return nil
+}
+
+/*
+publishKG 发布一个版本，原先已发布的版本变为已归档。构建记录加锁读取，与并发的发布、归档互斥。
+*/
+func publishKG(setting *KGSetting, ctx context.Context, buildID uint) error {
+	return setting.GetMetadataDatabase().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var build metadata.Build
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&build, buildID).Error; err != nil {
+			return utils.WrapErrorf(err, "select build [%d] fail", buildID)
+		}
+
+		if build.Status == metadata.BuildStatusPublished {
+			return nil
+		}
+
+		if err := checkBuildReady(tx, buildID); err != nil {
+			return err
+		}
+
+		err := tx.Model(&metadata.Build{}).
+			Where("status = ?", metadata.BuildStatusPublished).
+			Update("status", metadata.BuildStatusArchived).Error
+		if err != nil {
+			return utils.WrapError(err, "archive published builds fail")
+		}
+
+		err = tx.Model(&metadata.Build{}).
+			Where("id = ?", buildID).
+			Update("status", metadata.BuildStatusPublished).Error
+		if err != nil {
+			return utils.WrapErrorf(err, "publish build [%d] fail", buildID)
+		}
+
+		return nil
+	})
+}
+
+/*
+archiveKG 归档一个版本。归档已发布的版本后将没有已发布的版本。与 publishKG 相同，构建任务未完成的版本不能归档。
+*/
+func archiveKG(setting *KGSetting, ctx context.Context, buildID uint) error {
+	return setting.GetMetadataDatabase().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var build metadata.Build
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&build, buildID).Error; err != nil {
+			return utils.WrapErrorf(err, "select build [%d] fail", buildID)
+		}
+
+		if build.Status == metadata.BuildStatusArchived {
+			return nil
+		}
+
+		if err := checkBuildReady(tx, buildID); err != nil {
+			return err
+		}
+
+		err := tx.Model(&metadata.Build{}).
+			Where("id = ?", buildID).
+			Update("status", metadata.BuildStatusArchived).Error
+		if err != nil {
+			return utils.WrapErrorf(err, "archive build [%d] fail", buildID)
+		}
+
+		return nil
+	})
+}
+
+/*
+publishedKG 返回已发布版本的构建号，没有已发布的版本时返回 ErrNoPublishedBuild。
+*/
+func publishedKG(setting *KGSetting, ctx context.Context) (uint, error) {
+	var build metadata.Build
+	err := setting.GetMetadataDatabase().WithContext(ctx).
+		Select("id").
+		Where("status = ?", metadata.BuildStatusPublished).
+		Order("id desc").
+		Take(&build).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, utils.WrapError(ErrNoPublishedBuild, "select published build fail")
+	}
+	if err != nil {
+		return 0, utils.WrapError(err, "select published build fail")
+	}
+
+	return build.ID, nil
+}
+
+/*
+checkKGDeletable 检查一个版本能否被删除，已发布的版本和构建任务未结束的版本不能删除，返回该版本的构建记录。
+*/
+func checkKGDeletable(setting *KGSetting, ctx context.Context, buildID uint) (*metadata.Build, error) {
+	db := setting.GetMetadataDatabase().WithContext(ctx)
+
+	var build metadata.Build
+	if err := db.Take(&build, buildID).Error; err != nil {
+		return nil, utils.WrapErrorf(err, "select build [%d] fail", buildID)
+	}
+
+	if build.Status == metadata.BuildStatusPublished {
+		return nil, utils.WrapErrorf(ErrBuildPublished, "build [%d] cannot be deleted", buildID)
+	}
+
+	var count int64
+	err := db.Model(&metadata.BuildJob{}).
+		Where("build_id = ? and status in ?", buildID, []uint{metadata.BuildJobStatusWaiting, metadata.BuildJobStatusRunning}).
+		Count(&count).Error
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "count running jobs of build [%d] fail", buildID)
+	}
+
+	if count != 0 {
+		return nil, utils.WrapErrorf(ErrBuildNotReady, "build [%d] is still being built", buildID)
+	}
+
+	return &build, nil
+}
+
+/*
+setKGFiles 记录一个版本在 filesave 中的 CSV 地址，地址为空表示文件不存在或已被删除。
+*/
+func setKGFiles(setting *KGSetting, ctx context.Context, buildID uint, entityFileURL, relationFileURL string) error {
+	err := setting.GetMetadataDatabase().WithContext(ctx).
+		Model(&metadata.Build{}).
+		Where("id = ?", buildID).
+		Updates(map[string]interface{}{
+			"entity_file_url":   entityFileURL,
+			"relation_file_url": relationFileURL,
+		}).Error
+	if err != nil {
+		return utils.WrapErrorf(err, "update csv urls of build [%d] fail", buildID)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package graph
+
+import (
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/repository/metadata"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestKGVersion(t *testing.T) {
+	logging.SetDefaultConfig(logging.GenerateTestConfig(t))
+
+	database, err := metadata.CreateDatabase(metadata.GenerateTestConfig())
+	require.Nil(t, err)
+
+	setting := KGSetting{
+		GetMetadataDatabase: func() *gorm.DB {
+			return database
+		},
+		Logger: logging.NewLogger(),
+	}
+
+	ctx := context.TODO()
+
+	extractor := metadata.Extractor{
+		Name: "VersionExtractor",
+		Type: metadata.ExtractorTypeModel,
+	}
+	require.Nil(t, database.Create(&extractor).Error)
+	require.Nil(t, database.Create(&metadata.Entity{Name: "Version-A", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID}).Error)
+
+	build := func() uint {
+		res, err := buildKG(&setting, ctx, &KGBuildConfig{
+			ExtractorIDList: []uint{extractor.ID},
+			Desc:            "TestKGVersion",
+		})
+		require.Nil(t, err)
+		return res.BuildID
+	}
+
+	status := func(buildID uint) uint {
+		var b metadata.Build
+		require.Nil(t, database.Take(&b, buildID).Error)
+		return b.Status
+	}
+
+	first, second := build(), build()
+	assert.Equal(t, metadata.BuildStatusDraft, status(first))
+
+	// 发布新版本时原先发布的版本被归档
+	require.Nil(t, publishKG(&setting, ctx, first))
+	published, err := publishedKG(&setting, ctx)
+	require.Nil(t, err)
+	assert.Equal(t, first, published)
+
+	require.Nil(t, publishKG(&setting, ctx, second))
+	published, err = publishedKG(&setting, ctx)
+	require.Nil(t, err)
+	assert.Equal(t, second, published)
+	assert.Equal(t, metadata.BuildStatusArchived, status(first))
+
+	// 已发布的版本不能删除
+	_, err = checkKGDeletable(&setting, ctx, second)
+	assert.ErrorIs(t, err, ErrBuildPublished)
+
+	require.Nil(t, archiveKG(&setting, ctx, second))
+	_, err = publishedKG(&setting, ctx)
+	assert.ErrorIs(t, err, ErrNoPublishedBuild)
+
+	// 构建任务未完成的版本不能发布、归档或删除
+	third := build()
+	job := metadata.BuildJob{Desc: "TestKGVersion", Status: metadata.BuildJobStatusRunning, BuildID: &third}
+	require.Nil(t, database.Create(&job).Error)
+	assert.ErrorIs(t, publishKG(&setting, ctx, third), ErrBuildNotReady)
+	assert.ErrorIs(t, archiveKG(&setting, ctx, third), ErrBuildNotReady)
+	assert.Equal(t, metadata.BuildStatusDraft, status(third))
+	_, err = checkKGDeletable(&setting, ctx, third)
+	assert.ErrorIs(t, err, ErrBuildNotReady)
+	require.Nil(t, database.Model(&job).Update("status", metadata.BuildJobStatusDone).Error)
+	require.Nil(t, publishKG(&setting, ctx, third))
+	require.Nil(t, archiveKG(&setting, ctx, third))
+
+	require.Nil(t, setKGFiles(&setting, ctx, first, "entity.csv", "relation.csv"))
+	deletable, err := checkKGDeletable(&setting, ctx, first)
+	require.Nil(t, err)
+	assert.Equal(t, "entity.csv", deletable.EntityFileURL)
+	assert.Equal(t, "relation.csv", deletable.RelationFileURL)
+
+	require.Nil(t, dropKG(&setting, ctx, first))
+	assert.ErrorIs(t, publishKG(&setting, ctx, first), gorm.ErrRecordNotFound)
+
+	var count int64
+	require.Nil(t, database.Model(&metadata.Node{}).Where("build_id = ?", first).Count(&count).Error)
+	assert.Zero(t, count)
+}
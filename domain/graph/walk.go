@@ -0,0 +1,118 @@
+package graph
+
+import (
+	"autograph-backend-controller/repository/graphstore"
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/utils"
+	"context"
+	"encoding/json"
+	"gorm.io/gorm"
+	"sort"
+)
+
+/*
+KGNode 为一次构建中的一个节点及其出边，Source 为 SchemaNodeSource 的 JSON。
+*/
+type KGNode struct {
+	Name     string
+	Category string
+	Source   string
+	Edges    []KGOutEdge
+}
+
+/*
+KGOutEdge 为节点的一条出边，旧版本构建的边没有置信度。
+*/
+type KGOutEdge struct {
+	Relation   string
+	Tail       string
+	Confidence *float64
+}
+
+/*
+walkKG 分批读取一次构建的所有 Node，并依次调用 fn，不会一次性将整个图谱读入内存。指向自身的边会被忽略，出边按尾实体名和关系名排序。
+*/
+func walkKG(setting *KGSetting, ctx context.Context, buildID uint, fn func(node *KGNode) error) error {
+	var batch []metadata.Node
+	err := setting.GetMetadataDatabase().WithContext(ctx).
+		Select("id", "name", "category", "out_json", "source_json").
+		Where("build_id = ?", buildID).
+		FindInBatches(&batch, nodeBatchSize, func(tx *gorm.DB, batchNum int) error {
+			for i := 0; i < len(batch); i++ {
+				var out metadata.SchemaNodeOut
+				if err := json.Unmarshal([]byte(batch[i].OutJSON), &out); err != nil {
+					return utils.WrapErrorf(err, "json unmarshal out-rel of node [%#v] fail", batch[i].Name)
+				}
+
+				node := KGNode{
+					Name:     batch[i].Name,
+					Category: batch[i].Category,
+					Source:   batch[i].SourceJSON,
+					Edges:    make([]KGOutEdge, 0),
+				}
+
+				for tail, edges := range out.NextNodes {
+					if tail == node.Name {
+						continue
+					}
+
+					for _, edge := range edges {
+						node.Edges = append(node.Edges, KGOutEdge{
+							Relation:   edge.Name,
+							Tail:       tail,
+							Confidence: edge.Confidence,
+						})
+					}
+				}
+				sort.Slice(node.Edges, func(a, b int) bool {
+					ea, eb := &node.Edges[a], &node.Edges[b]
+					return ea.Tail < eb.Tail || ea.Tail == eb.Tail && ea.Relation < eb.Relation
+				})
+
+				if err := fn(&node); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return utils.WrapErrorf(err, "walk nodes of build [%d] fail", buildID)
+	}
+
+	return nil
+}
+
+/*
+versionSource 从 Node 读取一次构建的实体和关系，用于导入图谱存储
+*/
+type versionSource struct {
+	setting *KGSetting
+	buildID uint
+}
+
+func (s *versionSource) Entities(ctx context.Context, fn func(entity *graphstore.Entity) error) error {
+	return walkKG(s.setting, ctx, s.buildID, func(node *KGNode) error {
+		return fn(&graphstore.Entity{
+			Name:     node.Name,
+			Category: node.Category,
+			Source:   node.Source,
+		})
+	})
+}
+
+func (s *versionSource) Relations(ctx context.Context, fn func(relation *graphstore.Relation) error) error {
+	return walkKG(s.setting, ctx, s.buildID, func(node *KGNode) error {
+		for _, edge := range node.Edges {
+			err := fn(&graphstore.Relation{
+				Head:       node.Name,
+				Name:       edge.Relation,
+				Tail:       edge.Tail,
+				Confidence: edge.Confidence,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,82 @@
+package graph
+
+import (
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/repository/metadata"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"sort"
+	"testing"
+)
+
+func TestWalkKG(t *testing.T) {
+	logging.SetDefaultConfig(logging.GenerateTestConfig(t))
+
+	database, err := metadata.CreateDatabase(metadata.GenerateTestConfig())
+	require.Nil(t, err)
+
+	setting := KGSetting{
+		GetMetadataDatabase: func() *gorm.DB {
+			return database
+		},
+		Logger: logging.NewLogger(),
+	}
+
+	extractor := metadata.Extractor{
+		Name: "WalkExtractor",
+		Type: metadata.ExtractorTypeModel,
+	}
+	require.Nil(t, database.Create(&extractor).Error)
+
+	// A --属于--> B，A --自身--> A
+	entity := []metadata.Entity{
+		{Name: "Walk-A", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID, Category: metadata.EntityCategoryPerson},
+		{Name: "Walk-B", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID},
+	}
+	require.Nil(t, database.Create(&entity).Error)
+
+	relation := []metadata.Relation{
+		{Name: "属于", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID, HeadID: entity[0].ID, TailID: entity[1].ID},
+		{Name: "自身", Type: metadata.EntityTypeAdd, ExtractorID: extractor.ID, HeadID: entity[0].ID, TailID: entity[0].ID},
+	}
+	require.Nil(t, database.Create(&relation).Error)
+
+	res, err := buildKG(&setting, context.TODO(), &KGBuildConfig{
+		ExtractorIDList: []uint{extractor.ID},
+		Desc:            "TestWalkKG",
+	})
+	require.Nil(t, err)
+
+	nodes := make([]KGNode, 0)
+	err = walkKG(&setting, context.TODO(), res.BuildID, func(node *KGNode) error {
+		nodes = append(nodes, *node)
+		return nil
+	})
+	require.Nil(t, err)
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	require.Len(t, nodes, 2)
+
+	assert.Equal(t, "Walk-A", nodes[0].Name)
+	assert.Equal(t, metadata.EntityCategoryPerson, nodes[0].Category)
+	assert.NotEmpty(t, nodes[0].Source)
+	require.Len(t, nodes[0].Edges, 1)
+	assert.Equal(t, "属于", nodes[0].Edges[0].Relation)
+	assert.Equal(t, "Walk-B", nodes[0].Edges[0].Tail)
+	require.NotNil(t, nodes[0].Edges[0].Confidence)
+
+	assert.Equal(t, "Walk-B", nodes[1].Name)
+	assert.Empty(t, nodes[1].Edges)
+
+	// fn 返回的错误会中止遍历
+	walkErr := assert.AnError
+	visited := 0
+	err = walkKG(&setting, context.TODO(), res.BuildID, func(node *KGNode) error {
+		visited++
+		return walkErr
+	})
+	assert.ErrorIs(t, err, walkErr)
+	assert.Equal(t, 1, visited)
+}
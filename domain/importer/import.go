@@ -0,0 +1,121 @@
+package importer
+
+import (
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/utils"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+)
+
+// importBatchSize 批量写入 Entity、Relation 的大小
+const importBatchSize = 1024
+
+var ErrNoTriple = errors.New("no triple to import")
+
+/*
+ImportConfig 导入的配置。
+
+	Name、Desc 新建的抽取器的名称和描述；
+	Triples 要导入的三元组；
+	FileID 非空时，导入的 Entity 与 Relation 的 FileID 为该文件，构建时即可溯源到该文件。三元组没有来源句子，因此不创建 Text；
+*/
+type ImportConfig struct {
+	Name    string
+	Desc    string
+	Triples []Triple
+	FileID  *uint
+}
+
+type ImportResult struct {
+	ExtractorID   uint
+	EntityCount   int
+	RelationCount int
+}
+
+/*
+importTriples 在一个事务中创建类型为 Imported 的抽取器，并写入三元组对应的 Entity 与 Relation。同名的实体只写入一个 Entity，类别取第一个非空的类别。
+*/
+func importTriples(setting *ImportSetting, ctx context.Context, config *ImportConfig) (*ImportResult, error) {
+	if len(config.Triples) == 0 {
+		return nil, ErrNoTriple
+	}
+
+	ret := ImportResult{}
+
+	err := setting.GetMetadataDatabase().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if config.FileID != nil {
+			var file metadata.File
+			if err := tx.Select("id").Take(&file, *config.FileID).Error; err != nil {
+				return utils.WrapErrorf(err, "select file [%d] fail", *config.FileID)
+			}
+		}
+
+		extractor := metadata.Extractor{
+			Name: config.Name,
+			Desc: config.Desc,
+			Type: metadata.ExtractorTypeImported,
+		}
+		if err := tx.Create(&extractor).Error; err != nil {
+			return utils.WrapError(err, "create extractor fail")
+		}
+		ret.ExtractorID = extractor.ID
+
+		entityIndex := make(map[string]int) // 实体名 -> entities 中的下标
+		entities := make([]metadata.Entity, 0)
+		addEntity := func(name, category string) {
+			if i, ok := entityIndex[name]; ok {
+				if len(entities[i].Category) == 0 {
+					entities[i].Category = category
+				}
+				return
+			}
+
+			entityIndex[name] = len(entities)
+			entities = append(entities, metadata.Entity{
+				Name:        name,
+				Type:        metadata.EntityTypeAdd,
+				Category:    category,
+				ExtractorID: extractor.ID,
+				FileID:      config.FileID,
+			})
+		}
+
+		for _, triple := range config.Triples {
+			addEntity(triple.Head, triple.HeadCategory)
+			addEntity(triple.Tail, triple.TailCategory)
+		}
+
+		if err := tx.CreateInBatches(&entities, importBatchSize).Error; err != nil {
+			return utils.WrapError(err, "create entities fail")
+		}
+		ret.EntityCount = len(entities)
+
+		relations := make([]metadata.Relation, 0, len(config.Triples))
+		for _, triple := range config.Triples {
+			relations = append(relations, metadata.Relation{
+				Name:        triple.Relation,
+				Type:        metadata.EntityTypeAdd,
+				Confidence:  triple.Confidence,
+				ExtractorID: extractor.ID,
+				FileID:      config.FileID,
+				HeadID:      entities[entityIndex[triple.Head]].ID,
+				TailID:      entities[entityIndex[triple.Tail]].ID,
+			})
+		}
+
+		if err := tx.CreateInBatches(&relations, importBatchSize).Error; err != nil {
+			return utils.WrapError(err, "create relations fail")
+		}
+		ret.RelationCount = len(relations)
+
+		return nil
+	})
+	if err != nil {
+		return nil, utils.WrapError(err, "import triples fail")
+	}
+
+	setting.Logger.Infof("imported extractor [%d] with %d entities and %d relations", ret.ExtractorID, ret.EntityCount, ret.RelationCount)
+
+	return &ret, nil
+}
@@ -0,0 +1,87 @@
+package importer
+
+import (
+	"autograph-backend-controller/domain/graph"
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/repository/metadata"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+	logging.SetDefaultConfig(logging.GenerateTestConfig(t))
+
+	database, err := metadata.CreateDatabase(metadata.GenerateTestConfig())
+	require.Nil(t, err)
+
+	setting := ImportSetting{
+		GetMetadataDatabase: func() *gorm.DB {
+			return database
+		},
+		Logger: logging.NewLogger(),
+	}
+	graph.Init(&graph.KGSetting{
+		GetMetadataDatabase: setting.GetMetadataDatabase,
+		Logger:              logging.NewLogger(),
+	})
+
+	ctx := context.TODO()
+
+	file := metadata.File{Type: "csv", Name: "import-kb"}
+	require.Nil(t, database.Create(&file).Error)
+
+	triples, err := ParseTriples(FormatCSV, strings.NewReader("head,relation,tail,head_category\nImport-A,认识,Import-B,person\nImport-A,住在,Import-C,\n"))
+	require.Nil(t, err)
+
+	res, err := importTriples(&setting, ctx, &ImportConfig{
+		Name:    "TestImport",
+		Triples: triples,
+		FileID:  &file.ID,
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 3, res.EntityCount) // 同名的实体只写入一个
+	assert.Equal(t, 2, res.RelationCount)
+
+	// 导入的三元组没有来源句子，不关联 Text，只关联来源文件
+	var unlinked int64
+	require.Nil(t, database.Model(&metadata.Entity{}).
+		Where("extractor_id = ? and (text_id is not null or file_id <> ?)", res.ExtractorID, file.ID).
+		Count(&unlinked).Error)
+	assert.Zero(t, unlinked)
+
+	var extractor metadata.Extractor
+	require.Nil(t, database.Take(&extractor, res.ExtractorID).Error)
+	assert.Equal(t, metadata.ExtractorTypeImported, extractor.Type)
+
+	plain, err := importTriples(&setting, ctx, &ImportConfig{Name: "TestImportPlain", Triples: triples})
+	require.Nil(t, err)
+	assert.Equal(t, 3, plain.EntityCount)
+
+	_, err = importTriples(&setting, ctx, &ImportConfig{Name: "TestImportEmpty"})
+	assert.ErrorIs(t, err, ErrNoTriple)
+
+	// 导入的三元组与其它抽取器一样参与构建，并溯源到来源文件
+	build, err := graph.BuildKG(ctx, &graph.KGBuildConfig{
+		ExtractorIDList: []uint{res.ExtractorID},
+		Desc:            "TestImport",
+	})
+	require.Nil(t, err)
+
+	var node metadata.Node
+	require.Nil(t, database.Where("build_id = ? and name = ?", build.BuildID, "Import-A").Take(&node).Error)
+	assert.Equal(t, metadata.EntityCategoryPerson, node.Category)
+
+	var source metadata.SchemaNodeSource
+	require.Nil(t, json.Unmarshal([]byte(node.SourceJSON), &source))
+	require.Len(t, source.Files, 1)
+	assert.Equal(t, file.ID, source.Files[0].FileID)
+
+	var out metadata.SchemaNodeOut
+	require.Nil(t, json.Unmarshal([]byte(node.OutJSON), &out))
+	assert.Len(t, out.NextNodes, 2)
+}
@@ -0,0 +1,22 @@
+package importer
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type ImportSetting struct {
+	GetMetadataDatabase func() *gorm.DB
+	Logger              *logrus.Logger
+}
+
+var globalSetting ImportSetting
+
+func Init(setting *ImportSetting) {
+	globalSetting = *setting
+}
+
+func Import(ctx context.Context, config *ImportConfig) (*ImportResult, error) {
+	return importTriples(&globalSetting, ctx, config)
+}
@@ -0,0 +1,539 @@
+package importer
+
+import (
+	"autograph-backend-controller/utils"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	FormatCSV      = "csv"
+	FormatTSV      = "tsv"
+	FormatNTriples = "ntriples"
+	FormatJSONL    = "jsonl"
+)
+
+// maxNameLength Entity.Name 与 Relation.Name 的最大长度，与数据库中的 varchar(32) 一致
+const maxNameLength = 32
+
+var (
+	ErrUnknownFormat = errors.New("unknown import format")
+	ErrInvalidTriple = errors.New("invalid triple")
+)
+
+/*
+Triple 从外部文件中读取的一个三元组。
+
+	HeadCategory、TailCategory 头尾实体的类别，为空表示未知；
+	Confidence 置信度，为空表示未知；
+	Raw 三元组在文件中的原始记录，用于在报错时定位；
+*/
+type Triple struct {
+	Head         string
+	Relation     string
+	Tail         string
+	HeadCategory string
+	TailCategory string
+	Confidence   *float64
+	Raw          string
+}
+
+/*
+FormatOfFile 通过文件扩展名推断格式，无法推断时返回空。
+*/
+func FormatOfFile(filename string) string {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".csv":
+		return FormatCSV
+	case ".tsv":
+		return FormatTSV
+	case ".nt":
+		return FormatNTriples
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	}
+	return ""
+}
+
+/*
+ParseTriples 按格式读取 r 中的所有三元组，格式名不区分大小写。
+*/
+func ParseTriples(format string, r io.Reader) ([]Triple, error) {
+	var ret []Triple
+	var err error
+
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		ret, err = parseDelimited(r, ',')
+	case FormatTSV:
+		ret, err = parseDelimited(r, '\t')
+	case FormatNTriples:
+		ret, err = parseNTriples(r)
+	case FormatJSONL:
+		ret, err = parseJSONL(r)
+	default:
+		return nil, utils.WrapErrorf(ErrUnknownFormat, "format %#v", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range ret {
+		if err := checkTriple(&ret[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+func checkTriple(triple *Triple) error {
+	for _, name := range []string{triple.Head, triple.Relation, triple.Tail} {
+		if len(name) == 0 || utf8.RuneCountInString(name) > maxNameLength {
+			return lineError(ErrInvalidTriple, triple.Raw, "name %#v is empty or longer than %d", name, maxNameLength)
+		}
+	}
+
+	if triple.Confidence != nil && (*triple.Confidence < 0 || *triple.Confidence > 1) {
+		return lineError(ErrInvalidTriple, triple.Raw, "confidence %v out of range [0, 1]", *triple.Confidence)
+	}
+
+	triple.HeadCategory = normalizeCategory(triple.HeadCategory)
+	triple.TailCategory = normalizeCategory(triple.TailCategory)
+
+	return nil
+}
+
+/*
+lineError 包装解析错误，并附上出错的原始记录
+*/
+func lineError(err error, raw string, format string, args ...interface{}) error {
+	return utils.WrapErrorf(err, "record %#v: "+format, append([]interface{}{raw}, args...)...)
+}
+
+func normalizeCategory(category string) string {
+	return strings.ToLower(strings.TrimSpace(category))
+}
+
+func parseConfidence(s string) (*float64, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return nil, nil
+	}
+
+	confidence, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &confidence, nil
+}
+
+/////////////////////////////// CSV / TSV ///////////////////////////////////
+
+// delimitedColumns 表头中可以识别的列名 -> 字段
+var delimitedColumns = map[string]string{
+	"head":          "head",
+	"subject":       "head",
+	"relation":      "relation",
+	"rel":           "relation",
+	"predicate":     "relation",
+	"tail":          "tail",
+	"object":        "tail",
+	"confidence":    "confidence",
+	"head_category": "head_category",
+	"tail_category": "tail_category",
+	"name":          "name",
+	"category":      "category",
+}
+
+/*
+parseDelimited 读取 CSV 或 TSV。第一行包含 head、relation、tail（或 subject、predicate、object）列时视为表头，按列名读取；
+否则按 head,relation,tail[,confidence] 的顺序读取。
+表头中有 name 与 category 列时（如 /admin/export 导出的 CSV），头尾为空的行只用于提供实体的类别。
+*/
+func parseDelimited(r io.Reader, delimiter rune) ([]Triple, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = delimiter == '\t'
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, utils.WrapError(ErrInvalidTriple, err.Error())
+	}
+
+	if len(records) == 0 {
+		return make([]Triple, 0), nil
+	}
+
+	columns := map[string]int{"head": 0, "relation": 1, "tail": 2, "confidence": 3}
+	header := make(map[string]int)
+	for i, column := range records[0] {
+		if field, ok := delimitedColumns[strings.ToLower(strings.TrimSpace(column))]; ok {
+			if _, exists := header[field]; !exists {
+				header[field] = i
+			}
+		}
+	}
+	_, hasHead := header["head"]
+	_, hasRelation := header["relation"]
+	_, hasTail := header["tail"]
+	if hasHead && hasRelation && hasTail {
+		columns = header
+		records = records[1:]
+	}
+
+	get := func(record []string, field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	categories := make(map[string]string)
+	ret := make([]Triple, 0, len(records))
+	for _, record := range records {
+		raw := strings.Join(record, string(delimiter))
+
+		head, relation, tail := get(record, "head"), get(record, "relation"), get(record, "tail")
+		if len(head) == 0 && len(relation) == 0 && len(tail) == 0 {
+			if name := get(record, "name"); len(name) != 0 {
+				categories[name] = get(record, "category")
+			}
+			continue
+		}
+
+		confidence, err := parseConfidence(get(record, "confidence"))
+		if err != nil {
+			return nil, lineError(ErrInvalidTriple, raw, "parse confidence fail: %s", err.Error())
+		}
+
+		ret = append(ret, Triple{
+			Head:         head,
+			Relation:     relation,
+			Tail:         tail,
+			HeadCategory: get(record, "head_category"),
+			TailCategory: get(record, "tail_category"),
+			Confidence:   confidence,
+			Raw:          raw,
+		})
+	}
+
+	for i := range ret {
+		if len(ret[i].HeadCategory) == 0 {
+			ret[i].HeadCategory = categories[ret[i].Head]
+		}
+		if len(ret[i].TailCategory) == 0 {
+			ret[i].TailCategory = categories[ret[i].Tail]
+		}
+	}
+
+	return ret, nil
+}
+
+/////////////////////////////// JSONL ///////////////////////////////////
+
+type jsonlTriple struct {
+	Head         string   `json:"head"`
+	Relation     string   `json:"relation"`
+	Tail         string   `json:"tail"`
+	HeadCategory string   `json:"head_category"`
+	TailCategory string   `json:"tail_category"`
+	Confidence   *float64 `json:"confidence"`
+}
+
+/*
+parseJSONL 每一行为一个 JSON 对象，字段为 head、relation、tail 以及可选的 head_category、tail_category、confidence，忽略空行。
+*/
+func parseJSONL(r io.Reader) ([]Triple, error) {
+	ret := make([]Triple, 0)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+
+		var triple jsonlTriple
+		if err := json.Unmarshal([]byte(line), &triple); err != nil {
+			return nil, lineError(ErrInvalidTriple, line, "json unmarshal fail: %s", err.Error())
+		}
+
+		ret = append(ret, Triple{
+			Head:         strings.TrimSpace(triple.Head),
+			Relation:     strings.TrimSpace(triple.Relation),
+			Tail:         strings.TrimSpace(triple.Tail),
+			HeadCategory: triple.HeadCategory,
+			TailCategory: triple.TailCategory,
+			Confidence:   triple.Confidence,
+			Raw:          line,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, utils.WrapError(err, "scan jsonl fail")
+	}
+
+	return ret, nil
+}
+
+/////////////////////////////// N-Triples ///////////////////////////////////
+
+const (
+	rdfType   = "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
+	rdfsLabel = "http://www.w3.org/2000/01/rdf-schema#label"
+)
+
+type ntTermKind int
+
+const (
+	ntIRI ntTermKind = iota
+	ntBlank
+	ntLiteral
+)
+
+type ntTerm struct {
+	kind  ntTermKind
+	value string
+}
+
+/*
+parseNTriples 读取 N-Triples：rdfs:label 的字面量作为实体名，没有 label 的实体使用 IRI 的最后一段（百分号解码后）；
+rdf:type 的最后一段作为实体类别；宾语为 IRI 或空白节点的其它三元组作为关系，宾语为字面量的其它三元组被忽略。
+*/
+func parseNTriples(r io.Reader) ([]Triple, error) {
+	type edge struct {
+		subject   ntTerm
+		predicate string
+		object    ntTerm
+		raw       string
+	}
+
+	labels := make(map[ntTerm]string)
+	categories := make(map[ntTerm]string)
+	edges := make([]edge, 0)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		subject, predicate, object, err := parseNTriplesLine(line)
+		if err != nil {
+			return nil, lineError(ErrInvalidTriple, line, "%s", err.Error())
+		}
+
+		switch {
+		case predicate.value == rdfsLabel && object.kind == ntLiteral:
+			labels[subject] = object.value
+		case predicate.value == rdfType && object.kind == ntIRI:
+			categories[subject] = localName(object.value)
+		case object.kind != ntLiteral:
+			edges = append(edges, edge{subject: subject, predicate: predicate.value, object: object, raw: line})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, utils.WrapError(err, "scan n-triples fail")
+	}
+
+	name := func(term ntTerm) string {
+		if label, ok := labels[term]; ok {
+			return strings.TrimSpace(label)
+		}
+		if term.kind == ntBlank {
+			return term.value
+		}
+		return localName(term.value)
+	}
+
+	ret := make([]Triple, 0, len(edges))
+	for _, e := range edges {
+		ret = append(ret, Triple{
+			Head:         name(e.subject),
+			Relation:     localName(e.predicate),
+			Tail:         name(e.object),
+			HeadCategory: categories[e.subject],
+			TailCategory: categories[e.object],
+			Raw:          e.raw,
+		})
+	}
+
+	return ret, nil
+}
+
+/*
+localName 返回 IRI 中最后一个 / 或 # 之后的部分，并进行百分号解码。
+*/
+func localName(iri string) string {
+	name := iri[strings.LastIndexAny(iri, "/#")+1:]
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	return strings.TrimSpace(name)
+}
+
+func parseNTriplesLine(line string) (subject, predicate, object ntTerm, err error) {
+	rest := line
+
+	if subject, rest, err = parseNTTerm(rest); err != nil {
+		return
+	}
+	if predicate, rest, err = parseNTTerm(rest); err != nil {
+		return
+	}
+	if object, rest, err = parseNTTerm(rest); err != nil {
+		return
+	}
+
+	if subject.kind == ntLiteral || predicate.kind != ntIRI {
+		err = errors.New("subject must not be a literal and predicate must be an IRI")
+		return
+	}
+
+	rest = strings.TrimSpace(rest)
+	if !strings.HasPrefix(rest, ".") {
+		err = errors.New("missing '.' at the end of triple")
+		return
+	}
+	if rest = strings.TrimSpace(rest[1:]); len(rest) != 0 && !strings.HasPrefix(rest, "#") {
+		err = errors.New("unexpected content after '.'")
+	}
+
+	return
+}
+
+/*
+parseNTTerm 读取 s 开头的一个 IRI、空白节点或字面量，返回剩余的部分。字面量的语言标签和数据类型被忽略。
+*/
+func parseNTTerm(s string) (ntTerm, string, error) {
+	s = strings.TrimLeft(s, " \t")
+	if len(s) == 0 {
+		return ntTerm{}, "", errors.New("unexpected end of triple")
+	}
+
+	switch {
+	case s[0] == '<':
+		end := strings.IndexByte(s, '>')
+		if end < 0 {
+			return ntTerm{}, "", errors.New("unterminated IRI")
+		}
+		iri, err := unescapeNT(s[1:end])
+		if err != nil {
+			return ntTerm{}, "", err
+		}
+		return ntTerm{kind: ntIRI, value: iri}, s[end+1:], nil
+
+	case strings.HasPrefix(s, "_:"):
+		end := strings.IndexAny(s, " \t")
+		if end < 0 {
+			return ntTerm{}, "", errors.New("unexpected end of triple")
+		}
+		return ntTerm{kind: ntBlank, value: s[2:end]}, s[end:], nil
+
+	case s[0] == '"':
+		end := 1
+		for ; end < len(s); end++ {
+			if s[end] == '\\' {
+				end++
+				continue
+			}
+			if s[end] == '"' {
+				break
+			}
+		}
+		if end >= len(s) {
+			return ntTerm{}, "", errors.New("unterminated literal")
+		}
+
+		value, err := unescapeNT(s[1:end])
+		if err != nil {
+			return ntTerm{}, "", err
+		}
+
+		rest := s[end+1:]
+		if strings.HasPrefix(rest, "^^<") {
+			typeEnd := strings.IndexByte(rest, '>')
+			if typeEnd < 0 {
+				return ntTerm{}, "", errors.New("unterminated datatype IRI")
+			}
+			rest = rest[typeEnd+1:]
+		} else if strings.HasPrefix(rest, "@") {
+			langEnd := strings.IndexAny(rest, " \t.")
+			if langEnd < 0 {
+				langEnd = len(rest)
+			}
+			rest = rest[langEnd:]
+		}
+
+		return ntTerm{kind: ntLiteral, value: value}, rest, nil
+	}
+
+	return ntTerm{}, "", errors.New("unknown term")
+}
+
+var ntEscapes = map[byte]string{
+	't': "\t", 'b': "\b", 'n': "\n", 'r': "\r", 'f': "\f", '"': "\"", '\'': "'", '\\': "\\",
+}
+
+/*
+unescapeNT 处理 N-Triples 中的 \t、\n 等转义以及 \uXXXX、\UXXXXXXXX
+*/
+func unescapeNT(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		if i+1 >= len(s) {
+			return "", errors.New("unterminated escape")
+		}
+		i++
+
+		if escaped, ok := ntEscapes[s[i]]; ok {
+			b.WriteString(escaped)
+			continue
+		}
+
+		size := 0
+		switch s[i] {
+		case 'u':
+			size = 4
+		case 'U':
+			size = 8
+		default:
+			return "", errors.New("unknown escape \\" + string(s[i]))
+		}
+
+		if i+1+size > len(s) {
+			return "", errors.New("unterminated unicode escape")
+		}
+		code, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+		if err != nil {
+			return "", errors.New("invalid unicode escape")
+		}
+		b.WriteRune(rune(code))
+		i += size
+	}
+
+	return b.String(), nil
+}
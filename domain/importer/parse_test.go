@@ -0,0 +1,105 @@
+package importer
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestParseDelimited(t *testing.T) {
+	// 没有表头时按 head,relation,tail,confidence 读取
+	triples, err := ParseTriples(FormatCSV, strings.NewReader("小明,认识,小红,0.5\n\"小,明\",住在,北京\n"))
+	require.Nil(t, err)
+	require.Len(t, triples, 2)
+	assert.Equal(t, "小明", triples[0].Head)
+	assert.Equal(t, 0.5, *triples[0].Confidence)
+	assert.Equal(t, "小,明", triples[1].Head)
+	assert.Nil(t, triples[1].Confidence)
+
+	// 有表头时按列名读取
+	triples, err = ParseTriples(FormatTSV, strings.NewReader("object\tpredicate\tsubject\thead_category\n小红\t认识\t小明\tPerson\n"))
+	require.Nil(t, err)
+	require.Len(t, triples, 1)
+	assert.Equal(t, Triple{Head: "小明", Relation: "认识", Tail: "小红", HeadCategory: "person", Raw: "小红\t认识\t小明\tPerson"}, triples[0])
+
+	// 导出的 CSV 中节点行提供类别
+	exported := "kind,name,category,head,relation,tail,confidence\n" +
+		"node,小明,person,,,,\n" +
+		"edge,,,小明,住在,北京,0.75\n" +
+		"node,北京,location,,,,\n"
+	triples, err = ParseTriples(FormatCSV, strings.NewReader(exported))
+	require.Nil(t, err)
+	require.Len(t, triples, 1)
+	assert.Equal(t, "person", triples[0].HeadCategory)
+	assert.Equal(t, "location", triples[0].TailCategory)
+	assert.Equal(t, 0.75, *triples[0].Confidence)
+
+	for _, data := range []string{
+		"小明,认识\n",
+		"小明,认识,小红,high\n",
+		"小明,认识,小红,2\n",
+		"小明,认识," + strings.Repeat("长", maxNameLength+1) + "\n",
+	} {
+		_, err = ParseTriples(FormatCSV, strings.NewReader(data))
+		assert.ErrorIs(t, err, ErrInvalidTriple, data)
+	}
+}
+
+func TestParseJSONL(t *testing.T) {
+	data := `{"head": "小明", "relation": "认识", "tail": "小红", "confidence": 0.9, "tail_category": "person"}
+
+{"head": "小红", "relation": "住在", "tail": "北京"}
+`
+	triples, err := ParseTriples(FormatJSONL, strings.NewReader(data))
+	require.Nil(t, err)
+	require.Len(t, triples, 2)
+	assert.Equal(t, 0.9, *triples[0].Confidence)
+	assert.Equal(t, "person", triples[0].TailCategory)
+	assert.Equal(t, "北京", triples[1].Tail)
+
+	_, err = ParseTriples(FormatJSONL, strings.NewReader(`{"head": "小明"`))
+	assert.ErrorIs(t, err, ErrInvalidTriple)
+}
+
+func TestParseNTriples(t *testing.T) {
+	data := `# comment
+<http://example.org/entity/xiaoming> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/category/person> .
+<http://example.org/entity/xiaoming> <http://www.w3.org/2000/01/rdf-schema#label> "小明 \"明哥\""@zh .
+<http://example.org/entity/xiaoming> <http://example.org/relation/%E8%AE%A4%E8%AF%86> <http://example.org/entity/%E5%B0%8F%E7%BA%A2> .
+<http://example.org/entity/xiaoming> <http://example.org/relation#age> "18"^^<http://www.w3.org/2001/XMLSchema#integer> .
+_:b1 <http://example.org/relation/住在> <http://example.org/entity/xiaoming> .
+`
+	triples, err := ParseTriples(FormatNTriples, strings.NewReader(data))
+	require.Nil(t, err)
+	require.Len(t, triples, 2)
+
+	assert.Equal(t, `小明 "明哥"`, triples[0].Head)
+	assert.Equal(t, "认识", triples[0].Relation)
+	assert.Equal(t, "小红", triples[0].Tail)
+	assert.Equal(t, "person", triples[0].HeadCategory)
+
+	assert.Equal(t, "b1", triples[1].Head)
+	assert.Equal(t, "住在", triples[1].Relation)
+	assert.Equal(t, "person", triples[1].TailCategory)
+
+	for _, line := range []string{
+		`<http://example.org/a> <http://example.org/b> <http://example.org/c>`,
+		`"a" <http://example.org/b> <http://example.org/c> .`,
+		`<http://example.org/a> <http://example.org/b> "c .`,
+		`<http://example.org/a> <http://example.org/b> <http://example.org/c> . extra`,
+	} {
+		_, err = ParseTriples(FormatNTriples, strings.NewReader(line))
+		assert.ErrorIs(t, err, ErrInvalidTriple, line)
+	}
+}
+
+func TestFormatOfFile(t *testing.T) {
+	assert.Equal(t, FormatCSV, FormatOfFile("kb.CSV"))
+	assert.Equal(t, FormatNTriples, FormatOfFile("dump.nt"))
+	assert.Equal(t, FormatJSONL, FormatOfFile("a.b.ndjson"))
+	assert.Equal(t, "", FormatOfFile("kb.txt"))
+
+	_, err := ParseTriples("xlsx", strings.NewReader(""))
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
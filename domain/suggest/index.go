@@ -0,0 +1,127 @@
+package suggest
+
+import (
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/utils"
+	"context"
+	"github.com/mozillazg/go-pinyin"
+	"gorm.io/gorm"
+	"strings"
+	"unicode"
+)
+
+const (
+	nodeBatchSize = 128
+
+	// 最多缓存的构建数量，超过时淘汰最久未使用的
+	maxCachedIndexes = 8
+)
+
+/*
+entry 为索引中的一个实体名。Pinyin 与 Initials 为全拼和首字母，名称中没有汉字时为空。
+*/
+type entry struct {
+	Name     string
+	Category string
+
+	lower    string
+	runes    []rune
+	pinyin   string
+	initials string
+}
+
+/*
+index 为一次构建中所有实体名的索引，构建后只读，可以并发查询
+*/
+type index struct {
+	entries []entry
+}
+
+func newIndex() *index {
+	return &index{entries: make([]entry, 0)}
+}
+
+func (idx *index) add(name, category string) {
+	lower := strings.ToLower(name)
+	e := entry{
+		Name:     name,
+		Category: category,
+		lower:    lower,
+		runes:    []rune(lower),
+	}
+
+	if hasHan(name) {
+		e.pinyin = strings.Join(pinyin.LazyPinyin(lower, pinyinArgs(pinyin.Normal)), "")
+		e.initials = strings.Join(pinyin.LazyPinyin(lower, pinyinArgs(pinyin.FirstLetter)), "")
+	}
+
+	idx.entries = append(idx.entries, e)
+}
+
+/*
+pinyinArgs 没有拼音的字符保留原样，使 "A股" 的全拼为 "agu"
+*/
+func pinyinArgs(style int) pinyin.Args {
+	args := pinyin.NewArgs()
+	args.Style = style
+	args.Fallback = func(r rune, a pinyin.Args) []string {
+		if unicode.IsSpace(r) {
+			return nil
+		}
+		return []string{string(r)}
+	}
+	return args
+}
+
+func hasHan(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+loadIndex 分批读取一次构建的所有 Node 的名称和类别。构建不存在时返回 gorm.ErrRecordNotFound。
+*/
+func loadIndex(setting *SuggestSetting, ctx context.Context, buildID uint) (*index, error) {
+	db := setting.GetMetadataDatabase().WithContext(ctx)
+
+	var build metadata.Build
+	if err := db.Select("id").Take(&build, buildID).Error; err != nil {
+		return nil, utils.WrapErrorf(err, "select build [%d] fail", buildID)
+	}
+
+	ret := newIndex()
+
+	var batch []metadata.Node
+	err := db.Select("id", "name", "category").
+		Where("build_id = ?", buildID).
+		FindInBatches(&batch, nodeBatchSize, func(tx *gorm.DB, batchNum int) error {
+			for i := range batch {
+				ret.add(batch[i].Name, batch[i].Category)
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "select nodes of build [%d] fail", buildID)
+	}
+
+	setting.Logger.Infof("suggest index of build [%d] loaded, %d names", buildID, len(ret.entries))
+
+	return ret, nil
+}
+
+/*
+newIndexCache 按构建缓存索引。版本构建后不再修改，因此只需要在删除版本时移除。
+*/
+func newIndexCache(max int) *utils.LRUCache[uint, *index] {
+	return utils.NewLRUCache[uint, *index](max, nil)
+}
+
+func removeBuild(cache *utils.LRUCache[uint, *index], buildID uint) {
+	cache.Remove(func(key uint) bool {
+		return key == buildID
+	})
+}
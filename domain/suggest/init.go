@@ -0,0 +1,28 @@
+package suggest
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type SuggestSetting struct {
+	GetMetadataDatabase func() *gorm.DB
+	Logger              *logrus.Logger
+}
+
+var globalSetting SuggestSetting
+
+var globalCache = newIndexCache(maxCachedIndexes)
+
+func Init(setting *SuggestSetting) {
+	globalSetting = *setting
+}
+
+func Suggest(ctx context.Context, buildID uint, query string, limit int) ([]Suggestion, error) {
+	return suggest(&globalSetting, globalCache, ctx, buildID, query, limit)
+}
+
+func Invalidate(buildID uint) {
+	removeBuild(globalCache, buildID)
+}
@@ -0,0 +1,194 @@
+package suggest
+
+import (
+	"autograph-backend-controller/utils"
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	MatchExact     = "exact"
+	MatchPrefix    = "prefix"
+	MatchPinyin    = "pinyin"
+	MatchSubstring = "substring"
+	MatchFuzzy     = "fuzzy"
+)
+
+/*
+matchRank 匹配方式的优先级，越小越靠前
+*/
+var matchRank = map[string]int{
+	MatchExact:     0,
+	MatchPrefix:    1,
+	MatchPinyin:    2,
+	MatchSubstring: 3,
+	MatchFuzzy:     4,
+}
+
+/*
+Suggestion 为一个候选实体名，Distance 只在模糊匹配时不为 0
+*/
+type Suggestion struct {
+	Name     string
+	Category string
+	Match    string
+	Distance int
+}
+
+func suggest(setting *SuggestSetting, cache *utils.LRUCache[uint, *index], ctx context.Context, buildID uint, query string, limit int) ([]Suggestion, error) {
+	idx, release, ok := cache.Acquire(buildID)
+	if !ok {
+		generation := cache.Generation()
+
+		loaded, err := loadIndex(setting, ctx, buildID)
+		if err != nil {
+			return nil, utils.WrapErrorf(err, "load index of build [%d] fail", buildID)
+		}
+
+		idx, release = cache.Put(buildID, loaded, generation)
+	}
+	defer release()
+
+	return idx.match(query, limit), nil
+}
+
+/*
+match 依次尝试完全匹配、前缀、拼音（全拼或首字母前缀）、子串和编辑距离，每个实体名只取优先级最高的一种。
+结果按匹配方式、编辑距离、名称长度排序，最多返回 limit 个。
+*/
+func (idx *index) match(query string, limit int) []Suggestion {
+	query = strings.ToLower(strings.TrimSpace(query))
+	ret := make([]Suggestion, 0)
+	if len(query) == 0 || limit <= 0 {
+		return ret
+	}
+
+	queryRunes := []rune(query)
+	pinyinQuery := ""
+	if isASCII(query) {
+		pinyinQuery = strings.ReplaceAll(query, " ", "")
+	}
+	maxDistance := fuzzyDistance(len(queryRunes))
+
+	for i := range idx.entries {
+		e := &idx.entries[i]
+		s := Suggestion{Name: e.Name, Category: e.Category}
+
+		switch {
+		case e.lower == query:
+			s.Match = MatchExact
+		case strings.HasPrefix(e.lower, query):
+			s.Match = MatchPrefix
+		case len(pinyinQuery) != 0 && len(e.pinyin) != 0 &&
+			(strings.HasPrefix(e.pinyin, pinyinQuery) || strings.HasPrefix(e.initials, pinyinQuery)):
+			s.Match = MatchPinyin
+		case strings.Contains(e.lower, query):
+			s.Match = MatchSubstring
+		default:
+			distance, ok := editDistance(queryRunes, e.runes, maxDistance)
+			if !ok {
+				continue
+			}
+			s.Match = MatchFuzzy
+			s.Distance = distance
+		}
+
+		ret = append(ret, s)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		a, b := &ret[i], &ret[j]
+		if matchRank[a.Match] != matchRank[b.Match] {
+			return matchRank[a.Match] < matchRank[b.Match]
+		}
+		if a.Distance != b.Distance {
+			return a.Distance < b.Distance
+		}
+		if len(a.Name) != len(b.Name) {
+			return len(a.Name) < len(b.Name)
+		}
+		return a.Name < b.Name
+	})
+
+	if len(ret) > limit {
+		ret = ret[:limit]
+	}
+
+	return ret
+}
+
+/*
+fuzzyDistance 允许的最大编辑距离，过短的查询不做模糊匹配，避免返回大量无关结果
+*/
+func fuzzyDistance(length int) int {
+	switch {
+	case length < 2:
+		return 0
+	case length <= 4:
+		return 1
+	default:
+		return 2
+	}
+}
+
+/*
+editDistance 计算按字符的 Levenshtein 距离，超过 max 时返回 false
+*/
+func editDistance(a, b []rune, max int) (int, bool) {
+	if max <= 0 {
+		return 0, false
+	}
+
+	diff := len(a) - len(b)
+	if diff > max || -diff > max {
+		return 0, false
+	}
+
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+			rowMin = minInt(rowMin, cur[j])
+		}
+
+		if rowMin > max {
+			return 0, false
+		}
+		prev, cur = cur, prev
+	}
+
+	if prev[len(b)] > max {
+		return 0, false
+	}
+	return prev[len(b)], true
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func isASCII(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package suggest
+
+import (
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/repository/metadata"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func matchedNames(suggestions []Suggestion) []string {
+	ret := make([]string, 0, len(suggestions))
+	for _, s := range suggestions {
+		ret = append(ret, s.Name+":"+s.Match)
+	}
+	return ret
+}
+
+func TestIndexMatch(t *testing.T) {
+	idx := newIndex()
+	for _, name := range []string{"北京", "北京大学", "南京", "Beijing", "清华大学", "Tsinghua University", "A股"} {
+		idx.add(name, metadata.EntityCategoryOrganization)
+	}
+
+	assert.Equal(t, []string{"北京:exact", "北京大学:prefix", "南京:fuzzy"}, matchedNames(idx.match("北京", 10)))
+	assert.Equal(t, []string{"北京大学:substring", "清华大学:substring"}, matchedNames(idx.match("大学", 10)))
+
+	// 英文名不区分大小写，拼音支持全拼和首字母
+	assert.Equal(t, []string{"Beijing:exact", "北京:pinyin", "北京大学:pinyin"}, matchedNames(idx.match("beijing", 10)))
+	assert.Equal(t, []string{"北京大学:pinyin"}, matchedNames(idx.match("bjdx", 10)))
+	assert.Equal(t, []string{"A股:exact"}, matchedNames(idx.match("a股", 10)))
+	assert.Equal(t, []string{"A股:pinyin"}, matchedNames(idx.match("agu", 10)))
+
+	// 编辑距离
+	suggestions := idx.match("Tsinghua Univercity", 10)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, MatchFuzzy, suggestions[0].Match)
+	assert.Equal(t, 1, suggestions[0].Distance)
+	assert.Equal(t, []string{"北京:fuzzy", "南京:fuzzy"}, matchedNames(idx.match("东京", 10)))
+	assert.Empty(t, idx.match("x", 10))
+
+	assert.Len(t, idx.match("京", 1), 1)
+	assert.Empty(t, idx.match(" ", 10))
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, c := range []struct {
+		a, b     string
+		max      int
+		distance int
+		ok       bool
+	}{
+		{"kitten", "sitting", 3, 3, true},
+		{"kitten", "sitting", 2, 0, false},
+		{"北京", "北京", 1, 0, true},
+		{"abc", "abcdef", 2, 0, false},
+		{"", "ab", 2, 2, true},
+	} {
+		distance, ok := editDistance([]rune(c.a), []rune(c.b), c.max)
+		assert.Equal(t, c.ok, ok, "%s %s", c.a, c.b)
+		assert.Equal(t, c.distance, distance, "%s %s", c.a, c.b)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	logging.SetDefaultConfig(logging.GenerateTestConfig(t))
+
+	database, err := metadata.CreateDatabase(metadata.GenerateTestConfig())
+	require.Nil(t, err)
+
+	setting := SuggestSetting{
+		GetMetadataDatabase: func() *gorm.DB {
+			return database
+		},
+		Logger: logging.NewLogger(),
+	}
+	ctx := context.TODO()
+
+	build := metadata.Build{Desc: "TestSuggest"}
+	require.Nil(t, database.Create(&build).Error)
+	require.Nil(t, database.Create(&[]metadata.Node{
+		{BuildID: build.ID, Name: "Suggest-上海交通大学", Category: metadata.EntityCategoryOrganization, OutJSON: "{}", SourceJSON: "{}"},
+		{BuildID: build.ID, Name: "Suggest-上海", Category: metadata.EntityCategoryLocation, OutJSON: "{}", SourceJSON: "{}"},
+	}).Error)
+
+	cache := newIndexCache(1)
+	suggestions, err := suggest(&setting, cache, ctx, build.ID, "suggest-上海", 10)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"Suggest-上海:exact", "Suggest-上海交通大学:prefix"}, matchedNames(suggestions))
+	assert.Equal(t, metadata.EntityCategoryLocation, suggestions[0].Category)
+
+	// 索引被缓存，删除节点后结果不变，移除缓存后重新加载
+	require.Nil(t, database.Where("build_id = ?", build.ID).Delete(&metadata.Node{}).Error)
+	suggestions, err = suggest(&setting, cache, ctx, build.ID, "suggest-上海", 10)
+	require.Nil(t, err)
+	assert.Len(t, suggestions, 2)
+
+	removeBuild(cache, build.ID)
+	suggestions, err = suggest(&setting, cache, ctx, build.ID, "suggest-上海", 10)
+	require.Nil(t, err)
+	assert.Empty(t, suggestions)
+
+	_, err = suggest(&setting, cache, ctx, build.ID+1000000, "suggest", 10)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
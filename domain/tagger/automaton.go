@@ -0,0 +1,210 @@
+package tagger
+
+import (
+	"sort"
+	"unicode"
+)
+
+/*
+acNode Aho–Corasick 自动机的节点。
+
+	next 转移，fail 失配指针；
+	output 以该节点结尾的模式串下标，没有时为 -1；
+	dict 沿失配指针能到达的最近的有输出的节点，没有时为 -1；
+*/
+type acNode struct {
+	next   map[rune]int
+	fail   int
+	output int
+	dict   int
+}
+
+/*
+acPattern 模式串，names 为规范化后相同的实体名
+*/
+type acPattern struct {
+	length int
+	names  []string
+}
+
+/*
+acMatch 一次匹配，Begin、End 为字符下标，左闭右开
+*/
+type acMatch struct {
+	Begin   int
+	End     int
+	Pattern int
+}
+
+/*
+automaton 在实体名上构建的 Aho–Corasick 自动机，一次扫描找出文本中出现的所有实体名
+*/
+type automaton struct {
+	nodes    []acNode
+	patterns []acPattern
+	option   MatcherOption
+}
+
+func newAutomaton(names map[string]struct{}, option MatcherOption) *automaton {
+	ret := automaton{
+		nodes:  []acNode{{next: make(map[rune]int), output: -1, dict: -1}},
+		option: option,
+	}
+
+	// 按名称排序，使同一模式串中 names 的顺序确定
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		ret.insert(name)
+	}
+	ret.link()
+
+	return &ret
+}
+
+func (a *automaton) insert(name string) {
+	runes := []rune(name)
+	if len(runes) == 0 {
+		return
+	}
+
+	cur := 0
+	for _, r := range runes {
+		r = a.option.normalize(r)
+		next, ok := a.nodes[cur].next[r]
+		if !ok {
+			next = len(a.nodes)
+			a.nodes = append(a.nodes, acNode{next: make(map[rune]int), output: -1, dict: -1})
+			a.nodes[cur].next[r] = next
+		}
+		cur = next
+	}
+
+	if a.nodes[cur].output < 0 {
+		a.nodes[cur].output = len(a.patterns)
+		a.patterns = append(a.patterns, acPattern{length: len(runes)})
+	}
+	pattern := &a.patterns[a.nodes[cur].output]
+	pattern.names = append(pattern.names, name)
+}
+
+/*
+link 按广度优先计算失配指针和输出链接
+*/
+func (a *automaton) link() {
+	queue := make([]int, 0, len(a.nodes))
+	for _, child := range a.nodes[0].next {
+		a.nodes[child].fail = 0
+		queue = append(queue, child)
+	}
+
+	for len(queue) != 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		for r, child := range a.nodes[cur].next {
+			fail := a.nodes[cur].fail
+			for {
+				if next, ok := a.nodes[fail].next[r]; ok {
+					a.nodes[child].fail = next
+					break
+				}
+				if fail == 0 {
+					a.nodes[child].fail = 0
+					break
+				}
+				fail = a.nodes[fail].fail
+			}
+
+			failNode := a.nodes[child].fail
+			if a.nodes[failNode].output >= 0 {
+				a.nodes[child].dict = failNode
+			} else {
+				a.nodes[child].dict = a.nodes[failNode].dict
+			}
+
+			queue = append(queue, child)
+		}
+	}
+}
+
+/*
+findAll 找出文本中所有出现的模式串，包括互相重叠和嵌套的，按 Begin 升序、长度降序排列
+*/
+func (a *automaton) findAll(runes []rune) []acMatch {
+	ret := make([]acMatch, 0)
+
+	cur := 0
+	for i, r := range runes {
+		r = a.option.normalize(r)
+		for {
+			if next, ok := a.nodes[cur].next[r]; ok {
+				cur = next
+				break
+			}
+			if cur == 0 {
+				break
+			}
+			cur = a.nodes[cur].fail
+		}
+
+		for node := cur; node > 0; node = a.nodes[node].dict {
+			if pattern := a.nodes[node].output; pattern >= 0 {
+				ret = append(ret, acMatch{
+					Begin:   i + 1 - a.patterns[pattern].length,
+					End:     i + 1,
+					Pattern: pattern,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Begin < ret[j].Begin || ret[i].Begin == ret[j].Begin && ret[i].End > ret[j].End
+	})
+
+	return ret
+}
+
+/*
+longest 从左到右选出互不重叠的最长匹配，matches 需按 findAll 的顺序排列
+*/
+func longest(matches []acMatch) []acMatch {
+	ret := make([]acMatch, 0, len(matches))
+
+	end := 0
+	for _, match := range matches {
+		if match.Begin < end {
+			continue
+		}
+		ret = append(ret, match)
+		end = match.End
+	}
+
+	return ret
+}
+
+/*
+normalize 按选项将字符规范化：FoldWidth 将全角 ASCII 字符和全角空格转为半角，FoldCase 转为小写。
+规范化逐个字符进行，不改变字符数，因此匹配位置可以直接对应到原文。
+*/
+func (o MatcherOption) normalize(r rune) rune {
+	if o.FoldWidth {
+		switch {
+		case r == '　':
+			r = ' '
+		case r >= '！' && r <= '～':
+			r -= 0xFEE0
+		}
+	}
+
+	if o.FoldCase {
+		r = unicode.ToLower(r)
+	}
+
+	return r
+}
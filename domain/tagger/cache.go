@@ -0,0 +1,32 @@
+package tagger
+
+import (
+	"autograph-backend-controller/utils"
+)
+
+// 最多缓存的构建数量，超过时淘汰最久未使用的
+const maxCachedTaggers = 4
+
+/*
+cacheKey 同一构建使用不同识别方式的 Tagger 分别缓存
+*/
+type cacheKey struct {
+	buildID uint
+	option  MatcherOption
+}
+
+/*
+newTaggerCache 按构建缓存 Tagger。版本构建后不再修改，因此只需要在删除版本时移除，被淘汰或移除且没有引用的 Tagger 会被 Free。
+*/
+func newTaggerCache(max int) *utils.LRUCache[cacheKey, *Tagger] {
+	return utils.NewLRUCache[cacheKey, *Tagger](max, (*Tagger).Free)
+}
+
+/*
+removeBuild 移除构建的所有识别方式的 Tagger
+*/
+func removeBuild(cache *utils.LRUCache[cacheKey, *Tagger], buildID uint) {
+	cache.Remove(func(key cacheKey) bool {
+		return key.buildID == buildID
+	})
+}
@@ -14,12 +14,12 @@ type indexBuilder struct {
 	buildID uint
 
 	// outputs
-	relationIndex map[SOTuple]string
+	relationIndex map[SOTuple][]string
 	entityIndex   map[string]struct{}
 }
 
 func (b *indexBuilder) Build(tx *gorm.DB) error {
-	b.relationIndex = make(map[SOTuple]string)
+	b.relationIndex = make(map[SOTuple][]string)
 	b.entityIndex = make(map[string]struct{})
 
 	err := b.build(tx)
@@ -49,12 +49,14 @@ func (b *indexBuilder) build(tx *gorm.DB) error {
 					return utils.WrapError(err, "outSchema json unmarshal fail")
 				}
 
-				for next, relationInfo := range outSchema.NextNodes {
+				for next, edges := range outSchema.NextNodes {
 					soTuple := SOTuple{
 						HeadEntity: name,
 						TailEntity: next,
 					}
-					b.relationIndex[soTuple] = relationInfo.Name
+					for _, relationInfo := range edges {
+						b.relationIndex[soTuple] = append(b.relationIndex[soTuple], relationInfo.Name)
+					}
 				}
 			}
 
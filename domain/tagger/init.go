@@ -1,6 +1,7 @@
 package tagger
 
 import (
+	"autograph-backend-controller/utils"
 	"context"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
@@ -13,10 +14,33 @@ type TagSetting struct {
 
 var globalSetting TagSetting
 
+var globalCache = newTaggerCache(maxCachedTaggers)
+
 func Init(setting *TagSetting) {
 	globalSetting = *setting
 }
 
-func NewTaggerWithBuildID(ctx context.Context, buildID uint) (*Tagger, error) {
-	return newTaggerWithBuildID(&globalSetting, ctx, buildID)
+/*
+Acquire 返回构建的使用 option 识别方式的 Tagger，优先使用缓存。使用完后需要调用返回的 release，之后不能再使用该 Tagger。
+构建不存在时返回 gorm.ErrRecordNotFound，不放入缓存。
+*/
+func Acquire(ctx context.Context, buildID uint, option MatcherOption) (*Tagger, func(), error) {
+	key := cacheKey{buildID: buildID, option: option}
+	if tagger, release, ok := globalCache.Acquire(key); ok {
+		return tagger, release, nil
+	}
+
+	generation := globalCache.Generation()
+
+	tagger, err := newTaggerWithBuildID(&globalSetting, ctx, buildID, option)
+	if err != nil {
+		return nil, nil, utils.WrapErrorf(err, "load tagger of build [%d] fail", buildID)
+	}
+
+	tagger, release := globalCache.Put(key, tagger, generation)
+	return tagger, release, nil
+}
+
+func Invalidate(buildID uint) {
+	removeBuild(globalCache, buildID)
 }
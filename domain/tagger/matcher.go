@@ -0,0 +1,88 @@
+package tagger
+
+import (
+	"autograph-backend-controller/utils"
+	"errors"
+	"strings"
+)
+
+type MatchMode uint
+
+const (
+	// MatchModeJieba 用 jieba 分词，只识别与分词边界一致的实体
+	MatchModeJieba MatchMode = iota
+	// MatchModeLongest 用 Aho–Corasick 自动机匹配实体名，从左到右取互不重叠的最长匹配
+	MatchModeLongest
+	// MatchModeOverlap 用 Aho–Corasick 自动机匹配实体名，保留所有重叠和嵌套的匹配
+	MatchModeOverlap
+)
+
+var ErrUnknownMatchMode = errors.New("unknown match mode")
+
+/*
+MatcherOption 创建 Tagger 时选择的实体识别方式，零值为 jieba 分词。
+
+	Mode 识别方式；
+	FoldCase 忽略大小写，只对自动机模式生效；
+	FoldWidth 忽略全角半角，只对自动机模式生效；
+*/
+type MatcherOption struct {
+	Mode      MatchMode
+	FoldCase  bool
+	FoldWidth bool
+}
+
+/*
+ParseMatchMode 通过名称解析识别方式，名称为 jieba、longest、overlap 之一，不区分大小写，为空时为 jieba
+*/
+func ParseMatchMode(s string) (MatchMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "jieba":
+		return MatchModeJieba, nil
+	case "longest":
+		return MatchModeLongest, nil
+	case "overlap":
+		return MatchModeOverlap, nil
+	}
+
+	return 0, utils.WrapErrorf(ErrUnknownMatchMode, "match mode %#v", s)
+}
+
+func (o MatcherOption) useAutomaton() bool {
+	return o.Mode == MatchModeLongest || o.Mode == MatchModeOverlap
+}
+
+/*
+matchEntities 用自动机找出文本中的实体，位置转换为以字节为单位。
+规范化后相同的多个实体名在同一位置各输出一个 EntityInfo。
+*/
+func (t *Tagger) matchEntities(text string) []EntityInfo {
+	runes := []rune(text)
+
+	// offsets[i] 为第 i 个字符的字节位置，offsets[len(runes)] 为文本长度
+	offsets := make([]int, 0, len(runes)+1)
+	for i := range text {
+		offsets = append(offsets, i)
+	}
+	offsets = append(offsets, len(text))
+
+	matches := t.automaton.findAll(runes)
+	if t.option.Mode == MatchModeLongest {
+		matches = longest(matches)
+	}
+
+	var ret []EntityInfo
+	for _, match := range matches {
+		for _, name := range t.automaton.patterns[match.Pattern].names {
+			ret = append(ret, EntityInfo{
+				Range: Range{
+					Begin: offsets[match.Begin],
+					End:   offsets[match.End],
+				},
+				Name: name,
+			})
+		}
+	}
+
+	return ret
+}
@@ -1,26 +1,42 @@
 package tagger
 
 import (
+	"autograph-backend-controller/repository/metadata"
 	"autograph-backend-controller/utils"
 	"context"
 	"fmt"
 	"github.com/yanyiwu/gojieba"
 	"gorm.io/gorm"
+	"unicode/utf8"
 )
 
+/*
+Range 文本中的位置，以字节为单位，左闭右开
+*/
 type Range struct {
 	Begin int
 	End   int
 }
 
+/*
+RuneRange 将 text 中以字节为单位的位置转换为以字符为单位
+*/
+func RuneRange(text string, r Range) Range {
+	begin := utf8.RuneCountInString(text[:r.Begin])
+	return Range{
+		Begin: begin,
+		End:   begin + utf8.RuneCountInString(text[r.Begin:r.End]),
+	}
+}
+
 type EntityInfo struct {
 	Range
 	Name string
 }
 
 type SPOTriple struct {
-	HeadEntity Range
-	TailEntity Range
+	HeadEntity EntityInfo
+	TailEntity EntityInfo
 	Relation   string
 }
 
@@ -29,14 +45,28 @@ type SOTuple struct {
 	TailEntity string
 }
 
+/*
+Tagger 在文本中识别知识图谱中的实体，并找出实体之间已知的关系。
+option 为 jieba 分词时使用 jieba，否则使用在实体名上构建的 automaton。
+*/
 type Tagger struct {
+	option        MatcherOption
 	jieba         *gojieba.Jieba
+	automaton     *automaton
 	entityIndex   map[string]struct{}
-	relationIndex map[SOTuple]string
+	relationIndex map[SOTuple][]string
 }
 
-func newTaggerWithBuildID(setting *TagSetting, ctx context.Context, buildID uint) (*Tagger, error) {
-	ret := Tagger{}
+/*
+newTaggerWithBuildID 加载构建的节点和边作为 Tagger 的索引。构建不存在时返回 gorm.ErrRecordNotFound，而不是没有索引的 Tagger。
+*/
+func newTaggerWithBuildID(setting *TagSetting, ctx context.Context, buildID uint, option MatcherOption) (*Tagger, error) {
+	var build metadata.Build
+	if err := setting.GetMetadataDatabase().WithContext(ctx).Select("id").Take(&build, buildID).Error; err != nil {
+		return nil, utils.WrapErrorf(err, "select build [%d] fail", buildID)
+	}
+
+	ret := Tagger{option: option}
 	ret.reset()
 
 	err := ret.applyKG(ctx, setting.GetMetadataDatabase(), buildID)
@@ -48,10 +78,25 @@ func newTaggerWithBuildID(setting *TagSetting, ctx context.Context, buildID uint
 }
 
 func (t *Tagger) reset(jiebaPath ...string) {
+	if t.option.useAutomaton() {
+		t.automaton = newAutomaton(nil, t.option)
+		return
+	}
 	t.jieba = gojieba.NewJieba(jiebaPath...)
 }
 
-func (t *Tagger) applyIndex(entityIndex map[string]struct{}, relationIndex map[SOTuple]string) {
+/*
+Free 释放分词器占用的内存，之后不能再使用该 Tagger
+*/
+func (t *Tagger) Free() {
+	if t.jieba != nil {
+		t.jieba.Free()
+		t.jieba = nil
+	}
+	t.automaton = nil
+}
+
+func (t *Tagger) applyIndex(entityIndex map[string]struct{}, relationIndex map[SOTuple][]string) {
 	entityAppend := len(t.entityIndex) != 0
 	if !entityAppend {
 		t.entityIndex = entityIndex
@@ -67,12 +112,18 @@ func (t *Tagger) applyIndex(entityIndex map[string]struct{}, relationIndex map[S
 
 	for entity := range entityIndex {
 
-		t.jieba.AddWord(entity)
+		if t.jieba != nil {
+			t.jieba.AddWord(entity)
+		}
 
 		if entityAppend {
 			t.entityIndex[entity] = struct{}{}
 		}
 	}
+
+	if t.option.useAutomaton() {
+		t.automaton = newAutomaton(t.entityIndex, t.option)
+	}
 }
 
 func (t *Tagger) applyKG(ctx context.Context, db *gorm.DB, buildID uint) error {
@@ -114,20 +165,27 @@ func (t *Tagger) wordsToEntities(words []gojieba.Word) []EntityInfo {
 }
 
 func (t *Tagger) appendTripleIfRelationExists(triples []SPOTriple, head, tail EntityInfo) []SPOTriple {
-	relation, ok := t.relationIndex[SOTuple{head.Name, tail.Name}]
-	if ok {
-		return append(triples, SPOTriple{
-			HeadEntity: head.Range,
-			TailEntity: tail.Range,
+	for _, relation := range t.relationIndex[SOTuple{head.Name, tail.Name}] {
+		triples = append(triples, SPOTriple{
+			HeadEntity: head,
+			TailEntity: tail,
 			Relation:   relation,
 		})
 	}
 	return triples
 }
 
+/*
+Produce 找出文本中任意两个实体之间已知的关系，位置以字节为单位。重叠匹配时位置相同的两个实体之间不配对。
+*/
 func (t *Tagger) Produce(text string) []SPOTriple {
-	words := t.jieba.Tokenize(text, gojieba.DefaultMode, true)
-	entities := t.wordsToEntities(words)
+	var entities []EntityInfo
+	if t.option.useAutomaton() {
+		entities = t.matchEntities(text)
+	} else {
+		words := t.jieba.Tokenize(text, gojieba.DefaultMode, true)
+		entities = t.wordsToEntities(words)
+	}
 
 	var ret []SPOTriple
 
@@ -135,6 +193,9 @@ func (t *Tagger) Produce(text string) []SPOTriple {
 		for j := 0; j < i; j++ {
 			front := entities[j]
 			back := entities[i]
+			if front.Range == back.Range {
+				continue
+			}
 
 			ret = t.appendTripleIfRelationExists(ret, front, back)
 			ret = t.appendTripleIfRelationExists(ret, back, front)
@@ -40,18 +40,26 @@ func TestTagger_Produce(t *testing.T) {
 
 	out := []metadata.SchemaNodeOut{
 		{
-			NextNodes: map[string]metadata.RelationInfo{
+			NextNodes: map[string][]metadata.RelationInfo{
 				"指针": {
-					Name:       "相关",
-					Extractors: []uint{extractor.ID},
+					{
+						Name:       "相关",
+						Extractors: []uint{extractor.ID},
+					},
+					{
+						Name:       "包含",
+						Extractors: []uint{extractor.ID},
+					},
 				},
 			},
 		},
 		{
-			NextNodes: map[string]metadata.RelationInfo{
+			NextNodes: map[string][]metadata.RelationInfo{
 				"数组": {
-					Name:       "相关",
-					Extractors: []uint{extractor.ID},
+					{
+						Name:       "相关",
+						Extractors: []uint{extractor.ID},
+					},
 				},
 			},
 		},
@@ -77,24 +85,30 @@ func TestTagger_Produce(t *testing.T) {
 	err = database.Create(&nodes).Error
 	require.Nil(t, err)
 
-	tagger, err := newTaggerWithBuildID(&TagSetting{
+	setting := TagSetting{
 		Logger: logging.NewLogger(),
 		GetMetadataDatabase: func() *gorm.DB {
 			return database
 		},
-	}, context.TODO(), build.ID)
+	}
+
+	// 不存在的构建不会得到空的 Tagger
+	_, err = newTaggerWithBuildID(&setting, context.TODO(), build.ID+1000000, MatcherOption{})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	tagger, err := newTaggerWithBuildID(&setting, context.TODO(), build.ID, MatcherOption{})
 	require.Nil(t, err)
 
 	text := "数组变量作为右值时会退化为指向数组首元素的指针"
 	spoTriples := tagger.Produce(text)
 
-	assert.True(t, len(spoTriples) >= 4)
+	assert.True(t, len(spoTriples) >= 6)
 
 	type SPO struct {
 		S, P, O string
 	}
 
-	expect := []SPO{{"数组", "相关", "指针"}, {"指针", "相关", "数组"}}
+	expect := []SPO{{"数组", "相关", "指针"}, {"数组", "包含", "指针"}, {"指针", "相关", "数组"}}
 	for i, spo := range spoTriples {
 		head := text[spo.HeadEntity.Begin:spo.HeadEntity.End]
 		tail := text[spo.TailEntity.Begin:spo.TailEntity.End]
@@ -121,3 +135,133 @@ func TestTagger_Produce(t *testing.T) {
 		assert.True(t, found)
 	}
 }
+
+func TestTaggerCache(t *testing.T) {
+	newTagger := func() *Tagger {
+		tagger := Tagger{}
+		tagger.reset()
+		return &tagger
+	}
+
+	cache := newTaggerCache(1)
+	key := func(buildID uint) cacheKey {
+		return cacheKey{buildID: buildID}
+	}
+
+	_, _, ok := cache.Acquire(key(1))
+	assert.False(t, ok)
+
+	first, releaseFirst := cache.Put(key(1), newTagger(), cache.Generation())
+
+	// 并发加载同一构建时使用已缓存的
+	duplicate := newTagger()
+	cached, releaseCached := cache.Put(key(1), duplicate, cache.Generation())
+	assert.Same(t, first, cached)
+	assert.Nil(t, duplicate.jieba)
+	releaseCached()
+
+	// 淘汰时仍有引用，释放后才 Free
+	second, releaseSecond := cache.Put(key(2), newTagger(), cache.Generation())
+	_, _, ok = cache.Acquire(key(1))
+	assert.False(t, ok)
+	assert.NotNil(t, first.jieba)
+	releaseFirst()
+	releaseFirst()
+	assert.Nil(t, first.jieba)
+
+	acquired, releaseAcquired, ok := cache.Acquire(key(2))
+	require.True(t, ok)
+	assert.Same(t, second, acquired)
+	releaseAcquired()
+	releaseSecond()
+	assert.NotNil(t, second.jieba)
+
+	// 没有引用时移除立即 Free
+	removeBuild(cache, 2)
+	assert.Nil(t, second.jieba)
+	_, _, ok = cache.Acquire(key(2))
+	assert.False(t, ok)
+}
+
+func newTestTagger(option MatcherOption, entities []string, relations map[SOTuple][]string) *Tagger {
+	entityIndex := make(map[string]struct{})
+	for _, entity := range entities {
+		entityIndex[entity] = struct{}{}
+	}
+
+	tagger := Tagger{option: option}
+	tagger.reset()
+	tagger.applyIndex(entityIndex, relations)
+	return &tagger
+}
+
+func TestTagger_MatchEntities(t *testing.T) {
+	text := "Ｐｙｔｈｏｎ写的北京航空航天大学选课系统"
+	entities := []string{"北京", "北京航空航天大学", "航空", "大学", "python"}
+
+	match := func(option MatcherOption) []string {
+		tagger := newTestTagger(option, entities, nil)
+		defer tagger.Free()
+
+		ret := make([]string, 0)
+		for _, entity := range tagger.matchEntities(text) {
+			ret = append(ret, text[entity.Begin:entity.End]+"="+entity.Name)
+		}
+		return ret
+	}
+
+	assert.Equal(t, []string{"北京航空航天大学=北京航空航天大学"},
+		match(MatcherOption{Mode: MatchModeLongest}))
+
+	assert.Equal(t, []string{
+		"北京航空航天大学=北京航空航天大学",
+		"北京=北京",
+		"航空=航空",
+		"大学=大学",
+	}, match(MatcherOption{Mode: MatchModeOverlap}))
+
+	assert.Equal(t, []string{
+		"Ｐｙｔｈｏｎ=python",
+		"北京航空航天大学=北京航空航天大学",
+	}, match(MatcherOption{Mode: MatchModeLongest, FoldCase: true, FoldWidth: true}))
+
+	// 只忽略全角半角时大小写不同不匹配
+	assert.Equal(t, []string{"北京航空航天大学=北京航空航天大学"},
+		match(MatcherOption{Mode: MatchModeLongest, FoldWidth: true}))
+}
+
+func TestTagger_ProduceOverlap(t *testing.T) {
+	relations := map[SOTuple][]string{
+		{HeadEntity: "北京航空航天大学", TailEntity: "北京"}: {"位于"},
+		{HeadEntity: "小明", TailEntity: "北京航空航天大学"}: {"就读于"},
+	}
+	entities := []string{"北京", "北京航空航天大学", "小明"}
+	text := "小明就读于北京航空航天大学"
+
+	produce := func(option MatcherOption) []string {
+		tagger := newTestTagger(option, entities, relations)
+		defer tagger.Free()
+
+		ret := make([]string, 0)
+		for _, spo := range tagger.Produce(text) {
+			ret = append(ret, text[spo.HeadEntity.Begin:spo.HeadEntity.End]+"-"+spo.Relation+"-"+text[spo.TailEntity.Begin:spo.TailEntity.End])
+		}
+		return ret
+	}
+
+	assert.ElementsMatch(t, []string{"小明-就读于-北京航空航天大学"}, produce(MatcherOption{Mode: MatchModeLongest}))
+	assert.ElementsMatch(t, []string{"小明-就读于-北京航空航天大学", "北京航空航天大学-位于-北京"}, produce(MatcherOption{Mode: MatchModeOverlap}))
+}
+
+func TestParseMatchMode(t *testing.T) {
+	mode, err := ParseMatchMode("")
+	require.Nil(t, err)
+	assert.Equal(t, MatchModeJieba, mode)
+
+	mode, err = ParseMatchMode("Longest")
+	require.Nil(t, err)
+	assert.Equal(t, MatchModeLongest, mode)
+
+	_, err = ParseMatchMode("regex")
+	assert.ErrorIs(t, err, ErrUnknownMatchMode)
+}
@@ -5,6 +5,7 @@ go 1.18
 require (
 	github.com/gin-contrib/cors v1.3.1
 	github.com/gin-gonic/gin v1.7.7
+	github.com/mozillazg/go-pinyin v0.19.0
 	github.com/neo4j/neo4j-go-driver/v4 v4.4.3
 	github.com/sirupsen/logrus v1.8.1
 	github.com/streadway/amqp v1.0.0
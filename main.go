@@ -2,11 +2,18 @@ package main
 
 import (
 	"autograph-backend-controller/config"
+	"autograph-backend-controller/domain/alias"
+	"autograph-backend-controller/domain/autolabel"
+	"autograph-backend-controller/domain/buildjob"
+	"autograph-backend-controller/domain/dataset"
 	"autograph-backend-controller/domain/extractorcall"
 	"autograph-backend-controller/domain/graph"
+	"autograph-backend-controller/domain/importer"
+	"autograph-backend-controller/domain/suggest"
 	"autograph-backend-controller/domain/tagger"
 	"autograph-backend-controller/logging"
 	"autograph-backend-controller/repository/filesave"
+	"autograph-backend-controller/repository/graphstore"
 	"autograph-backend-controller/repository/metadata"
 	"autograph-backend-controller/repository/neograph"
 	"autograph-backend-controller/server"
@@ -94,12 +101,15 @@ func neographConf() *neograph.Config {
 	}
 
 	// TODO
-	return &neograph.Config{Neo4j: neograph.Neo4jConfig{
-		Host: "",
-		Port: 0,
-		User: "",
-		Pwd:  "",
-	}}
+	return &neograph.Config{
+		Neo4j: neograph.Neo4jConfig{
+			Host: "",
+			Port: 0,
+			User: "",
+			Pwd:  "",
+		},
+		CheckMigration: true,
+	}
 }
 
 func graphConf() *graph.KGSetting {
@@ -109,6 +119,48 @@ func graphConf() *graph.KGSetting {
 	}
 }
 
+func aliasConf() *alias.AliasSetting {
+	return &alias.AliasSetting{
+		GetMetadataDatabase: metadata.DatabaseRaw,
+		Logger:              logging.NewLogger(),
+	}
+}
+
+func suggestConf() *suggest.SuggestSetting {
+	return &suggest.SuggestSetting{
+		GetMetadataDatabase: metadata.DatabaseRaw,
+		Logger:              logging.NewLogger(),
+	}
+}
+
+func importerConf() *importer.ImportSetting {
+	return &importer.ImportSetting{
+		GetMetadataDatabase: metadata.DatabaseRaw,
+		Logger:              logging.NewLogger(),
+	}
+}
+
+func datasetConf() *dataset.DatasetSetting {
+	return &dataset.DatasetSetting{
+		GetMetadataDatabase: metadata.DatabaseRaw,
+		Logger:              logging.NewLogger(),
+	}
+}
+
+func autolabelConf() *autolabel.LabelSetting {
+	return &autolabel.LabelSetting{
+		GetMetadataDatabase: metadata.DatabaseRaw,
+		Logger:              logging.NewLogger(),
+	}
+}
+
+func buildjobConf() *buildjob.JobSetting {
+	return &buildjob.JobSetting{
+		GetMetadataDatabase: metadata.DatabaseRaw,
+		Logger:              logging.NewLogger(),
+	}
+}
+
 func main() {
 	logging.SetDefaultConfig(loggingConf())
 	logger := logging.NewLogger()
@@ -126,9 +178,22 @@ func main() {
 
 	neograph.Init(neographConf())
 	defer neograph.Close()
+	graphstore.Init(graphstore.NewNeo4jStore())
+
+	alias.Init(aliasConf())
 
 	graph.Init(graphConf())
 
+	suggest.Init(suggestConf())
+
+	importer.Init(importerConf())
+
+	dataset.Init(datasetConf())
+
+	autolabel.Init(autolabelConf())
+
+	buildjob.Init(buildjobConf())
+
 	s := server.New(&server.Config{
 		Host:      "",
 		Port:      8003,
@@ -12,3 +12,7 @@ func SaveFile(data []byte) (SaveFileResp, error) {
 
 	return SaveFileResp{resp}, nil
 }
+
+func DeleteFile(url string) error {
+	return deleteFile(&globalConfig, url)
+}
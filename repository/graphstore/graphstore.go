@@ -0,0 +1,135 @@
+package graphstore
+
+import (
+	"autograph-backend-controller/utils"
+	"context"
+	"errors"
+)
+
+var ErrInvalidQuery = errors.New("invalid graph query")
+
+/*
+Entity 图谱中的实体。ID 由存储在查询时填充，仅在一次查询的结果中用于区分实体，导入时忽略。
+*/
+type Entity struct {
+	ID       string
+	Name     string
+	Category string
+	Source   string // SchemaNodeSource 的 JSON
+}
+
+/*
+Relation 图谱中的关系，Head 与 Tail 为实体名，Confidence 为空表示没有置信度。
+*/
+type Relation struct {
+	Head       string
+	Name       string
+	Tail       string
+	Confidence *float64
+}
+
+/*
+Subgraph 查询得到的子图，Relations 的头尾实体都在 Entities 中。
+*/
+type Subgraph struct {
+	Entities  []Entity
+	Relations []Relation
+}
+
+/*
+Path 一条路径，Entities 依次为路径上的实体，Relations[i] 连接 Entities[i] 与 Entities[i+1]，方向以 Relation 的 Head 与 Tail 为准。
+*/
+type Path struct {
+	Entities  []Entity
+	Relations []Relation
+}
+
+/*
+VersionSource 提供一个版本的全部实体和关系。导入时先遍历全部实体，再遍历全部关系，每个方法最多被调用一次。
+*/
+type VersionSource interface {
+	Entities(ctx context.Context, fn func(entity *Entity) error) error
+	Relations(ctx context.Context, fn func(relation *Relation) error) error
+}
+
+const (
+	DirectionOut  = "out"
+	DirectionIn   = "in"
+	DirectionBoth = "both"
+)
+
+/*
+NeighbourhoodQuery 查询从 Name 出发、沿 Direction 方向不超过 Depth 跳的所有路径组成的子图，Direction 为空时沿出边。
+Categories 非空时，路径上除起点外的实体都必须属于其中的类别；Relations 非空时，路径上的关系名都必须在其中。
+Limit 非零时最多返回 Limit 个实体（含起点），距离起点近的实体优先，只返回两端都在结果中的关系。
+*/
+type NeighbourhoodQuery struct {
+	Name       string
+	Depth      int
+	Direction  string
+	Categories []string
+	Relations  []string
+	Limit      int
+}
+
+/*
+PathQuery 查询 From 与 To 之间不超过 MaxDepth 跳的所有最短路径，不区分关系的方向，最多返回 Limit 条。
+*/
+type PathQuery struct {
+	From     string
+	To       string
+	MaxDepth int
+	Limit    int
+}
+
+/*
+GraphStore 按版本保存图谱并提供查询，实现需要保证同一版本中实体名唯一。
+*/
+type GraphStore interface {
+	// LoadVersion 导入一个版本，已存在的同一版本会被覆盖，失败时不会留下部分数据
+	LoadVersion(ctx context.Context, version uint, source VersionSource) error
+	Neighbourhood(ctx context.Context, version uint, query *NeighbourhoodQuery) (*Subgraph, error)
+	Paths(ctx context.Context, version uint, query *PathQuery) ([]Path, error)
+	// DeleteVersion 删除一个版本，版本不存在时不返回错误
+	DeleteVersion(ctx context.Context, version uint) error
+}
+
+const (
+	MaxNeighbourhoodDepth = 4
+	MaxPathDepth          = 6
+)
+
+func checkNeighbourhoodQuery(query *NeighbourhoodQuery) error {
+	if query.Depth < 1 || query.Depth > MaxNeighbourhoodDepth {
+		return utils.WrapErrorf(ErrInvalidQuery, "depth %d out of range [1, %d]", query.Depth, MaxNeighbourhoodDepth)
+	}
+
+	switch query.Direction {
+	case "", DirectionOut, DirectionIn, DirectionBoth:
+	default:
+		return utils.WrapErrorf(ErrInvalidQuery, "unknown direction %#v", query.Direction)
+	}
+
+	if query.Limit < 0 {
+		return utils.WrapErrorf(ErrInvalidQuery, "limit %d cannot be negative", query.Limit)
+	}
+
+	return nil
+}
+
+func checkPathQuery(query *PathQuery) error {
+	// Neo4j 的 allShortestPaths 不接受相同的起点和终点
+	if query.From == query.To {
+		return utils.WrapErrorf(ErrInvalidQuery, "from and to are both %#v", query.From)
+	}
+
+	if query.MaxDepth < 1 || query.MaxDepth > MaxPathDepth {
+		return utils.WrapErrorf(ErrInvalidQuery, "max depth %d out of range [1, %d]", query.MaxDepth, MaxPathDepth)
+	}
+
+	if query.Limit < 1 {
+		return utils.WrapErrorf(ErrInvalidQuery, "limit %d must be positive", query.Limit)
+	}
+
+	return nil
+}
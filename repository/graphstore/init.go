@@ -0,0 +1,14 @@
+package graphstore
+
+var globalStore GraphStore
+
+/*
+Init 设置全局使用的图谱存储，服务使用 Neo4j，测试可以使用内存实现。
+*/
+func Init(store GraphStore) {
+	globalStore = store
+}
+
+func Default() GraphStore {
+	return globalStore
+}
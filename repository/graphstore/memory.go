@@ -0,0 +1,290 @@
+package graphstore
+
+import (
+	"autograph-backend-controller/utils"
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+/*
+memoryStore 将图谱保存在内存中的存储，用于测试。查询的语义与 neo4jStore 相同。
+*/
+type memoryStore struct {
+	sync.RWMutex
+	versions map[uint]*memoryVersion
+	nextID   int64
+}
+
+/*
+memoryVersion 一个版本的图谱，out 与 in 分别为实体名 -> 出边与入边，按关系名和相邻实体名排序。
+*/
+type memoryVersion struct {
+	entities map[string]*Entity
+	out      map[string][]Relation
+	in       map[string][]Relation
+}
+
+func NewMemoryStore() GraphStore {
+	return &memoryStore{
+		versions: make(map[uint]*memoryVersion),
+	}
+}
+
+/*
+LoadVersion 先在新的 memoryVersion 中完成导入，成功后再替换原有的版本，因此失败时不会留下部分数据。
+头尾实体不存在的关系会被忽略，与 Neo4j 中 match 不到实体时的行为相同。
+*/
+func (s *memoryStore) LoadVersion(ctx context.Context, version uint, source VersionSource) error {
+	v := memoryVersion{
+		entities: make(map[string]*Entity),
+		out:      make(map[string][]Relation),
+		in:       make(map[string][]Relation),
+	}
+
+	err := source.Entities(ctx, func(entity *Entity) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		e := *entity
+		e.ID = strconv.FormatInt(s.newID(), 10)
+		v.entities[e.Name] = &e
+		return nil
+	})
+	if err != nil {
+		return utils.WrapErrorf(err, "load entities of version [%d] fail", version)
+	}
+
+	relations := make(map[relationKey]Relation)
+	err = source.Relations(ctx, func(relation *Relation) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if v.entities[relation.Head] == nil || v.entities[relation.Tail] == nil {
+			return nil
+		}
+
+		relations[relationKey{head: relation.Head, name: relation.Name, tail: relation.Tail}] = *relation
+		return nil
+	})
+	if err != nil {
+		return utils.WrapErrorf(err, "load relations of version [%d] fail", version)
+	}
+
+	for _, relation := range relations {
+		v.out[relation.Head] = append(v.out[relation.Head], relation)
+		v.in[relation.Tail] = append(v.in[relation.Tail], relation)
+	}
+	for _, edges := range v.out {
+		sort.Slice(edges, func(i, j int) bool {
+			return edges[i].Name < edges[j].Name || edges[i].Name == edges[j].Name && edges[i].Tail < edges[j].Tail
+		})
+	}
+	for _, edges := range v.in {
+		sort.Slice(edges, func(i, j int) bool {
+			return edges[i].Name < edges[j].Name || edges[i].Name == edges[j].Name && edges[i].Head < edges[j].Head
+		})
+	}
+
+	s.Lock()
+	s.versions[version] = &v
+	s.Unlock()
+
+	return nil
+}
+
+func (s *memoryStore) newID() int64 {
+	s.Lock()
+	defer s.Unlock()
+
+	s.nextID++
+	return s.nextID
+}
+
+func (s *memoryStore) DeleteVersion(ctx context.Context, version uint) error {
+	s.Lock()
+	delete(s.versions, version)
+	s.Unlock()
+
+	return nil
+}
+
+func (s *memoryStore) version(version uint) *memoryVersion {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.versions[version]
+}
+
+/*
+Neighbourhood 按跳数广度优先遍历。一条边属于结果当且仅当其起点可以在 Depth-1 跳内经由满足条件的实体和关系到达，且边与终点都满足条件，
+这与 Neo4j 中由所有满足条件的路径组成的子图相同。
+*/
+func (s *memoryStore) Neighbourhood(ctx context.Context, version uint, query *NeighbourhoodQuery) (*Subgraph, error) {
+	if err := checkNeighbourhoodQuery(query); err != nil {
+		return nil, err
+	}
+
+	builder := newSubgraphBuilder(query.Limit)
+
+	v := s.version(version)
+	if v == nil || v.entities[query.Name] == nil {
+		return builder.subgraph(), nil
+	}
+
+	categories := toSet(query.Categories)
+	relations := toSet(query.Relations)
+	accept := func(relation *Relation, next string) bool {
+		if len(relations) != 0 {
+			if _, ok := relations[relation.Name]; !ok {
+				return false
+			}
+		}
+		if len(categories) != 0 {
+			if _, ok := categories[v.entities[next].Category]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	direction := query.Direction
+	if direction == "" {
+		direction = DirectionOut
+	}
+
+	visited := map[string]struct{}{query.Name: {}}
+	frontier := []string{query.Name}
+	for depth := 0; depth < query.Depth && len(frontier) != 0; depth++ {
+		next := make([]string, 0)
+		visit := func(name string, relation *Relation, neighbour string) {
+			if !accept(relation, neighbour) {
+				return
+			}
+
+			if !builder.addEntity(v.entities[name]) || !builder.addEntity(v.entities[neighbour]) {
+				return
+			}
+			builder.addRelation(relation)
+
+			if _, ok := visited[neighbour]; !ok {
+				visited[neighbour] = struct{}{}
+				next = append(next, neighbour)
+			}
+		}
+
+		for _, name := range frontier {
+			if direction == DirectionOut || direction == DirectionBoth {
+				for i := range v.out[name] {
+					visit(name, &v.out[name][i], v.out[name][i].Tail)
+				}
+			}
+			if direction == DirectionIn || direction == DirectionBoth {
+				for i := range v.in[name] {
+					visit(name, &v.in[name][i], v.in[name][i].Head)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	return builder.subgraph(), nil
+}
+
+func toSet(list []string) map[string]struct{} {
+	ret := make(map[string]struct{}, len(list))
+	for _, item := range list {
+		ret[item] = struct{}{}
+	}
+	return ret
+}
+
+/*
+Paths 不区分方向地广度优先遍历，记录每个实体在最短路径上的前驱关系，再从终点回溯出所有最短路径。
+*/
+func (s *memoryStore) Paths(ctx context.Context, version uint, query *PathQuery) ([]Path, error) {
+	if err := checkPathQuery(query); err != nil {
+		return nil, err
+	}
+
+	ret := make([]Path, 0)
+
+	v := s.version(version)
+	if v == nil || v.entities[query.From] == nil || v.entities[query.To] == nil || query.From == query.To {
+		return ret, nil
+	}
+
+	type step struct {
+		prev     string
+		relation *Relation
+	}
+
+	dist := map[string]int{query.From: 0}
+	prevs := make(map[string][]step)
+	frontier := []string{query.From}
+	for depth := 0; depth < query.MaxDepth && len(frontier) != 0; depth++ {
+		if _, ok := dist[query.To]; ok {
+			break
+		}
+
+		next := make([]string, 0)
+		visit := func(from, to string, relation *Relation) {
+			d, ok := dist[to]
+			if !ok {
+				dist[to] = depth + 1
+				next = append(next, to)
+			} else if d != depth+1 {
+				return
+			}
+			prevs[to] = append(prevs[to], step{prev: from, relation: relation})
+		}
+
+		for _, name := range frontier {
+			for i := range v.out[name] {
+				visit(name, v.out[name][i].Tail, &v.out[name][i])
+			}
+			for i := range v.in[name] {
+				visit(name, v.in[name][i].Head, &v.in[name][i])
+			}
+		}
+		frontier = next
+	}
+
+	if _, ok := dist[query.To]; !ok {
+		return ret, nil
+	}
+
+	// 从终点回溯，names 与 relations 为逆序的路径
+	var backtrack func(name string, names []string, relations []Relation)
+	backtrack = func(name string, names []string, relations []Relation) {
+		if len(ret) >= query.Limit {
+			return
+		}
+
+		names = append(names, name)
+		if name == query.From {
+			path := Path{
+				Entities:  make([]Entity, 0, len(names)),
+				Relations: make([]Relation, 0, len(relations)),
+			}
+			for i := len(names) - 1; i >= 0; i-- {
+				path.Entities = append(path.Entities, *v.entities[names[i]])
+			}
+			for i := len(relations) - 1; i >= 0; i-- {
+				path.Relations = append(path.Relations, relations[i])
+			}
+			ret = append(ret, path)
+			return
+		}
+
+		for _, st := range prevs[name] {
+			backtrack(st.prev, names, append(relations, *st.relation))
+		}
+	}
+	backtrack(query.To, make([]string, 0), make([]Relation, 0))
+
+	return ret, nil
+}
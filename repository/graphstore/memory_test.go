@@ -0,0 +1,169 @@
+package graphstore
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"testing"
+)
+
+type sliceSource struct {
+	entities  []Entity
+	relations []Relation
+	err       error
+}
+
+func (s *sliceSource) Entities(ctx context.Context, fn func(entity *Entity) error) error {
+	for i := range s.entities {
+		if err := fn(&s.entities[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sliceSource) Relations(ctx context.Context, fn func(relation *Relation) error) error {
+	for i := range s.relations {
+		if err := fn(&s.relations[i]); err != nil {
+			return err
+		}
+	}
+	return s.err
+}
+
+func relationNames(relations []Relation) []string {
+	ret := make([]string, 0, len(relations))
+	for _, r := range relations {
+		ret = append(ret, r.Head+"-"+r.Name+"->"+r.Tail)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.TODO()
+	store := NewMemoryStore()
+
+	/*
+		A --r1--> B --r2--> C --r3--> D
+		A --r4--> E(location)
+		E --r5--> C
+	*/
+	source := sliceSource{
+		entities: []Entity{
+			{Name: "A", Category: "person"},
+			{Name: "B", Category: "person"},
+			{Name: "C", Category: "person"},
+			{Name: "D", Category: "person"},
+			{Name: "E", Category: "location"},
+		},
+		relations: []Relation{
+			{Head: "A", Name: "r1", Tail: "B"},
+			{Head: "B", Name: "r2", Tail: "C"},
+			{Head: "C", Name: "r3", Tail: "D"},
+			{Head: "A", Name: "r4", Tail: "E"},
+			{Head: "E", Name: "r5", Tail: "C"},
+			{Head: "A", Name: "r6", Tail: "Missing"},
+		},
+	}
+	require.Nil(t, store.LoadVersion(ctx, 1, &source))
+
+	t.Run("neighbourhood", func(t *testing.T) {
+		subgraph, err := store.Neighbourhood(ctx, 1, &NeighbourhoodQuery{Name: "A", Depth: 2})
+		require.Nil(t, err)
+		assert.Equal(t, []string{"A-r1->B", "A-r4->E", "B-r2->C", "E-r5->C"}, relationNames(subgraph.Relations))
+		assert.Len(t, subgraph.Entities, 4)
+
+		subgraph, err = store.Neighbourhood(ctx, 1, &NeighbourhoodQuery{Name: "A", Depth: 3, Categories: []string{"person"}})
+		require.Nil(t, err)
+		assert.Equal(t, []string{"A-r1->B", "B-r2->C", "C-r3->D"}, relationNames(subgraph.Relations))
+
+		subgraph, err = store.Neighbourhood(ctx, 1, &NeighbourhoodQuery{Name: "D", Depth: 1})
+		require.Nil(t, err)
+		assert.Empty(t, subgraph.Entities)
+
+		_, err = store.Neighbourhood(ctx, 1, &NeighbourhoodQuery{Name: "A", Depth: MaxNeighbourhoodDepth + 1})
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+
+		_, err = store.Neighbourhood(ctx, 1, &NeighbourhoodQuery{Name: "A", Depth: 1, Direction: "up"})
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+	})
+
+	t.Run("direction", func(t *testing.T) {
+		subgraph, err := store.Neighbourhood(ctx, 1, &NeighbourhoodQuery{Name: "C", Depth: 1, Direction: DirectionIn})
+		require.Nil(t, err)
+		assert.Equal(t, []string{"B-r2->C", "E-r5->C"}, relationNames(subgraph.Relations))
+
+		subgraph, err = store.Neighbourhood(ctx, 1, &NeighbourhoodQuery{Name: "C", Depth: 1, Direction: DirectionBoth})
+		require.Nil(t, err)
+		assert.Equal(t, []string{"B-r2->C", "C-r3->D", "E-r5->C"}, relationNames(subgraph.Relations))
+
+		subgraph, err = store.Neighbourhood(ctx, 1, &NeighbourhoodQuery{Name: "D", Depth: 2, Direction: DirectionIn})
+		require.Nil(t, err)
+		assert.Equal(t, []string{"B-r2->C", "C-r3->D", "E-r5->C"}, relationNames(subgraph.Relations))
+	})
+
+	t.Run("relation filter and limit", func(t *testing.T) {
+		subgraph, err := store.Neighbourhood(ctx, 1, &NeighbourhoodQuery{Name: "A", Depth: 3, Relations: []string{"r1", "r2", "r5"}})
+		require.Nil(t, err)
+		assert.Equal(t, []string{"A-r1->B", "B-r2->C"}, relationNames(subgraph.Relations))
+
+		// 距离近的实体优先
+		subgraph, err = store.Neighbourhood(ctx, 1, &NeighbourhoodQuery{Name: "A", Depth: 3, Limit: 3})
+		require.Nil(t, err)
+		assert.Len(t, subgraph.Entities, 3)
+		assert.Equal(t, []string{"A-r1->B", "A-r4->E"}, relationNames(subgraph.Relations))
+	})
+
+	t.Run("paths", func(t *testing.T) {
+		paths, err := store.Paths(ctx, 1, &PathQuery{From: "A", To: "C", MaxDepth: 3, Limit: 10})
+		require.Nil(t, err)
+		require.Len(t, paths, 2)
+		for _, path := range paths {
+			require.Len(t, path.Entities, 3)
+			assert.Equal(t, "A", path.Entities[0].Name)
+			assert.Equal(t, "C", path.Entities[2].Name)
+		}
+
+		// 不区分方向
+		paths, err = store.Paths(ctx, 1, &PathQuery{From: "D", To: "B", MaxDepth: 3, Limit: 10})
+		require.Nil(t, err)
+		require.Len(t, paths, 1)
+		assert.Equal(t, []string{"B-r2->C", "C-r3->D"}, relationNames(paths[0].Relations))
+
+		paths, err = store.Paths(ctx, 1, &PathQuery{From: "A", To: "C", MaxDepth: 3, Limit: 1})
+		require.Nil(t, err)
+		assert.Len(t, paths, 1)
+
+		paths, err = store.Paths(ctx, 1, &PathQuery{From: "A", To: "D", MaxDepth: 2, Limit: 10})
+		require.Nil(t, err)
+		assert.Empty(t, paths)
+
+		_, err = store.Paths(ctx, 1, &PathQuery{From: "A", To: "A", MaxDepth: 3, Limit: 10})
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+	})
+
+	t.Run("reload and delete", func(t *testing.T) {
+		// 导入失败时保留原有的数据
+		failed := sliceSource{entities: source.entities[:1], err: errors.New("source fail")}
+		assert.NotNil(t, store.LoadVersion(ctx, 1, &failed))
+
+		subgraph, err := store.Neighbourhood(ctx, 1, &NeighbourhoodQuery{Name: "A", Depth: 1})
+		require.Nil(t, err)
+		assert.Len(t, subgraph.Relations, 2)
+
+		// 重复导入覆盖原有的版本
+		require.Nil(t, store.LoadVersion(ctx, 1, &sliceSource{entities: source.entities}))
+		subgraph, err = store.Neighbourhood(ctx, 1, &NeighbourhoodQuery{Name: "A", Depth: 1})
+		require.Nil(t, err)
+		assert.Empty(t, subgraph.Relations)
+
+		require.Nil(t, store.DeleteVersion(ctx, 1))
+		require.Nil(t, store.DeleteVersion(ctx, 1))
+		paths, err := store.Paths(ctx, 1, &PathQuery{From: "A", To: "B", MaxDepth: 1, Limit: 1})
+		require.Nil(t, err)
+		assert.Empty(t, paths)
+	})
+}
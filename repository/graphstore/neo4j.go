@@ -0,0 +1,349 @@
+package graphstore
+
+import (
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/repository/neograph"
+	"autograph-backend-controller/utils"
+	"context"
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"strconv"
+)
+
+/*
+categoryLabels 实体类别 -> Neo4j 标签。标签无法作为 Cypher 的参数，因此只有白名单中的类别会成为标签。
+*/
+var categoryLabels = map[string]string{
+	metadata.EntityCategoryPerson:       "Person",
+	metadata.EntityCategoryOrganization: "Organization",
+	metadata.EntityCategoryLocation:     "Location",
+	metadata.EntityCategoryTime:         "Time",
+	metadata.EntityCategoryEvent:        "Event",
+	metadata.EntityCategoryWork:         "Work",
+	metadata.EntityCategoryConcept:      "Concept",
+}
+
+/*
+neo4jStore 基于 neograph 的图谱存储，需要先调用 neograph.Init
+*/
+type neo4jStore struct{}
+
+func NewNeo4jStore() GraphStore {
+	return &neo4jStore{}
+}
+
+/*
+neo4jSource 将 VersionSource 转换为 neograph.VersionSource
+*/
+type neo4jSource struct {
+	source VersionSource
+}
+
+func (s *neo4jSource) Entities(ctx context.Context, fn func(entity *neograph.LoadEntity) error) error {
+	return s.source.Entities(ctx, func(entity *Entity) error {
+		return fn(&neograph.LoadEntity{
+			Name:     entity.Name,
+			Category: entity.Category,
+			Source:   entity.Source,
+			Label:    categoryLabels[entity.Category],
+		})
+	})
+}
+
+func (s *neo4jSource) Relations(ctx context.Context, fn func(relation *neograph.LoadRelation) error) error {
+	return s.source.Relations(ctx, func(relation *Relation) error {
+		return fn(&neograph.LoadRelation{
+			Head:       relation.Head,
+			Name:       relation.Name,
+			Tail:       relation.Tail,
+			Confidence: relation.Confidence,
+		})
+	})
+}
+
+func (s *neo4jStore) LoadVersion(ctx context.Context, version uint, source VersionSource) error {
+	if err := neograph.LoadVersion(ctx, version, &neo4jSource{source: source}); err != nil {
+		return utils.WrapErrorf(err, "load version [%d] to neo4j fail", version)
+	}
+
+	return nil
+}
+
+func (s *neo4jStore) DeleteVersion(ctx context.Context, version uint) error {
+	if err := neograph.DeleteVersion(ctx, version); err != nil {
+		return utils.WrapErrorf(err, "delete version [%d] from neo4j fail", version)
+	}
+
+	return nil
+}
+
+/*
+neighbourhoodPatterns 各个方向的单跳关系模式，a 为当前一跳的实体，b 为其邻居
+*/
+var neighbourhoodPatterns = map[string]string{
+	"":            `(a)-[r:Relation]->(b:Entity)`,
+	DirectionOut:  `(a)-[r:Relation]->(b:Entity)`,
+	DirectionIn:   `(a)<-[r:Relation]-(b:Entity)`,
+	DirectionBoth: `(a)-[r:Relation]-(b:Entity)`,
+}
+
+/*
+neighbourhoodMaxRows 每一跳查询最多返回的关系数，避免高连接度的实体在一次查询中展开过多的关系
+*/
+const neighbourhoodMaxRows = 10000
+
+/*
+Neighbourhood 与 memoryStore 相同，按跳数广度优先遍历，每一跳只查询当前一跳实体的关系并在查询中限制返回数量，因此距离起点近的实体优先。
+子图中的实体达到数量限制后，之后的跳只查询两端都已在子图中的关系。
+*/
+func (s *neo4jStore) Neighbourhood(ctx context.Context, version uint, query *NeighbourhoodQuery) (*Subgraph, error) {
+	if err := checkNeighbourhoodQuery(query); err != nil {
+		return nil, err
+	}
+
+	cypher := fmt.Sprintf(`
+		match (a:Entity)
+		where a.version = $version and a.name in $frontier
+		match %s
+		where r.version = $version
+			and (size($relations) = 0 or r.name in $relations)
+			and (size($categories) = 0 or b.category in $categories)
+			and (not $full or b.name in $names)
+		return a, r, b
+		limit $maxRows
+	`, neighbourhoodPatterns[query.Direction])
+
+	builder := newSubgraphBuilder(query.Limit)
+	visited := map[string]struct{}{query.Name: {}}
+	frontier := []string{query.Name}
+	for depth := 0; depth < query.Depth && len(frontier) != 0; depth++ {
+		full := query.Limit != 0 && len(builder.entities) >= query.Limit
+		names := make([]string, 0)
+		if full {
+			for i := range builder.entities {
+				names = append(names, builder.entities[i].Name)
+			}
+		}
+
+		records, err := neograph.Execute(ctx, cypher, map[string]interface{}{
+			"version":    version,
+			"frontier":   frontier,
+			"categories": nonNilStrings(query.Categories),
+			"relations":  nonNilStrings(query.Relations),
+			"full":       full,
+			"names":      names,
+			"maxRows":    neighbourhoodMaxRows,
+		})
+		if err != nil {
+			return nil, utils.WrapErrorf(err, "query neighbourhood of %#v at depth [%d] in version [%d] fail", query.Name, depth+1, version)
+		}
+
+		next := make([]string, 0)
+		for _, record := range records {
+			from, relation, neighbour, err := parseStep(record)
+			if err != nil {
+				return nil, utils.WrapError(err, "parse neighbourhood step fail")
+			}
+
+			if !builder.addEntity(from) || !builder.addEntity(neighbour) {
+				continue
+			}
+			builder.addRelation(relation)
+
+			if _, ok := visited[neighbour.Name]; !ok {
+				visited[neighbour.Name] = struct{}{}
+				next = append(next, neighbour.Name)
+			}
+		}
+		frontier = next
+	}
+
+	return builder.subgraph(), nil
+}
+
+/*
+parseStep 解析单跳查询返回的 a, r, b
+*/
+func parseStep(record *neo4j.Record) (*Entity, *Relation, *Entity, error) {
+	if len(record.Values) != 3 {
+		return nil, nil, nil, fmt.Errorf("unexpected record length [%d]", len(record.Values))
+	}
+
+	from, ok := record.Values[0].(neo4j.Node)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("value is not node [%#v]", record.Values[0])
+	}
+
+	relationship, ok := record.Values[1].(neo4j.Relationship)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("value is not relationship [%#v]", record.Values[1])
+	}
+
+	neighbour, ok := record.Values[2].(neo4j.Node)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("value is not node [%#v]", record.Values[2])
+	}
+
+	fromEntity, err := parseNode(&from)
+	if err != nil {
+		return nil, nil, nil, utils.WrapErrorf(err, "parse node [%d] fail", from.Id)
+	}
+
+	neighbourEntity, err := parseNode(&neighbour)
+	if err != nil {
+		return nil, nil, nil, utils.WrapErrorf(err, "parse node [%d] fail", neighbour.Id)
+	}
+
+	names := map[int64]string{
+		from.Id:      fromEntity.Name,
+		neighbour.Id: neighbourEntity.Name,
+	}
+	relation, err := parseRelationship(&relationship, names)
+	if err != nil {
+		return nil, nil, nil, utils.WrapErrorf(err, "parse relationship [%d] fail", relationship.Id)
+	}
+
+	return fromEntity, relation, neighbourEntity, nil
+}
+
+/*
+nonNilStrings 作为 Cypher 参数的 nil 切片会被当作 null，size(null) 为 null，因此转换为空列表
+*/
+func nonNilStrings(list []string) []string {
+	if list == nil {
+		return make([]string, 0)
+	}
+	return list
+}
+
+func (s *neo4jStore) Paths(ctx context.Context, version uint, query *PathQuery) ([]Path, error) {
+	if err := checkPathQuery(query); err != nil {
+		return nil, err
+	}
+
+	cypher := fmt.Sprintf(`
+		match (a:Entity{
+			version: $version,
+			name: $from
+		}), (b:Entity{
+			version: $version,
+			name: $to
+		})
+		match p = allShortestPaths((a)-[:Relation*..%d]-(b))
+		where all(r in relationships(p) where r.version = $version)
+		return p
+		limit $limit
+	`, query.MaxDepth)
+
+	records, err := neograph.Execute(ctx, cypher, map[string]interface{}{
+		"version": version,
+		"from":    query.From,
+		"to":      query.To,
+		"limit":   query.Limit,
+	})
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "query paths from %#v to %#v in version [%d] fail", query.From, query.To, version)
+	}
+
+	ret := make([]Path, 0, len(records))
+	for _, record := range records {
+		path, err := parsePath(record)
+		if err != nil {
+			return nil, utils.WrapError(err, "parse path fail")
+		}
+
+		ret = append(ret, *path)
+	}
+
+	return ret, nil
+}
+
+func parsePath(record *neo4j.Record) (*Path, error) {
+	if len(record.Values) == 0 {
+		return nil, fmt.Errorf("empty record")
+	}
+
+	path, ok := record.Values[0].(neo4j.Path)
+	if !ok {
+		return nil, fmt.Errorf("value is not path [%#v]", record.Values[0])
+	}
+
+	ret := Path{
+		Entities:  make([]Entity, 0, len(path.Nodes)),
+		Relations: make([]Relation, 0, len(path.Relationships)),
+	}
+
+	names := make(map[int64]string, len(path.Nodes))
+	for i := range path.Nodes {
+		entity, err := parseNode(&path.Nodes[i])
+		if err != nil {
+			return nil, utils.WrapErrorf(err, "parse node [%d] fail", path.Nodes[i].Id)
+		}
+
+		names[path.Nodes[i].Id] = entity.Name
+		ret.Entities = append(ret.Entities, *entity)
+	}
+
+	for i := range path.Relationships {
+		relation, err := parseRelationship(&path.Relationships[i], names)
+		if err != nil {
+			return nil, utils.WrapErrorf(err, "parse relationship [%d] fail", path.Relationships[i].Id)
+		}
+
+		ret.Relations = append(ret.Relations, *relation)
+	}
+
+	return &ret, nil
+}
+
+func parseNode(node *neo4j.Node) (*Entity, error) {
+	name, ok := node.Props["name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("node name is not string [%#v]", node.Props["name"])
+	}
+
+	// 旧版本构建的节点没有类别
+	category, _ := node.Props["category"].(string)
+
+	source, ok := node.Props["source"].(string)
+	if !ok {
+		return nil, fmt.Errorf("node source is not string [%#v]", node.Props["source"])
+	}
+
+	return &Entity{
+		ID:       strconv.FormatInt(node.Id, 10),
+		Name:     name,
+		Category: category,
+		Source:   source,
+	}, nil
+}
+
+func parseRelationship(relationship *neo4j.Relationship, names map[int64]string) (*Relation, error) {
+	name, ok := relationship.Props["name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("relation name is not string [%#v]", relationship.Props["name"])
+	}
+
+	head, ok := names[relationship.StartId]
+	if !ok {
+		return nil, fmt.Errorf("head [%d] not found in path", relationship.StartId)
+	}
+
+	tail, ok := names[relationship.EndId]
+	if !ok {
+		return nil, fmt.Errorf("tail [%d] not found in path", relationship.EndId)
+	}
+
+	// 旧版本构建的边没有置信度
+	var confidence *float64
+	if value, ok := relationship.Props["confidence"].(float64); ok {
+		confidence = &value
+	}
+
+	return &Relation{
+		Head:       head,
+		Name:       name,
+		Tail:       tail,
+		Confidence: confidence,
+	}, nil
+}
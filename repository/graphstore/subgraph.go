@@ -0,0 +1,86 @@
+package graphstore
+
+/*
+subgraphBuilder 合并多条路径为子图，重复的实体和关系只保留一个。limit 非零时最多保留 limit 个实体，并只保留两端都在子图中的关系。
+*/
+type subgraphBuilder struct {
+	limit       int
+	entities    []Entity
+	relations   []Relation
+	entitySet   map[string]struct{}
+	relationSet map[relationKey]struct{}
+}
+
+type relationKey struct {
+	head string
+	name string
+	tail string
+}
+
+func newSubgraphBuilder(limit int) *subgraphBuilder {
+	return &subgraphBuilder{
+		limit:       limit,
+		entities:    make([]Entity, 0),
+		relations:   make([]Relation, 0),
+		entitySet:   make(map[string]struct{}),
+		relationSet: make(map[relationKey]struct{}),
+	}
+}
+
+/*
+addEntity 添加实体，返回实体是否在子图中
+*/
+func (b *subgraphBuilder) addEntity(entity *Entity) bool {
+	if b.hasEntity(entity.Name) {
+		return true
+	}
+
+	if b.limit != 0 && len(b.entities) >= b.limit {
+		return false
+	}
+
+	b.entitySet[entity.Name] = struct{}{}
+	b.entities = append(b.entities, *entity)
+	return true
+}
+
+func (b *subgraphBuilder) hasEntity(name string) bool {
+	_, ok := b.entitySet[name]
+	return ok
+}
+
+func (b *subgraphBuilder) addRelation(relation *Relation) {
+	if !b.hasEntity(relation.Head) || !b.hasEntity(relation.Tail) {
+		return
+	}
+
+	key := relationKey{head: relation.Head, name: relation.Name, tail: relation.Tail}
+	if _, ok := b.relationSet[key]; ok {
+		return
+	}
+
+	b.relationSet[key] = struct{}{}
+	b.relations = append(b.relations, *relation)
+}
+
+/*
+addPath 按顺序添加路径上的实体，某个实体因数量限制未被添加时，其后的实体与起点不再连通，因此也不添加。
+*/
+func (b *subgraphBuilder) addPath(path *Path) {
+	for i := range path.Entities {
+		if !b.addEntity(&path.Entities[i]) {
+			break
+		}
+	}
+
+	for i := range path.Relations {
+		b.addRelation(&path.Relations[i])
+	}
+}
+
+func (b *subgraphBuilder) subgraph() *Subgraph {
+	return &Subgraph{
+		Entities:  b.entities,
+		Relations: b.relations,
+	}
+}
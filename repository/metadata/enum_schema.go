@@ -3,6 +3,8 @@ package metadata
 const (
 	ExtractorTypeModel             uint = 1
 	ExtractorTypeHumanIntervention uint = 2
+	ExtractorTypeImported          uint = 3
+	ExtractorTypeDistant           uint = 4
 )
 
 const (
@@ -16,3 +18,46 @@ const (
 	TaskStatusDone  uint = 2
 	TaskStatusFail  uint = 3
 )
+
+const (
+	BuildStatusDraft     uint = 1
+	BuildStatusPublished uint = 2
+	BuildStatusArchived  uint = 3
+)
+
+const (
+	BuildJobStatusWaiting   uint = 1
+	BuildJobStatusRunning   uint = 2
+	BuildJobStatusDone      uint = 3
+	BuildJobStatusFail      uint = 4
+	BuildJobStatusCancelled uint = 5
+)
+
+const (
+	LabelJobStatusWaiting   uint = 1
+	LabelJobStatusRunning   uint = 2
+	LabelJobStatusDone      uint = 3
+	LabelJobStatusFail      uint = 4
+	LabelJobStatusCancelled uint = 5
+)
+
+const (
+	BuildJobStageSnapshot        uint = 1
+	BuildJobStageCollectEntities uint = 2
+	BuildJobStageCreateNodes     uint = 3
+	BuildJobStageExportCSV       uint = 4
+	BuildJobStageLoadNeo4j       uint = 5
+)
+
+/*
+实体类别，只有以下类别会作为 Neo4j 的标签，其它类别只作为属性保存。
+*/
+const (
+	EntityCategoryPerson       = "person"
+	EntityCategoryOrganization = "organization"
+	EntityCategoryLocation     = "location"
+	EntityCategoryTime         = "time"
+	EntityCategoryEvent        = "event"
+	EntityCategoryWork         = "work"
+	EntityCategoryConcept      = "concept"
+)
@@ -14,20 +14,75 @@ type SchemaExtractorExtraDeploy struct {
 	DeployYAML string
 }
 
+/*
+RelationInfo 描述了一条出边。Confidence 为该边所有 Relation 的置信度的聚合，旧版本构建的边没有置信度。
+Texts 为该边所有 Relation 来源的 Text.ID，旧版本构建的边没有来源句子。
+*/
 type RelationInfo struct {
 	Name       string     `json:"name"`
 	Files      []FileInfo `json:"files"`
 	Extractors []uint     `json:"extractors"`
+	Texts      []uint     `json:"texts,omitempty"`
+	Confidence *float64   `json:"confidence,omitempty"`
 }
 
+/*
+SchemaNodeOut 的版本：
+
+	SchemaNodeOutVersionSingleEdge 旧版本，没有 version 字段，next_nodes 的 value 为一条 RelationInfo，即每对头尾实体之间只有一条边；
+	SchemaNodeOutVersionMultiEdge next_nodes 的 value 为 RelationInfo 的列表，每对头尾实体之间可以有多条不同名的边；
+*/
+const (
+	SchemaNodeOutVersionSingleEdge uint = 1
+	SchemaNodeOutVersionMultiEdge  uint = 2
+)
+
+/*
+SchemaNodeOut 描述了节点的出边，NextNodes 的 key 为相邻节点的名字。读取旧版本的 JSON 时会自动转换为多条边的形式。
+*/
 type SchemaNodeOut struct {
-	NextNodes map[string]RelationInfo `json:"next_nodes"`
+	Version   uint                      `json:"version"`
+	NextNodes map[string][]RelationInfo `json:"next_nodes"`
 }
 
 func (no *SchemaNodeOut) ToJSON() string {
+	no.Version = SchemaNodeOutVersionMultiEdge
 	return toJSON(no)
 }
 
+func (no *SchemaNodeOut) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Version   uint            `json:"version"`
+		NextNodes json.RawMessage `json:"next_nodes"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	no.Version = SchemaNodeOutVersionMultiEdge
+	no.NextNodes = nil
+
+	if len(raw.NextNodes) == 0 || string(raw.NextNodes) == "null" {
+		return nil
+	}
+
+	if raw.Version >= SchemaNodeOutVersionMultiEdge {
+		return json.Unmarshal(raw.NextNodes, &no.NextNodes)
+	}
+
+	var singleEdge map[string]RelationInfo
+	if err := json.Unmarshal(raw.NextNodes, &singleEdge); err != nil {
+		return err
+	}
+
+	no.NextNodes = make(map[string][]RelationInfo, len(singleEdge))
+	for next, info := range singleEdge {
+		no.NextNodes[next] = []RelationInfo{info}
+	}
+
+	return nil
+}
+
 type FileInfo struct {
 	FileID   uint   `json:"file_id"`
 	FileName string `json:"file_name"`
@@ -37,6 +92,7 @@ type FileInfo struct {
 type SchemaNodeSource struct {
 	Files      []FileInfo `json:"files"`
 	Extractors []uint     `json:"extractors"`
+	Aliases    []string   `json:"aliases,omitempty"` // 归并到该节点的别名
 }
 
 func (ns *SchemaNodeSource) ToJSON() string {
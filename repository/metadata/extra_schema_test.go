@@ -0,0 +1,34 @@
+package metadata
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSchemaNodeOut_UnmarshalJSON(t *testing.T) {
+	// 旧版本的构建，每对头尾实体之间只有一条边
+	singleEdge := `{"next_nodes":{"B":{"name":"R","files":null,"extractors":[1]}}}`
+
+	var out SchemaNodeOut
+	require.Nil(t, json.Unmarshal([]byte(singleEdge), &out))
+	assert.Equal(t, SchemaNodeOutVersionMultiEdge, out.Version)
+	assert.Equal(t, map[string][]RelationInfo{
+		"B": {{Name: "R", Extractors: []uint{1}}},
+	}, out.NextNodes)
+
+	multiEdge := SchemaNodeOut{
+		NextNodes: map[string][]RelationInfo{
+			"B": {{Name: "R1", Extractors: []uint{1}}, {Name: "R2", Extractors: []uint{2}}},
+		},
+	}
+
+	var parsed SchemaNodeOut
+	require.Nil(t, json.Unmarshal([]byte(multiEdge.ToJSON()), &parsed))
+	assert.Equal(t, multiEdge, parsed)
+
+	var empty SchemaNodeOut
+	require.Nil(t, json.Unmarshal([]byte(`{}`), &empty))
+	assert.Empty(t, empty.NextNodes)
+}
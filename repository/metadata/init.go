@@ -89,6 +89,8 @@ func migration(db *gorm.DB) error {
 		&ExtractTask{}, &ExtractTaskItem{},
 		&Relation{}, &Entity{},
 		&Build{}, &BuildExtractor{}, &Node{},
+		&BuildJob{}, &LabelJob{},
+		&EntityAlias{},
 	}
 	err := db.
 		Set("gorm:table_options", "ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci").
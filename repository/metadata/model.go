@@ -96,7 +96,7 @@ Extractor 记录了抽取器（包括算法模型和人为干预）模型的元
 	Extra 为扩展预留，当Type为Model时，记录了模型的部署信息；
 	Name 模型名称；
 	Desc 模型描述；
-	Type 表示抽取器是算法模型还是人为干预，1表示算法模型，2表示人为干预。
+	Type 表示抽取器是算法模型、人为干预、外部导入还是远程监督，1表示算法模型，2表示人为干预，3表示从外部三元组文件导入，4表示用已有构建自动标注的远程监督结果。
 
 	ProducedEntities 多对一关系，由此模型抽取出的实体；
 	ProducedRelations 多对一关系，由此模型抽取出的关系；
@@ -106,7 +106,7 @@ type Extractor struct {
 	Extra
 	Name string `gorm:"type:varchar(64) not null"`
 	Desc string
-	Type uint `gorm:"comment:Model=1, HumanIntervention=2"`
+	Type uint `gorm:"comment:Model=1, HumanIntervention=2, Imported=3, Distant=4"`
 	URL  string
 
 	ProducedEntities  []Entity   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
@@ -119,19 +119,28 @@ Entity 记录了实体的元信息。
 	Extra 为扩展预留；
 	Name 实体名；
 	Type 表示该元信息表示增加实体还是删除实体，1表示增加，2表示删除（一般来说只有Extractor是人为干预时才可能为删除）
+	Category 实体类别，如 person、organization、location，为空表示未知；
+	Confidence 抽取器给出的置信度，取值为 [0, 1]，为空表示未知，构建时视为 1；
+	Begin、End 实体在来源文本 Text.Content 中的位置，以字符为单位，左闭右开，为空表示未知；
 
 	TextID	一对多关系，此实体来源的文本；
+	FileID 没有来源文本时实体来源的文件，如导入的三元组，有来源文本时以 Text.FileID 为准；
 	ExtractorID 一对多关系，抽取出次实体的模型；
 */
 type Entity struct {
 	gorm.Model
 	Extra
-	Name string `gorm:"type:varchar(32) not null;index:idx_entities_name"`
-	Type uint   `gorm:"comment:Add=1,Del=2"`
+	Name       string `gorm:"type:varchar(32) not null;index:idx_entities_name"`
+	Type       uint   `gorm:"comment:Add=1,Del=2"`
+	Category   string `gorm:"type:varchar(32)"`
+	Confidence *float64
+	Begin      *int
+	End        *int
 
 	ExtractorID  uint
 	TaskID       *uint
 	TextID       *uint
+	FileID       *uint
 	HeadEntityOf []Relation `gorm:"foreignKey:HeadID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	TailEntityOf []Relation `gorm:"foreignKey:TailID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
@@ -142,15 +151,22 @@ Relation 记录了关系的元信息。
 	Extra 为扩展预留；
 	Name 关系名
 	Type 表示该元信息表示增加实体还是删除实体，1表示增加，2表示删除（一般来说只有Extractor是人为干预时才可能为删除）
+	Confidence 抽取器给出的置信度，取值为 [0, 1]，为空表示未知，构建时视为 1；
+	Begin、End 覆盖头尾实体的最小范围在来源文本 Text.Content 中的位置，以字符为单位，左闭右开，为空表示未知；
+	FileID 没有来源文本时关系来源的文件，如导入的三元组，有来源文本时以 Text.FileID 为准；
 */
 type Relation struct {
 	gorm.Model
 	Extra
-	Name string `gorm:"type:varchar(32) not null;index:idx_relations_name"`
-	Type uint   `gorm:"comment:Add=1,Del=2"`
+	Name       string `gorm:"type:varchar(32) not null;index:idx_relations_name"`
+	Type       uint   `gorm:"comment:Add=1,Del=2"`
+	Confidence *float64
+	Begin      *int
+	End        *int
 
 	ExtractorID uint
 	TextID      *uint
+	FileID      *uint
 	TaskID      *uint
 	HeadID      uint `gorm:"type:bigint unsigned not null"`
 	TailID      uint `gorm:"type:bigint unsigned not null"`
@@ -163,11 +179,24 @@ Build 记录了一次图谱构建的元信息。
 
 	Extra 为扩展预留；
 	Desc 构建的描述；
+	BaseBuildID 增量构建所基于的构建号，全量构建时为空；
+	RelationResolver 同一对头尾实体之间存在多个关系名时使用的消解策略；
+	RelationResolverParam 消解策略的参数，JSON格式；
+	MinConfidence 参与构建的 Entity 和 Relation 的最低置信度，为 0 时不过滤；
+	Status 版本状态，1表示草稿，2表示已发布，3表示已归档，同一时间最多只有一个已发布的版本；
+	EntityFileURL、RelationFileURL 导入 Neo4j 时使用的 CSV 在 filesave 中的地址，删除版本时一并删除；
 */
 type Build struct {
 	gorm.Model
 	Extra
-	Desc string
+	Desc                  string
+	BaseBuildID           *uint
+	RelationResolver      string `gorm:"type:varchar(32)"`
+	RelationResolverParam string `gorm:"type:text"`
+	MinConfidence         float64
+	Status                uint   `gorm:"default:1;index;comment:Draft=1,Published=2,Archived=3"`
+	EntityFileURL         string `gorm:"type:varchar(255)"`
+	RelationFileURL       string `gorm:"type:varchar(255)"`
 }
 
 /*
@@ -177,15 +206,17 @@ BuildExtractor 记录了一次知识图谱构建中抽取器的快照。
 	BuildID 构建号；
 	Name 抽取器名；
 	Desc 抽取器描述；
-	Type 表示抽取器是算法模型还是人为干预，1表示算法模型，2表示人为干预。
+	Type 表示抽取器是算法模型、人为干预还是外部导入，1表示算法模型，2表示人为干预，3表示外部导入。
+	ExtractorID 快照对应的抽取器，用于增量构建时追溯抽取器的变化；
 */
 type BuildExtractor struct {
 	gorm.Model
 	Extra
-	BuildID uint   `gorm:"index:idx_name_version"`
-	Name    string `gorm:"type:varchar(64) not null;index:idx_name_version"`
-	Desc    string
-	Type    uint `gorm:"comment:Model=1, HumanIntervention=2"`
+	BuildID     uint   `gorm:"index:idx_name_version"`
+	Name        string `gorm:"type:varchar(64) not null;index:idx_name_version"`
+	Desc        string
+	Type        uint `gorm:"comment:Model=1, HumanIntervention=2, Imported=3, Distant=4"`
+	ExtractorID uint
 }
 
 /*
@@ -193,6 +224,7 @@ Node 记录了一次知识图谱构建中的节点，以及节点出边和入边
 
 	BuildID 构建号；
 	Name 实体名；
+	Category 实体类别，由构建时从 Entity.Category 中选出，为空表示未知；
 	OutJSON 通过JSON字符串描述节点的出边，描述的是Node与Node的多对多关系；
 	// InJSON 通过JSON字符串描述节点的入边，描述的是Node与Node的多对多关系；
 	SourceJSON 通过JSON字符串描述节点的来源信息，描述的是Node与File、Node与BuildExtractor的两组多对多关系；
@@ -200,11 +232,80 @@ Node 记录了一次知识图谱构建中的节点，以及节点出边和入边
 type Node struct {
 	gorm.Model
 	Extra
-	BuildID uint   `gorm:"index:idx_name_version"`
-	Name    string `gorm:"type:varchar(32) not null;index:idx_name_version"`
-	OutJSON string
+	BuildID  uint   `gorm:"index:idx_name_version"`
+	Name     string `gorm:"type:varchar(32) not null;index:idx_name_version"`
+	Category string `gorm:"type:varchar(32)"`
+	OutJSON  string
 	// InJSON     string
 	SourceJSON string
 }
 
+/*
+BuildJob 记录了一次异步的知识图谱构建任务，以及任务的进度。
+
+	Extra 为扩展预留；
+	Desc 构建的描述；
+	ExtractorListJSON 参与构建的 Extractor.ID 列表，JSON格式；
+	Status 任务状态，1表示等待，2表示进行中，3表示完成，4表示失败，5表示已取消；
+	Stage 任务当前所处的阶段，依次为：抽取器快照、收集实体、构建节点、导出CSV、导入Neo4j；
+	Progress 当前阶段的进度，单位为百分比；
+	BuildID 构建号，Build 创建前为空；
+	ErrMsg 任务失败的原因；
+*/
+type BuildJob struct {
+	gorm.Model
+	Extra
+	Desc              string
+	ExtractorListJSON string `gorm:"type:text"`
+	Status            uint   `gorm:"comment:Waiting=1,Running=2,Done=3,Fail=4,Cancelled=5"`
+	Stage             uint   `gorm:"comment:Snapshot=1,CollectEntities=2,CreateNodes=3,ExportCSV=4,LoadNeo4j=5"`
+	Progress          uint
+	BuildID           *uint
+	ErrMsg            string `gorm:"type:text"`
+}
+
+/*
+LabelJob 记录了一次异步的自动标注任务，以及任务的进度。
+
+	Extra 为扩展预留；
+	BuildID 作为远程监督知识库的构建号；
+	Name、Desc 新建的远程监督抽取器的名称和描述；
+	ConfigJSON 要标注的文件、句子及 Tagger 识别实体的方式，JSON格式；
+	Status 任务状态，1表示等待，2表示进行中，3表示完成，4表示失败，5表示已取消；
+	Progress 已标注的句子数占所有句子数的百分比；
+	ExtractorID 新建的远程监督抽取器，创建前为空，任务失败或取消时已写入的标注会被删除，此时也为空；
+	TextCount、LabeledCount、EntityCount、RelationCount 已标注的句子数、标注出关系的句子数及写入的 Entity 与 Relation 数；
+	ErrMsg 任务失败的原因；
+*/
+type LabelJob struct {
+	gorm.Model
+	Extra
+	BuildID       uint
+	Name          string `gorm:"type:varchar(64)"`
+	Desc          string
+	ConfigJSON    string `gorm:"type:text"`
+	Status        uint   `gorm:"comment:Waiting=1,Running=2,Done=3,Fail=4,Cancelled=5"`
+	Progress      uint
+	ExtractorID   *uint
+	TextCount     int
+	LabeledCount  int
+	EntityCount   int
+	RelationCount int
+	ErrMsg        string `gorm:"type:text"`
+}
+
 ///////////////////////////// 其它信息，包含系统所需的各种数据 /////////////////////////////////////////
+
+/*
+EntityAlias 记录了实体的别名，构建时名字为 Alias 的实体会被归并到名字为 CanonicalName 的 Node。
+删除别名时为软删除，以便增量构建时追溯别名的变化；同一个 Alias 最多只有一条未删除的记录。
+
+	CanonicalName 规范名，不能是其它实体的别名；
+	Alias 别名，不能是其它实体的规范名；
+*/
+type EntityAlias struct {
+	gorm.Model
+	Extra
+	CanonicalName string `gorm:"type:varchar(32) not null;index:idx_entity_aliases_canonical_name"`
+	Alias         string `gorm:"type:varchar(32) not null;index:idx_entity_aliases_alias"`
+}
@@ -2,26 +2,93 @@ package neograph
 
 import (
 	"autograph-backend-controller/utils"
+	"context"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 )
 
-func Execute(cypher string, param map[string]interface{}) ([]*neo4j.Record, error) {
-	return execute(globalDriver, cypher, param)
+func Execute(ctx context.Context, cypher string, param map[string]interface{}) ([]*neo4j.Record, error) {
+	return execute(ctx, globalDriver, cypher, param)
 }
 
-func execute(driver neo4j.Driver, cypher string, param map[string]interface{}) ([]*neo4j.Record, error) {
+type statement struct {
+	cypher string
+	param  map[string]interface{}
+}
+
+/*
+execute 在一个显式事务中执行 cypher，若 ctx 在提交前被取消，则回滚事务，保证不会留下部分写入的数据。
+*/
+func execute(ctx context.Context, driver neo4j.Driver, cypher string, param map[string]interface{}) ([]*neo4j.Record, error) {
+	var records []*neo4j.Record
+
+	err := transaction(ctx, driver, func(tx neo4j.Transaction) error {
+		res, err := tx.Run(cypher, param)
+		if err != nil {
+			return utils.WrapErrorf(err, "execute [%#v] fail", cypher)
+		}
+
+		records, err = res.Collect()
+		if err != nil {
+			return utils.WrapError(err, "collect fail")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+/*
+executeAll 在同一个显式事务中依次执行多条语句，任意一条失败时回滚全部语句。
+*/
+func executeAll(ctx context.Context, driver neo4j.Driver, statements []statement) error {
+	return transaction(ctx, driver, func(tx neo4j.Transaction) error {
+		for _, stmt := range statements {
+			res, err := tx.Run(stmt.cypher, stmt.param)
+			if err != nil {
+				return utils.WrapErrorf(err, "execute [%#v] fail", stmt.cypher)
+			}
+
+			if _, err := res.Consume(); err != nil {
+				return utils.WrapErrorf(err, "consume [%#v] fail", stmt.cypher)
+			}
+		}
+
+		return nil
+	})
+}
+
+func transaction(ctx context.Context, driver neo4j.Driver, fn func(tx neo4j.Transaction) error) error {
+	if err := ctx.Err(); err != nil {
+		return utils.WrapError(err, "context done before execute")
+	}
+
 	session := driver.NewSession(neo4j.SessionConfig{})
 	defer session.Close()
 
-	res, err := session.Run(cypher, param)
+	tx, err := session.BeginTransaction()
 	if err != nil {
-		return nil, utils.WrapErrorf(err, "execute [%#v] fail", cypher)
+		return utils.WrapError(err, "begin transaction fail")
 	}
+	defer tx.Close()
 
-	records, err := res.Collect()
-	if err != nil {
-		return nil, utils.WrapError(err, "collect fail")
+	if err := fn(tx); err != nil {
+		return err
 	}
 
-	return records, nil
+	if err := ctx.Err(); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return utils.WrapErrorf(rollbackErr, "rollback after context done [%v] fail", err)
+		}
+		return utils.WrapError(err, "context done before commit")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.WrapError(err, "commit fail")
+	}
+
+	return nil
 }
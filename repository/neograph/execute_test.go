@@ -32,7 +32,7 @@ package neograph
 //
 //	// create e1
 //	{
-//		res, err := execute(driver, "create (e1:Test{name: $name, desc: $desc, ts: $ts})", map[string]interface{}{
+//		res, err := execute(context.TODO(), driver, "create (e1:Test{name: $name, desc: $desc, ts: $ts})", map[string]interface{}{
 //			"name": "e1",
 //			"ts":   ts,
 //			"desc": string(desc),
@@ -46,7 +46,7 @@ package neograph
 //
 //	// create e1
 //	{
-//		res, err := execute(driver, "create (e2:Test{name: $name, desc: $desc, ts: $ts})", map[string]interface{}{
+//		res, err := execute(context.TODO(), driver, "create (e2:Test{name: $name, desc: $desc, ts: $ts})", map[string]interface{}{
 //			"name": "e2",
 //			"ts":   ts,
 //			"desc": string(desc),
@@ -60,7 +60,7 @@ package neograph
 //
 //	// create r
 //	{
-//		res, err := execute(driver, "match (e1:Test{ts: $ts}), (e2:Test{ts: $ts}) merge (e1)-[r:TestR{ts: $ts}]->(e2)", map[string]interface{}{
+//		res, err := execute(context.TODO(), driver, "match (e1:Test{ts: $ts}), (e2:Test{ts: $ts}) merge (e1)-[r:TestR{ts: $ts}]->(e2)", map[string]interface{}{
 //			"ts": ts,
 //		})
 //		require.Nil(t, err)
@@ -71,7 +71,7 @@ package neograph
 //	}
 //
 //	{
-//		res, err := execute(driver, "match (e1:Test{ts: $ts})-[r:TestR{ts: $ts}]->(e2:Test{ts: $ts}) return e1, r, e2", map[string]interface{}{
+//		res, err := execute(context.TODO(), driver, "match (e1:Test{ts: $ts})-[r:TestR{ts: $ts}]->(e2:Test{ts: $ts}) return e1, r, e2", map[string]interface{}{
 //			"ts": ts,
 //		})
 //		require.Nil(t, err)
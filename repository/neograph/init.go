@@ -2,6 +2,7 @@ package neograph
 
 import (
 	"autograph-backend-controller/utils"
+	"context"
 	"fmt"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 )
@@ -14,16 +15,20 @@ type Neo4jConfig struct {
 }
 
 type Config struct {
-	Neo4j Neo4jConfig
+	Neo4j          Neo4jConfig
+	CheckMigration bool
 }
 
 func GenerateTestConfig() *Config {
-	return &Config{Neo4j: Neo4jConfig{
-		Host: "localhost",
-		Port: 7687,
-		User: "neo4j",
-		Pwd:  "autograph",
-	}}
+	return &Config{
+		Neo4j: Neo4jConfig{
+			Host: "localhost",
+			Port: 7687,
+			User: "neo4j",
+			Pwd:  "autograph",
+		},
+		CheckMigration: true,
+	}
 }
 
 var globalDriver neo4j.Driver
@@ -48,6 +53,12 @@ func Init(config *Config) {
 	if err != nil {
 		panic(err)
 	}
+
+	if config.CheckMigration {
+		if err := migration(context.Background(), globalDriver); err != nil {
+			panic(utils.WrapError(err, "neo4j migration fail"))
+		}
+	}
 }
 
 func Close() {
@@ -0,0 +1,216 @@
+package neograph
+
+import (
+	"autograph-backend-controller/utils"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"regexp"
+)
+
+/*
+LoadBatchSize 每个事务写入的节点或边的数量
+*/
+const LoadBatchSize = 1000
+
+var ErrInvalidLabel = errors.New("invalid neo4j label")
+
+/*
+labelPattern 标签无法作为 Cypher 的参数，只能拼接在语句中，因此只允许由字母、数字和下划线组成的标签。
+*/
+var labelPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+/*
+LoadEntity 待导入的实体，Label 非空时作为 Entity 之外的额外标签。
+*/
+type LoadEntity struct {
+	Name     string
+	Category string
+	Source   string
+	Label    string
+}
+
+/*
+LoadRelation 待导入的关系，Confidence 为空时导入为 null。
+*/
+type LoadRelation struct {
+	Head       string
+	Name       string
+	Tail       string
+	Confidence *float64
+}
+
+/*
+VersionSource 提供一个版本的全部实体和关系。导入时先遍历全部实体，再遍历全部关系，每个方法最多被调用一次。
+*/
+type VersionSource interface {
+	Entities(ctx context.Context, fn func(entity *LoadEntity) error) error
+	Relations(ctx context.Context, fn func(relation *LoadRelation) error) error
+}
+
+func LoadVersion(ctx context.Context, version uint, source VersionSource) error {
+	return loadVersion(ctx, globalDriver, version, source)
+}
+
+/*
+loadVersion 将一个版本导入 Neo4j。实体和关系以 LoadBatchSize 为一批，通过 UNWIND 在各自的显式事务中写入。
+导入前会删除该版本已有的数据，因此可以对同一个版本重复导入；导入失败或 ctx 被取消时删除已写入的部分。
+*/
+func loadVersion(ctx context.Context, driver neo4j.Driver, version uint, source VersionSource) error {
+	if err := deleteVersion(ctx, driver, version); err != nil {
+		return utils.WrapErrorf(err, "clear version [%d] before loading fail", version)
+	}
+
+	err := loadVersionData(ctx, driver, version, source)
+	if err == nil {
+		return nil
+	}
+
+	// ctx 可能已被取消，使用新的 ctx 清理
+	if cleanupErr := deleteVersion(context.Background(), driver, version); cleanupErr != nil {
+		return utils.WrapErrorf(err, "load version [%d] fail, and cleanup fail: %v", version, cleanupErr)
+	}
+
+	return utils.WrapErrorf(err, "load version [%d] fail", version)
+}
+
+func loadVersionData(ctx context.Context, driver neo4j.Driver, version uint, source VersionSource) error {
+	entities := make([]*LoadEntity, 0, LoadBatchSize)
+	err := source.Entities(ctx, func(entity *LoadEntity) error {
+		if entity.Label != "" && !labelPattern.MatchString(entity.Label) {
+			return utils.WrapErrorf(ErrInvalidLabel, "label %#v of entity %#v", entity.Label, entity.Name)
+		}
+
+		entities = append(entities, entity)
+		if len(entities) < LoadBatchSize {
+			return nil
+		}
+
+		err := loadEntities(ctx, driver, version, entities)
+		entities = entities[:0]
+		return err
+	})
+	if err == nil {
+		err = loadEntities(ctx, driver, version, entities)
+	}
+	if err != nil {
+		return utils.WrapError(err, "load entities fail")
+	}
+
+	relations := make([]*LoadRelation, 0, LoadBatchSize)
+	err = source.Relations(ctx, func(relation *LoadRelation) error {
+		relations = append(relations, relation)
+		if len(relations) < LoadBatchSize {
+			return nil
+		}
+
+		err := loadRelations(ctx, driver, version, relations)
+		relations = relations[:0]
+		return err
+	})
+	if err == nil {
+		err = loadRelations(ctx, driver, version, relations)
+	}
+	if err != nil {
+		return utils.WrapError(err, "load relations fail")
+	}
+
+	return nil
+}
+
+/*
+loadEntities 写入一批实体，以 key 属性进行 merge，从而使用唯一约束的索引。标签不同的实体使用不同的语句，同一批实体在同一个事务中写入。
+*/
+func loadEntities(ctx context.Context, driver neo4j.Driver, version uint, entities []*LoadEntity) error {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	rowsByLabel := make(map[string][]interface{})
+	for _, entity := range entities {
+		rowsByLabel[entity.Label] = append(rowsByLabel[entity.Label], map[string]interface{}{
+			"key":      entityKey(version, entity.Name),
+			"name":     entity.Name,
+			"category": entity.Category,
+			"source":   entity.Source,
+		})
+	}
+
+	statements := make([]statement, 0, len(rowsByLabel))
+	for label, rows := range rowsByLabel {
+		setLabel := ""
+		if label != "" {
+			setLabel = fmt.Sprintf(", e:%s", label)
+		}
+
+		statements = append(statements, statement{
+			cypher: fmt.Sprintf(`
+				unwind $rows as row
+				merge (e:Entity{
+					key: row.key
+				})
+				set e.version = $version, e.name = row.name, e.category = row.category, e.source = row.source%s
+			`, setLabel),
+			param: map[string]interface{}{
+				"version": version,
+				"rows":    rows,
+			},
+		})
+	}
+
+	if err := executeAll(ctx, driver, statements); err != nil {
+		return utils.WrapErrorf(err, "load %d entities fail", len(entities))
+	}
+
+	return nil
+}
+
+/*
+loadRelations 写入一批关系。关系名是 merge 的条件之一，同一对节点之间不同名的关系会成为多条边。
+*/
+func loadRelations(ctx context.Context, driver neo4j.Driver, version uint, relations []*LoadRelation) error {
+	if len(relations) == 0 {
+		return nil
+	}
+
+	rows := make([]interface{}, 0, len(relations))
+	for _, relation := range relations {
+		var confidence interface{}
+		if relation.Confidence != nil {
+			confidence = *relation.Confidence
+		}
+
+		rows = append(rows, map[string]interface{}{
+			"head":       entityKey(version, relation.Head),
+			"name":       relation.Name,
+			"tail":       entityKey(version, relation.Tail),
+			"confidence": confidence,
+		})
+	}
+
+	err := executeAll(ctx, driver, []statement{{
+		cypher: `
+			unwind $rows as row
+			match (h:Entity{
+				key: row.head
+			}),(t:Entity{
+				key: row.tail
+			})
+			merge (h)-[r:Relation{
+				version: $version,
+				name: row.name
+			}]->(t)
+			set r.confidence = row.confidence
+		`,
+		param: map[string]interface{}{
+			"version": version,
+			"rows":    rows,
+		},
+	}})
+	if err != nil {
+		return utils.WrapErrorf(err, "load %d relations fail", len(relations))
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package neograph
+
+import (
+	"autograph-backend-controller/utils"
+	"context"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"strconv"
+	"time"
+)
+
+/*
+schemaMigration 为一次 Neo4j 模式变更。模式变更与数据写入不能在同一个事务中进行，因此每条语句在各自的事务中执行。
+语句均为幂等的，执行到一半失败后可以重新执行。
+*/
+type schemaMigration struct {
+	id         uint
+	name       string
+	statements []string
+}
+
+/*
+migrations 按 id 递增的顺序执行，已执行的变更以 (:SchemaMigration{id}) 节点记录，不会重复执行。新的变更只能追加在末尾。
+
+社区版不支持多个属性的唯一约束，因此实体额外保存 key 属性，值为 "version:name"，以此保证同一个版本中实体名唯一。
+*/
+var migrations = []schemaMigration{
+	{
+		id:   1,
+		name: "index_entity_version_name",
+		statements: []string{
+			`create index entity_version_name if not exists for (e:Entity) on (e.version, e.name)`,
+		},
+	},
+	{
+		id:   2,
+		name: "index_relation_version",
+		statements: []string{
+			`create index relation_version if not exists for ()-[r:Relation]-() on (r.version)`,
+		},
+	},
+	{
+		id:   3,
+		name: "unique_entity_key",
+		statements: []string{
+			`match (e:Entity) where e.key is null set e.key = toString(e.version) + ':' + e.name`,
+			`create constraint entity_key if not exists on (e:Entity) assert e.key is unique`,
+		},
+	},
+}
+
+/*
+entityKey 返回实体的 key 属性
+*/
+func entityKey(version uint, name string) string {
+	return strconv.FormatUint(uint64(version), 10) + ":" + name
+}
+
+func migration(ctx context.Context, driver neo4j.Driver) error {
+	records, err := execute(ctx, driver, `match (m:SchemaMigration) return m.id`, nil)
+	if err != nil {
+		return utils.WrapError(err, "select schema migrations fail")
+	}
+
+	applied := make(map[int64]struct{}, len(records))
+	for _, record := range records {
+		if id, ok := record.Values[0].(int64); ok {
+			applied[id] = struct{}{}
+		}
+	}
+
+	for _, m := range migrations {
+		if _, ok := applied[int64(m.id)]; ok {
+			continue
+		}
+
+		for _, cypher := range m.statements {
+			if _, err := execute(ctx, driver, cypher, nil); err != nil {
+				return utils.WrapErrorf(err, "apply schema migration [%d %s] fail", m.id, m.name)
+			}
+		}
+
+		_, err := execute(ctx, driver, `
+			merge (m:SchemaMigration{id: $id})
+			set m.name = $name, m.applied_at = $applied_at
+		`, map[string]interface{}{
+			"id":         m.id,
+			"name":       m.name,
+			"applied_at": time.Now().Unix(),
+		})
+		if err != nil {
+			return utils.WrapErrorf(err, "record schema migration [%d %s] fail", m.id, m.name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package neograph
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	// 已执行的变更按 id 记录，id 必须严格递增且名字不重复
+	names := make(map[string]struct{})
+	for i, m := range migrations {
+		if i > 0 {
+			assert.Greater(t, m.id, migrations[i-1].id)
+		}
+
+		assert.NotContains(t, names, m.name)
+		names[m.name] = struct{}{}
+
+		assert.NotEmpty(t, m.statements)
+	}
+
+	assert.Equal(t, "12:张三", entityKey(12, "张三"))
+	assert.NotEqual(t, entityKey(1, "2:a"), entityKey(12, ":a"))
+}
@@ -0,0 +1,39 @@
+package neograph
+
+import (
+	"autograph-backend-controller/utils"
+	"context"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+func DeleteVersion(ctx context.Context, version uint) error {
+	return deleteVersion(ctx, globalDriver, version)
+}
+
+/*
+deleteVersion 删除某个版本的所有实体节点及其关系。每个事务最多删除 LoadBatchSize 个节点，避免大版本的删除占用过多内存。
+*/
+func deleteVersion(ctx context.Context, driver neo4j.Driver, version uint) error {
+	for {
+		records, err := execute(ctx, driver, `
+			match (e:Entity{version: $version})
+			with e limit $limit
+			detach delete e
+			return count(e)
+		`, map[string]interface{}{
+			"version": version,
+			"limit":   LoadBatchSize,
+		})
+		if err != nil {
+			return utils.WrapErrorf(err, "delete entities of version [%d] fail", version)
+		}
+
+		if len(records) == 0 {
+			return nil
+		}
+
+		if deleted, ok := records[0].Values[0].(int64); !ok || deleted == 0 {
+			return nil
+		}
+	}
+}
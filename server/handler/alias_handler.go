@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"autograph-backend-controller/domain/alias"
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/server/common"
+	"autograph-backend-controller/utils"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type aliasGroupSchema struct {
+	Canonical string   `json:"canonical"`
+	Aliases   []string `json:"aliases"`
+}
+
+func ListAlias(ctx *gin.Context) {
+	groups, err := alias.ListAliases(ctx.Request.Context(), ctx.Query("canonical"))
+	if err != nil {
+		logging.Default().WithError(err).Errorf("ListAlias produce error: %s", err.Error())
+		ctx.JSON(http.StatusInternalServerError, common.MakeUnknownErrorResp())
+		return
+	}
+
+	resp := make([]aliasGroupSchema, 0, len(groups))
+	for _, group := range groups {
+		resp = append(resp, aliasGroupSchema{
+			Canonical: group.CanonicalName,
+			Aliases:   group.Aliases,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, common.MakeSuccessResp(resp))
+}
+
+func SetAlias(ctx *gin.Context) {
+	var req aliasGroupSchema
+	if err := ctx.Bind(&req); err != nil {
+		logging.Default().WithError(err).Errorf("parse req error: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, common.MakeUnknownErrorResp())
+		return
+	}
+
+	if len(req.Canonical) == 0 || len(req.Aliases) == 0 {
+		err := utils.WrapError(common.ErrRequestParamEmpty, "param canonical or aliases is empty")
+		logging.Default().WithError(err).Errorf("parse req error: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, common.MakeUnknownErrorResp())
+		return
+	}
+
+	if err := alias.SetAliases(ctx.Request.Context(), req.Canonical, req.Aliases); err != nil {
+		logging.Default().WithError(err).Errorf("produce error: %s", err.Error())
+		if errors.Is(err, alias.ErrAliasConflict) {
+			ctx.JSON(http.StatusConflict, common.MakeUnknownErrorResp())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, common.MakeUnknownErrorResp())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, common.MakeSuccessResp(nil))
+}
+
+type removeAliasReqSchema struct {
+	Aliases []string `json:"aliases"`
+}
+
+func RemoveAlias(ctx *gin.Context) {
+	var req removeAliasReqSchema
+	if err := ctx.Bind(&req); err != nil {
+		logging.Default().WithError(err).Errorf("parse req error: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, common.MakeUnknownErrorResp())
+		return
+	}
+
+	if err := alias.RemoveAliases(ctx.Request.Context(), req.Aliases); err != nil {
+		logging.Default().WithError(err).Errorf("produce error: %s", err.Error())
+		ctx.JSON(http.StatusInternalServerError, common.MakeUnknownErrorResp())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, common.MakeSuccessResp(nil))
+}
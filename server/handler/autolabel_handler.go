@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"autograph-backend-controller/domain/autolabel"
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/server/common"
+	"autograph-backend-controller/utils"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+func AutoLabel(ctx *gin.Context) {
+	handler := autoLabelHandler{
+		ctx: ctx,
+	}
+
+	if err := handler.checkParam(); err != nil {
+		logging.Default().WithError(err).Errorf("parse req error: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, common.MakeUnknownErrorResp())
+		return
+	}
+
+	jobID, err := handler.produce()
+	if err != nil {
+		logging.Default().WithError(err).Errorf("produce error: %s", err.Error())
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, autolabel.ErrNoText) {
+			ctx.JSON(http.StatusNotFound, common.MakeUnknownErrorResp())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, common.MakeUnknownErrorResp())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, common.MakeSuccessResp(autoLabelRespSchema{JobID: jobID}))
+}
+
+/*
+autoLabelHandler 提交自动标注任务，用一个构建作为知识库，在后台自动标注文件或句子，标注结果写入新建的远程监督抽取器。
+通过 /admin/autolabel/:id 查询任务的进度，完成后可以通过 /admin/dataset?extractor=<extractor_id> 或 type=4 导出
+*/
+type autoLabelHandler struct {
+	ctx *gin.Context
+
+	// params
+	config autolabel.LabelConfig
+}
+
+type autoLabelReqSchema struct {
+	tagMatcherSchema
+	Version  uint   `json:"version"`
+	Name     string `json:"name"`      // 可选，新建的抽取器名称
+	Desc     string `json:"desc"`      // 可选，新建的抽取器描述
+	FileList []uint `json:"file_list"` // 要标注的文件，与 text_list 至少有一个非空
+	TextList []uint `json:"text_list"` // 要标注的句子
+}
+
+type autoLabelRespSchema struct {
+	JobID uint `json:"job_id"`
+}
+
+func (h *autoLabelHandler) checkParam() error {
+	var req autoLabelReqSchema
+	if err := h.ctx.Bind(&req); err != nil {
+		return utils.WrapError(err, "bind req fail")
+	}
+
+	if req.Version == 0 {
+		return utils.WrapError(common.ErrRequestParamEmpty, "param version is empty")
+	}
+
+	if len(req.FileList) == 0 && len(req.TextList) == 0 {
+		return utils.WrapError(common.ErrRequestParamEmpty, "param file_list and text_list are both empty")
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if len(name) == 0 {
+		name = fmt.Sprintf("远程监督%s", time.Now().Format(time.RFC3339))
+	}
+
+	// 与 Extractor.Name 的 varchar(64) 一致
+	if utf8.RuneCountInString(name) > importMaxNameLength {
+		return utils.WrapErrorf(common.ErrRequestParamInvalid, "name %#v longer than %d", name, importMaxNameLength)
+	}
+
+	desc := strings.TrimSpace(req.Desc)
+	if len(desc) == 0 {
+		desc = fmt.Sprintf("用版本%d自动标注", req.Version)
+	}
+
+	matcher, err := req.option()
+	if err != nil {
+		return utils.WrapError(err, "parse matcher fail")
+	}
+
+	h.config = autolabel.LabelConfig{
+		BuildID:    req.Version,
+		Name:       name,
+		Desc:       desc,
+		FileIDList: req.FileList,
+		TextIDList: req.TextList,
+		Matcher:    matcher,
+	}
+
+	logging.Default().Infof("version=%d, name=%#v, matcher=%+v, files=%v, texts=%d", req.Version, name, matcher, req.FileList, len(req.TextList))
+
+	return nil
+}
+
+func (h *autoLabelHandler) produce() (uint, error) {
+	jobID, err := autolabel.Submit(h.ctx.Request.Context(), &h.config)
+	if err != nil {
+		return 0, utils.WrapErrorf(err, "submit label job with version [%d] fail", h.config.BuildID)
+	}
+
+	return jobID, nil
+}
+
+func GetLabelJob(ctx *gin.Context) {
+	handler := getLabelJobHandler{
+		ctx: ctx,
+	}
+
+	if err := handler.checkParam(); err != nil {
+		logging.Default().WithError(err).Errorf("parse req error: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, common.MakeUnknownErrorResp())
+		return
+	}
+
+	resp, err := handler.produce()
+	if err != nil {
+		logging.Default().WithError(err).Errorf("produce error: %s", err.Error())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, common.MakeUnknownErrorResp())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, common.MakeUnknownErrorResp())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, common.MakeSuccessResp(resp))
+}
+
+var labelJobStatusName = map[uint]string{
+	metadata.LabelJobStatusWaiting:   "waiting",
+	metadata.LabelJobStatusRunning:   "running",
+	metadata.LabelJobStatusDone:      "done",
+	metadata.LabelJobStatusFail:      "fail",
+	metadata.LabelJobStatusCancelled: "cancelled",
+}
+
+type getLabelJobHandler struct {
+	ctx *gin.Context
+
+	// params
+	id uint
+}
+
+type getLabelJobResp struct {
+	ID            uint   `json:"id"`
+	Version       uint   `json:"version"`
+	Name          string `json:"name"`
+	Desc          string `json:"desc"`
+	Status        string `json:"status"`
+	Progress      uint   `json:"progress"`
+	ExtractorID   *uint  `json:"extractor_id"`
+	TextCount     int    `json:"text_count"`
+	LabeledCount  int    `json:"labeled_count"`
+	EntityCount   int    `json:"entity_count"`
+	RelationCount int    `json:"relation_count"`
+	ErrMsg        string `json:"err_msg"`
+	CreateTime    int64  `json:"create_time"`
+	CreateTimeStr string `json:"create_time_str"`
+	UpdateTime    int64  `json:"update_time"`
+	UpdateTimeStr string `json:"update_time_str"`
+}
+
+func (h *getLabelJobHandler) checkParam() error {
+	id := h.ctx.Param("id")
+
+	idInteger, err := strconv.Atoi(id)
+	if err != nil {
+		return utils.WrapErrorf(err, "atoi(%#v) fail", id)
+	}
+
+	if idInteger < 0 {
+		return utils.WrapErrorf(common.ErrRequestParamInvalid, "id(%d) cannot be negative", idInteger)
+	}
+
+	h.id = uint(idInteger)
+
+	return nil
+}
+
+func (h *getLabelJobHandler) produce() (*getLabelJobResp, error) {
+	job, err := autolabel.GetJob(h.id)
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "get label job [%d] fail", h.id)
+	}
+
+	return &getLabelJobResp{
+		ID:            job.ID,
+		Version:       job.BuildID,
+		Name:          job.Name,
+		Desc:          job.Desc,
+		Status:        labelJobStatusName[job.Status],
+		Progress:      job.Progress,
+		ExtractorID:   job.ExtractorID,
+		TextCount:     job.Result.TextCount,
+		LabeledCount:  job.Result.LabeledCount,
+		EntityCount:   job.Result.EntityCount,
+		RelationCount: job.Result.RelationCount,
+		ErrMsg:        job.ErrMsg,
+		CreateTime:    job.CreateTime.Unix(),
+		CreateTimeStr: job.CreateTime.Format(time.RFC3339),
+		UpdateTime:    job.UpdateTime.Unix(),
+		UpdateTimeStr: job.UpdateTime.Format(time.RFC3339),
+	}, nil
+}
+
+func CancelLabelJob(ctx *gin.Context) {
+	handler := getLabelJobHandler{
+		ctx: ctx,
+	}
+
+	if err := handler.checkParam(); err != nil {
+		logging.Default().WithError(err).Errorf("parse req error: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, common.MakeUnknownErrorResp())
+		return
+	}
+
+	if err := autolabel.Cancel(handler.id); err != nil {
+		logging.Default().WithError(err).Errorf("produce error: %s", err.Error())
+		if errors.Is(err, autolabel.ErrJobNotRunning) {
+			ctx.JSON(http.StatusConflict, common.MakeUnknownErrorResp())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, common.MakeUnknownErrorResp())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, common.MakeSuccessResp(nil))
+}
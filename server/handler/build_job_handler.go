@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"autograph-backend-controller/domain/buildjob"
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/server/common"
+	"autograph-backend-controller/utils"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func GetBuildJob(ctx *gin.Context) {
+	handler := getBuildJobHandler{
+		ctx: ctx,
+	}
+
+	if err := handler.checkParam(); err != nil {
+		logging.Default().WithError(err).Errorf("parse req error: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, common.MakeUnknownErrorResp())
+		return
+	}
+
+	resp, err := handler.produce()
+	if err != nil {
+		logging.Default().WithError(err).Errorf("produce error: %s", err.Error())
+		ctx.JSON(http.StatusInternalServerError, common.MakeUnknownErrorResp())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, common.MakeSuccessResp(resp))
+}
+
+var buildJobStatusName = map[uint]string{
+	metadata.BuildJobStatusWaiting:   "waiting",
+	metadata.BuildJobStatusRunning:   "running",
+	metadata.BuildJobStatusDone:      "done",
+	metadata.BuildJobStatusFail:      "fail",
+	metadata.BuildJobStatusCancelled: "cancelled",
+}
+
+var buildJobStageName = map[uint]string{
+	metadata.BuildJobStageSnapshot:        "snapshot",
+	metadata.BuildJobStageCollectEntities: "collect_entities",
+	metadata.BuildJobStageCreateNodes:     "create_nodes",
+	metadata.BuildJobStageExportCSV:       "export_csv",
+	metadata.BuildJobStageLoadNeo4j:       "load_neo4j",
+}
+
+type getBuildJobHandler struct {
+	ctx *gin.Context
+
+	// params
+	id uint
+}
+
+type getBuildJobResp struct {
+	ID            uint   `json:"id"`
+	Desc          string `json:"desc"`
+	Status        string `json:"status"`
+	Stage         string `json:"stage"`
+	Progress      uint   `json:"progress"`
+	Version       *uint  `json:"version"`
+	ErrMsg        string `json:"err_msg"`
+	CreateTime    int64  `json:"create_time"`
+	CreateTimeStr string `json:"create_time_str"`
+	UpdateTime    int64  `json:"update_time"`
+	UpdateTimeStr string `json:"update_time_str"`
+}
+
+func (h *getBuildJobHandler) checkParam() error {
+	id := h.ctx.Param("id")
+
+	idInteger, err := strconv.Atoi(id)
+	if err != nil {
+		return utils.WrapErrorf(err, "atoi(%#v) fail", id)
+	}
+
+	if idInteger < 0 {
+		return utils.WrapErrorf(common.ErrRequestParamInvalid, "id(%d) cannot be negative", idInteger)
+	}
+
+	h.id = uint(idInteger)
+
+	return nil
+}
+
+func (h *getBuildJobHandler) produce() (*getBuildJobResp, error) {
+	job, err := buildjob.GetJob(h.id)
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "get build job [%d] fail", h.id)
+	}
+
+	return &getBuildJobResp{
+		ID:            job.ID,
+		Desc:          job.Desc,
+		Status:        buildJobStatusName[job.Status],
+		Stage:         buildJobStageName[job.Stage],
+		Progress:      job.Progress,
+		Version:       job.BuildID,
+		ErrMsg:        job.ErrMsg,
+		CreateTime:    job.CreateTime.Unix(),
+		CreateTimeStr: job.CreateTime.Format(time.RFC3339),
+		UpdateTime:    job.UpdateTime.Unix(),
+		UpdateTimeStr: job.UpdateTime.Format(time.RFC3339),
+	}, nil
+}
+
+func CancelBuildJob(ctx *gin.Context) {
+	handler := getBuildJobHandler{
+		ctx: ctx,
+	}
+
+	if err := handler.checkParam(); err != nil {
+		logging.Default().WithError(err).Errorf("parse req error: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, common.MakeUnknownErrorResp())
+		return
+	}
+
+	if err := buildjob.Cancel(handler.id); err != nil {
+		logging.Default().WithError(err).Errorf("produce error: %s", err.Error())
+		if errors.Is(err, buildjob.ErrJobNotRunning) {
+			ctx.JSON(http.StatusConflict, common.MakeUnknownErrorResp())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, common.MakeUnknownErrorResp())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, common.MakeSuccessResp(nil))
+}
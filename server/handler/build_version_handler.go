@@ -1,14 +1,12 @@
 package handler
 
 import (
+	"autograph-backend-controller/domain/buildjob"
 	"autograph-backend-controller/domain/graph"
 	"autograph-backend-controller/logging"
-	"autograph-backend-controller/repository/filesave"
-	"autograph-backend-controller/repository/neograph"
 	"autograph-backend-controller/server/common"
 	"autograph-backend-controller/utils"
-	"context"
-	"fmt"
+	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"net/http"
 )
@@ -24,14 +22,14 @@ func BuildVersion(ctx *gin.Context) {
 		return
 	}
 
-	version, err := handler.produce()
+	jobID, err := handler.produce()
 	if err != nil {
 		logging.Default().WithError(err).Errorf("produce error: %s", err.Error())
 		ctx.JSON(http.StatusInternalServerError, common.MakeUnknownErrorResp())
 		return
 	}
 
-	ctx.JSON(http.StatusOK, common.MakeSuccessResp(buildVersionRespSchema{Version: version}))
+	ctx.JSON(http.StatusOK, common.MakeSuccessResp(buildVersionRespSchema{JobID: jobID}))
 }
 
 type buildVersionHandler struct {
@@ -40,15 +38,25 @@ type buildVersionHandler struct {
 	// params
 	extractorIDList []uint
 	desc            string
+	baseVersion     uint
+	resolver        graph.RelationResolver
+	minConfidence   float64
 }
 
 type buildVersionReqSchema struct {
 	ExtractorList []uint `json:"extractor_list"`
 	Desc          string `json:"desc"`
+	BaseVersion   uint   `json:"base_version"` // 可选，非零时基于该版本增量构建
+
+	// 可选，同一对头尾实体之间存在多个关系名时的消解策略：majority（默认）、weighted、latest_intervention、keep_all
+	RelationResolver      string          `json:"relation_resolver"`
+	RelationResolverParam json.RawMessage `json:"relation_resolver_param"` // 可选，策略参数，如 weighted 的 {"weights":{"1":2.0},"default_weight":1}
+
+	MinConfidence float64 `json:"min_confidence"` // 可选，忽略置信度低于该值的实体和关系，取值为 [0, 1]
 }
 
 type buildVersionRespSchema struct {
-	Version uint `json:"version"`
+	JobID uint `json:"job_id"`
 }
 
 func (h *buildVersionHandler) checkParam() error {
@@ -65,82 +73,35 @@ func (h *buildVersionHandler) checkParam() error {
 		return utils.WrapError(common.ErrRequestParamEmpty, "param desc is empty")
 	}
 
-	h.extractorIDList = req.ExtractorList
-	h.desc = req.Desc
-
-	return nil
-}
-
-func (h *buildVersionHandler) produce() (uint, error) {
-	buildInfo, err := graph.BuildKG(context.TODO(), &graph.KGBuildConfig{
-		ExtractorIDList: h.extractorIDList,
-		Desc:            h.desc,
-	})
-
+	resolver, err := graph.NewRelationResolver(req.RelationResolver, string(req.RelationResolverParam))
 	if err != nil {
-		return 0, utils.WrapErrorf(err, "build kg with extractors=%#v, desc=%#v fail", h.extractorIDList, h.desc)
+		return utils.WrapErrorf(common.ErrRequestParamInvalid, "param relation_resolver=%#v is invalid: %s", req.RelationResolver, err.Error())
 	}
 
-	entityData, relationData, err := graph.TransKGToCSV(buildInfo.BuildID)
-	if err != nil {
-		return 0, utils.WrapErrorf(err, "transform kg [%d] to csv fail", buildInfo.BuildID)
-	}
-
-	entityFileInfo, err := filesave.SaveFile(entityData)
-	if err != nil {
-		return 0, utils.WrapErrorf(err, "save entity csv [buildID=%d] fail", buildInfo.BuildID)
-	}
+	h.extractorIDList = req.ExtractorList
+	h.desc = req.Desc
+	h.baseVersion = req.BaseVersion
+	h.resolver = resolver
 
-	relationFileInfo, err := filesave.SaveFile(relationData)
-	if err != nil {
-		return 0, utils.WrapErrorf(err, "save relation csv [buildID=%d] fail", buildInfo.BuildID)
+	if req.MinConfidence < 0 || req.MinConfidence > 1 {
+		return utils.WrapErrorf(common.ErrRequestParamInvalid, "param min_confidence=%v is out of [0, 1]", req.MinConfidence)
 	}
+	h.minConfidence = req.MinConfidence
 
-	entityFileURL := fmt.Sprintf("http://%s/raw/%s", filesave.GetConfig().FullHost(), entityFileInfo.URL)
-	relationFileURL := fmt.Sprintf("http://%s/raw/%s", filesave.GetConfig().FullHost(), relationFileInfo.URL)
-
-	entityCypher := `
-		load csv 
-		with headers 
-		from $url 
-		as line 
-		create(e:Entity{
-			version:toInteger(line.version),
-			name:toString(line.name),
-			source:toString(line.source)
-		});
-	`
-	relationCypher := `
-		load csv
-		with headers
-		from $url
-		as line
-		match (h:Entity{
-			version:toInteger(line.version),
-			name:toString(line.head)
-		}),(t:Entity{
-			version:toInteger(line.version),
-			name:toString(line.tail)
-		})
-		merge (h)-[r:Relation{
-			version:toInteger(line.version),
-			name:toString(line.rel)
-		}]->(t)
-	`
-
-	_, err = neograph.Execute(entityCypher, map[string]interface{}{
-		"url": entityFileURL,
-	})
-	if err != nil {
-		return 0, utils.WrapError(err, "load csv to neo4j fail")
-	}
+	return nil
+}
 
-	_, err = neograph.Execute(relationCypher, map[string]interface{}{
-		"url": relationFileURL,
+func (h *buildVersionHandler) produce() (uint, error) {
+	jobID, err := buildjob.Submit(&buildjob.JobConfig{
+		ExtractorIDList:  h.extractorIDList,
+		Desc:             h.desc,
+		BaseBuildID:      h.baseVersion,
+		RelationResolver: h.resolver,
+		MinConfidence:    h.minConfidence,
 	})
 	if err != nil {
-		return 0, utils.WrapError(err, "load csv to neo4j fail")
+		return 0, utils.WrapErrorf(err, "submit build job with extractors=%#v, desc=%#v fail", h.extractorIDList, h.desc)
 	}
 
-	return buildInfo.BuildID, nil
+	return jobID, nil
 }
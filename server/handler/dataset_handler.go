@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"autograph-backend-controller/domain/dataset"
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/server/common"
+	"autograph-backend-controller/utils"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func Dataset(ctx *gin.Context) {
+	handler := datasetHandler{
+		ctx: ctx,
+	}
+
+	if err := handler.checkParam(); err != nil {
+		logging.Default().WithError(err).Errorf("parse req error: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, common.MakeUnknownErrorResp())
+		return
+	}
+
+	if err := handler.checkVersion(); err != nil {
+		logging.Default().WithError(err).Errorf("check version error: %s", err.Error())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, common.MakeUnknownErrorResp())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, common.MakeUnknownErrorResp())
+		return
+	}
+
+	// 开始写响应后无法再返回错误码，只记录日志
+	if err := handler.write(); err != nil {
+		logging.Default().WithError(err).Errorf("write dataset error: %s", err.Error())
+	}
+}
+
+/*
+datasetHandler 导出标注过的句子作为训练数据：
+format 为 jsonl 或 bio；extractor 可选，逗号分隔的抽取器 ID；task 可选，抽取任务 ID；
+v 可选，只导出该构建所用的抽取器的输出；type 可选，抽取器类型，4 为自动标注的远程监督抽取器；intervention 可选，为 true 时人为干预中删除的实体和关系不导出
+*/
+type datasetHandler struct {
+	ctx *gin.Context
+
+	// param
+	format string
+	config dataset.DatasetConfig
+
+	writer dataset.Writer
+}
+
+func (h *datasetHandler) checkParam() error {
+	var err error
+
+	h.format = strings.ToLower(strings.TrimSpace(h.ctx.Query("format")))
+	if len(h.format) == 0 {
+		return utils.WrapError(common.ErrRequestParamEmpty, "format is empty")
+	}
+
+	for _, s := range strings.Split(h.ctx.Query("extractor"), ",") {
+		s = strings.TrimSpace(s)
+		if len(s) == 0 {
+			continue
+		}
+
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return utils.WrapErrorf(common.ErrRequestParamInvalid, "extractor %#v is not an id", s)
+		}
+		h.config.ExtractorIDList = append(h.config.ExtractorIDList, uint(id))
+	}
+
+	if s := strings.TrimSpace(h.ctx.Query("task")); len(s) != 0 {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return utils.WrapErrorf(common.ErrRequestParamInvalid, "task %#v is not an id", s)
+		}
+		taskID := uint(id)
+		h.config.TaskID = &taskID
+	}
+
+	if s := strings.TrimSpace(h.ctx.Query("v")); len(s) != 0 {
+		version, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return utils.WrapErrorf(common.ErrRequestParamInvalid, "version %#v is not an id", s)
+		}
+		buildID := uint(version)
+		h.config.BuildID = &buildID
+	}
+
+	if s := strings.TrimSpace(h.ctx.Query("type")); len(s) != 0 {
+		extractorType, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return utils.WrapErrorf(common.ErrRequestParamInvalid, "type %#v is not a number", s)
+		}
+		t := uint(extractorType)
+		h.config.ExtractorType = &t
+	}
+
+	if s := strings.TrimSpace(h.ctx.Query("intervention")); len(s) != 0 {
+		h.config.ApplyIntervention, err = strconv.ParseBool(s)
+		if err != nil {
+			return utils.WrapErrorf(common.ErrRequestParamInvalid, "intervention %#v is not a bool", s)
+		}
+	}
+
+	h.writer, err = dataset.NewWriter(h.format, h.ctx.Writer)
+	if err != nil {
+		return utils.WrapErrorf(common.ErrRequestParamInvalid, "create writer fail: %s", err.Error())
+	}
+
+	logging.Default().Infof("format=%#v, config=%+v", h.format, h.config)
+
+	return nil
+}
+
+func (h *datasetHandler) checkVersion() error {
+	if h.config.BuildID == nil {
+		return nil
+	}
+
+	var build metadata.Build
+	if err := metadata.DatabaseRaw().Select("id").Take(&build, *h.config.BuildID).Error; err != nil {
+		return utils.WrapErrorf(err, "select build [%d] fail", *h.config.BuildID)
+	}
+
+	return nil
+}
+
+func (h *datasetHandler) write() error {
+	filename := fmt.Sprintf("dataset.%s", h.writer.Extension())
+	if h.config.BuildID != nil {
+		filename = fmt.Sprintf("dataset_%d.%s", *h.config.BuildID, h.writer.Extension())
+	}
+
+	h.ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	h.ctx.Header("Content-Type", h.writer.ContentType())
+	h.ctx.Status(http.StatusOK)
+
+	if err := dataset.Export(h.ctx.Request.Context(), &h.config, h.writer); err != nil {
+		return utils.WrapErrorf(err, "export dataset as %s fail", h.format)
+	}
+
+	return nil
+}
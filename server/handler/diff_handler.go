@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"autograph-backend-controller/domain/graph"
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/server/common"
+	"autograph-backend-controller/utils"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	diffFormatJSON = "json"
+	diffFormatCSV  = "csv"
+)
+
+func Diff(ctx *gin.Context) {
+	handler := diffHandler{
+		ctx: ctx,
+	}
+
+	if err := handler.checkParam(); err != nil {
+		logging.Default().WithError(err).Errorf("parse req error: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, common.MakeUnknownErrorResp())
+		return
+	}
+
+	resp, err := handler.produce()
+	if err != nil {
+		logging.Default().WithError(err).Errorf("produce error: %s", err.Error())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, common.MakeUnknownErrorResp())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, common.MakeUnknownErrorResp())
+		return
+	}
+
+	if err := handler.write(resp); err != nil {
+		logging.Default().WithError(err).Errorf("write diff error: %s", err.Error())
+	}
+}
+
+type diffHandler struct {
+	ctx *gin.Context
+
+	// params
+	from   uint
+	to     uint
+	format string // 为空时返回普通的 JSON 响应，否则以附件形式下载
+}
+
+type diffEdge struct {
+	Head     string `json:"head"`
+	Relation string `json:"relation"`
+	Tail     string `json:"tail"`
+}
+
+type diffRenamedEdge struct {
+	Head        string `json:"head"`
+	Tail        string `json:"tail"`
+	OldRelation string `json:"old_relation"`
+	NewRelation string `json:"new_relation"`
+}
+
+type diffSourceChange struct {
+	Name              string              `json:"name"`
+	AddedFiles        []metadata.FileInfo `json:"added_files"`
+	RemovedFiles      []metadata.FileInfo `json:"removed_files"`
+	AddedExtractors   []string            `json:"added_extractors"`
+	RemovedExtractors []string            `json:"removed_extractors"`
+}
+
+type diffResp struct {
+	From           uint               `json:"from"`
+	To             uint               `json:"to"`
+	AddedNodes     []string           `json:"added_nodes"`
+	RemovedNodes   []string           `json:"removed_nodes"`
+	AddedEdges     []diffEdge         `json:"added_edges"`
+	RemovedEdges   []diffEdge         `json:"removed_edges"`
+	RenamedEdges   []diffRenamedEdge  `json:"renamed_edges"`
+	ChangedSources []diffSourceChange `json:"changed_sources"`
+}
+
+func (h *diffHandler) checkParam() error {
+	var err error
+
+	h.from, err = parseVersionParam(h.ctx.Query("from"))
+	if err != nil {
+		return utils.WrapError(err, "parse from fail")
+	}
+
+	h.to, err = parseVersionParam(h.ctx.Query("to"))
+	if err != nil {
+		return utils.WrapError(err, "parse to fail")
+	}
+
+	h.format = strings.ToLower(strings.TrimSpace(h.ctx.Query("format")))
+	if h.format != "" && h.format != diffFormatJSON && h.format != diffFormatCSV {
+		return utils.WrapErrorf(common.ErrRequestParamInvalid, "unknown format %#v", h.format)
+	}
+
+	return nil
+}
+
+func parseVersionParam(s string) (uint, error) {
+	if s == "" {
+		return 0, common.ErrRequestParamEmpty
+	}
+
+	version, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, utils.WrapErrorf(err, "atoi(%#v) fail", s)
+	}
+
+	if version <= 0 {
+		return 0, utils.WrapErrorf(common.ErrRequestParamInvalid, "version(%d) must be positive", version)
+	}
+
+	return uint(version), nil
+}
+
+func (h *diffHandler) produce() (*diffResp, error) {
+	diff, err := graph.DiffKG(h.ctx.Request.Context(), h.from, h.to)
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "diff build [%d] and [%d] fail", h.from, h.to)
+	}
+
+	ret := diffResp{
+		From:           diff.From,
+		To:             diff.To,
+		AddedNodes:     diff.AddedNodes,
+		RemovedNodes:   diff.RemovedNodes,
+		AddedEdges:     make([]diffEdge, 0, len(diff.AddedEdges)),
+		RemovedEdges:   make([]diffEdge, 0, len(diff.RemovedEdges)),
+		RenamedEdges:   make([]diffRenamedEdge, 0, len(diff.RenamedEdges)),
+		ChangedSources: make([]diffSourceChange, 0, len(diff.ChangedSources)),
+	}
+
+	for _, edge := range diff.AddedEdges {
+		ret.AddedEdges = append(ret.AddedEdges, diffEdge(edge))
+	}
+	for _, edge := range diff.RemovedEdges {
+		ret.RemovedEdges = append(ret.RemovedEdges, diffEdge(edge))
+	}
+	for _, edge := range diff.RenamedEdges {
+		ret.RenamedEdges = append(ret.RenamedEdges, diffRenamedEdge(edge))
+	}
+	for _, change := range diff.ChangedSources {
+		ret.ChangedSources = append(ret.ChangedSources, diffSourceChange(change))
+	}
+
+	return &ret, nil
+}
+
+func (h *diffHandler) write(resp *diffResp) error {
+	filename := fmt.Sprintf("diff_%d_%d.%s", resp.From, resp.To, h.format)
+
+	switch h.format {
+	case diffFormatJSON:
+		h.ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+		h.ctx.Header("Content-Type", "application/json; charset=utf-8")
+		h.ctx.Status(http.StatusOK)
+		return json.NewEncoder(h.ctx.Writer).Encode(resp)
+	case diffFormatCSV:
+		h.ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+		h.ctx.Header("Content-Type", "text/csv; charset=utf-8")
+		h.ctx.Status(http.StatusOK)
+		return writeDiffCSV(csv.NewWriter(h.ctx.Writer), resp)
+	default:
+		h.ctx.JSON(http.StatusOK, common.MakeSuccessResp(resp))
+		return nil
+	}
+}
+
+/*
+writeDiffCSV 每一行为一项变化，列为 change,name,relation,tail,detail。
+重命名的边 relation 为新关系名，detail 为旧关系名；来源变化的 detail 为变化的文件 ID 或抽取器名。
+*/
+func writeDiffCSV(w *csv.Writer, resp *diffResp) error {
+	rows := [][]string{{"change", "name", "relation", "tail", "detail"}}
+
+	for _, name := range resp.AddedNodes {
+		rows = append(rows, []string{"add_node", name, "", "", ""})
+	}
+	for _, name := range resp.RemovedNodes {
+		rows = append(rows, []string{"remove_node", name, "", "", ""})
+	}
+	for _, edge := range resp.AddedEdges {
+		rows = append(rows, []string{"add_edge", edge.Head, edge.Relation, edge.Tail, ""})
+	}
+	for _, edge := range resp.RemovedEdges {
+		rows = append(rows, []string{"remove_edge", edge.Head, edge.Relation, edge.Tail, ""})
+	}
+	for _, edge := range resp.RenamedEdges {
+		rows = append(rows, []string{"rename_edge", edge.Head, edge.NewRelation, edge.Tail, edge.OldRelation})
+	}
+	for _, change := range resp.ChangedSources {
+		for _, file := range change.AddedFiles {
+			rows = append(rows, []string{"add_file", change.Name, "", "", strconv.Itoa(int(file.FileID))})
+		}
+		for _, file := range change.RemovedFiles {
+			rows = append(rows, []string{"remove_file", change.Name, "", "", strconv.Itoa(int(file.FileID))})
+		}
+		for _, extractor := range change.AddedExtractors {
+			rows = append(rows, []string{"add_extractor", change.Name, "", "", extractor})
+		}
+		for _, extractor := range change.RemovedExtractors {
+			rows = append(rows, []string{"remove_extractor", change.Name, "", "", extractor})
+		}
+	}
+
+	if err := w.WriteAll(rows); err != nil {
+		return utils.WrapError(err, "write csv fail")
+	}
+
+	return nil
+}
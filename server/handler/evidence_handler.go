@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"autograph-backend-controller/domain/graph"
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/server/common"
+	"autograph-backend-controller/utils"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+)
+
+func Evidence(ctx *gin.Context) {
+	handler := evidenceHandler{
+		ctx: ctx,
+	}
+
+	if err := handler.checkParam(); err != nil {
+		logging.Default().WithError(err).Errorf("parse req error: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, common.MakeUnknownErrorResp())
+		return
+	}
+
+	resp, err := handler.produce()
+	if err != nil {
+		logging.Default().WithError(err).Errorf("produce error: %s", err.Error())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, common.MakeUnknownErrorResp())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, common.MakeUnknownErrorResp())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, common.MakeSuccessResp(resp))
+}
+
+const (
+	evidenceDefaultLimit = 20
+	evidenceMaxLimit     = 200
+)
+
+/*
+evidenceHandler 查询节点或边的证据：node 非空时查询节点，否则查询 head、relation、tail 确定的边
+*/
+type evidenceHandler struct {
+	ctx *gin.Context
+
+	// param
+	version  uint
+	node     string
+	head     string
+	relation string
+	tail     string
+	limit    int
+}
+
+type evidenceItem struct {
+	TextID     uint            `json:"text_id"`
+	FileID     *uint           `json:"file_id"`
+	Extractor  searchExtractor `json:"extractor"`
+	Snippet    string          `json:"snippet"`
+	Offset     int             `json:"offset"`
+	Highlights []searchSpan    `json:"highlights"`
+}
+
+type evidenceResp struct {
+	Evidences []evidenceItem `json:"evidences"`
+	Version   uint           `json:"version"`
+}
+
+func (h *evidenceHandler) checkParam() error {
+	h.node = strings.TrimSpace(h.ctx.Query("node"))
+	h.head = strings.TrimSpace(h.ctx.Query("head"))
+	h.relation = strings.TrimSpace(h.ctx.Query("relation"))
+	h.tail = strings.TrimSpace(h.ctx.Query("tail"))
+
+	if len(h.node) == 0 && (len(h.head) == 0 || len(h.relation) == 0 || len(h.tail) == 0) {
+		return utils.WrapError(common.ErrRequestParamEmpty, "either node or head, relation and tail is required")
+	}
+
+	var err error
+
+	h.version, err = versionOrPublished(h.ctx)
+	if err != nil {
+		return utils.WrapError(err, "parse version fail")
+	}
+
+	h.limit, err = optionalIntParam(h.ctx.Query("limit"), evidenceDefaultLimit, 1, evidenceMaxLimit)
+	if err != nil {
+		return utils.WrapError(err, "parse limit fail")
+	}
+
+	logging.Default().Infof("version=%d, node=%#v, head=%#v, relation=%#v, tail=%#v, limit=%d",
+		h.version, h.node, h.head, h.relation, h.tail, h.limit)
+
+	return nil
+}
+
+func (h *evidenceHandler) produce() (*evidenceResp, error) {
+	ctx := h.ctx.Request.Context()
+
+	var evidences []graph.KGEvidence
+	var err error
+	if len(h.node) != 0 {
+		evidences, err = graph.KGNodeEvidence(ctx, h.version, h.node, h.limit)
+		if err != nil {
+			return nil, utils.WrapErrorf(err, "get evidence of node [%#v] in version [%d] fail", h.node, h.version)
+		}
+	} else {
+		edge := graph.KGEdge{Head: h.head, Relation: h.relation, Tail: h.tail}
+		evidences, err = graph.KGEdgeEvidence(ctx, h.version, edge, h.limit)
+		if err != nil {
+			return nil, utils.WrapErrorf(err, "get evidence of edge [%#v] in version [%d] fail", edge, h.version)
+		}
+	}
+
+	ret := evidenceResp{
+		Evidences: make([]evidenceItem, 0, len(evidences)),
+		Version:   h.version,
+	}
+
+	for _, evidence := range evidences {
+		highlights := make([]searchSpan, 0, len(evidence.Highlights))
+		for _, span := range evidence.Highlights {
+			highlights = append(highlights, searchSpan(span))
+		}
+
+		ret.Evidences = append(ret.Evidences, evidenceItem{
+			TextID:     evidence.TextID,
+			FileID:     evidence.FileID,
+			Extractor:  searchExtractor(evidence.Extractor),
+			Snippet:    evidence.Snippet,
+			Offset:     evidence.Offset,
+			Highlights: highlights,
+		})
+	}
+
+	return &ret, nil
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"autograph-backend-controller/domain/export"
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/server/common"
+	"autograph-backend-controller/utils"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+)
+
+func Export(ctx *gin.Context) {
+	handler := exportHandler{
+		ctx: ctx,
+	}
+
+	if err := handler.checkParam(); err != nil {
+		logging.Default().WithError(err).Errorf("parse req error: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, common.MakeUnknownErrorResp())
+		return
+	}
+
+	if err := handler.checkVersion(); err != nil {
+		logging.Default().WithError(err).Errorf("check version error: %s", err.Error())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, common.MakeUnknownErrorResp())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, common.MakeUnknownErrorResp())
+		return
+	}
+
+	// 开始写响应后无法再返回错误码，只记录日志
+	if err := handler.write(); err != nil {
+		logging.Default().WithError(err).Errorf("write export error: %s", err.Error())
+	}
+}
+
+type exportHandler struct {
+	ctx *gin.Context
+
+	// param
+	version   uint
+	format    string
+	namespace string
+
+	exporter export.Exporter
+}
+
+func (h *exportHandler) checkParam() error {
+	var err error
+
+	h.version, err = versionOrPublished(h.ctx)
+	if err != nil {
+		return utils.WrapError(err, "parse version fail")
+	}
+
+	h.format = strings.ToLower(strings.TrimSpace(h.ctx.Query("format")))
+	if len(h.format) == 0 {
+		return utils.WrapError(common.ErrRequestParamEmpty, "format is empty")
+	}
+
+	h.namespace = strings.TrimSpace(h.ctx.Query("ns"))
+
+	h.exporter, err = export.NewExporter(h.format, h.ctx.Writer, &export.Option{
+		Version:   h.version,
+		Namespace: h.namespace,
+	})
+	if err != nil {
+		return utils.WrapErrorf(common.ErrRequestParamInvalid, "create exporter fail: %s", err.Error())
+	}
+
+	logging.Default().Infof("version=%d, format=%#v, namespace=%#v", h.version, h.format, h.namespace)
+
+	return nil
+}
+
+func (h *exportHandler) checkVersion() error {
+	var build metadata.Build
+	if err := metadata.DatabaseRaw().Select("id").Take(&build, h.version).Error; err != nil {
+		return utils.WrapErrorf(err, "select build [%d] fail", h.version)
+	}
+
+	return nil
+}
+
+func (h *exportHandler) write() error {
+	filename := fmt.Sprintf("kg_%d.%s", h.version, h.exporter.Extension())
+
+	h.ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	h.ctx.Header("Content-Type", h.exporter.ContentType())
+	h.ctx.Status(http.StatusOK)
+
+	if err := export.Export(h.ctx.Request.Context(), h.version, h.exporter); err != nil {
+		return utils.WrapErrorf(err, "export version [%d] as %s fail", h.version, h.format)
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"autograph-backend-controller/domain/importer"
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/server/common"
+	"autograph-backend-controller/utils"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// importMaxNameLength 抽取器名的最大长度，与 Extractor.Name 的 varchar(64) 一致
+const importMaxNameLength = 64
+
+func Import(ctx *gin.Context) {
+	handler := importHandler{
+		ctx: ctx,
+	}
+
+	if err := handler.checkParam(); err != nil {
+		logging.Default().WithError(err).Errorf("parse req error: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, common.MakeUnknownErrorResp())
+		return
+	}
+
+	resp, err := handler.produce()
+	if err != nil {
+		logging.Default().WithError(err).Errorf("produce error: %s", err.Error())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, common.MakeUnknownErrorResp())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, common.MakeUnknownErrorResp())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, common.MakeSuccessResp(resp))
+}
+
+/*
+importHandler 从 multipart 表单中读取三元组文件并导入：
+file 为三元组文件；format 为 csv、tsv、ntriples、jsonl 之一，为空时通过文件扩展名推断；
+name、desc 为新建的抽取器的名称和描述；file_id 可选，导入的实体和关系溯源到的文件
+*/
+type importHandler struct {
+	ctx *gin.Context
+
+	// params
+	name    string
+	desc    string
+	fileID  *uint
+	triples []importer.Triple
+}
+
+type importResp struct {
+	ExtractorName string `json:"extractor_name"`
+	ExtractorID   uint   `json:"extractor_id"`
+	EntityCount   int    `json:"entity_count"`
+	RelationCount int    `json:"relation_count"`
+}
+
+func (h *importHandler) checkParam() error {
+	contentType := h.ctx.GetHeader("Content-Type")
+	if !strings.Contains(contentType, "multipart/form-data") {
+		return utils.WrapErrorf(common.ErrContentTypeNotMultipartFormData,
+			"actual Content-Type = [%s] not 'multipart/form-data'", contentType)
+	}
+
+	header, err := h.ctx.FormFile("file")
+	if err != nil {
+		return utils.WrapError(err, "read multipart file fail")
+	}
+
+	format := strings.ToLower(strings.TrimSpace(h.ctx.PostForm("format")))
+	if len(format) == 0 {
+		format = importer.FormatOfFile(header.Filename)
+	}
+	if len(format) == 0 {
+		return utils.WrapErrorf(common.ErrRequestParamEmpty, "cannot infer format of %#v", header.Filename)
+	}
+
+	if fileID := strings.TrimSpace(h.ctx.PostForm("file_id")); len(fileID) != 0 {
+		id, err := strconv.Atoi(fileID)
+		if err != nil {
+			return utils.WrapErrorf(err, "atoi(%#v) fail", fileID)
+		}
+		if id <= 0 {
+			return utils.WrapErrorf(common.ErrRequestParamInvalid, "file_id(%d) must be positive", id)
+		}
+		h.fileID = utils.UintToPtr(uint(id))
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return utils.WrapError(err, "open multipart file fail")
+	}
+	defer file.Close()
+
+	h.triples, err = importer.ParseTriples(format, file)
+	if err != nil {
+		return utils.WrapErrorf(err, "parse %#v as %s fail", header.Filename, format)
+	}
+
+	if len(h.triples) == 0 {
+		return utils.WrapErrorf(common.ErrRequestParamEmpty, "no triple in %#v", header.Filename)
+	}
+
+	h.name = strings.TrimSpace(h.ctx.PostForm("name"))
+	if len(h.name) == 0 {
+		h.name = fmt.Sprintf("导入%s", time.Now().Format(time.RFC3339))
+	}
+
+	if utf8.RuneCountInString(h.name) > importMaxNameLength {
+		return utils.WrapErrorf(common.ErrRequestParamInvalid, "name %#v longer than %d", h.name, importMaxNameLength)
+	}
+
+	h.desc = strings.TrimSpace(h.ctx.PostForm("desc"))
+	if len(h.desc) == 0 {
+		h.desc = fmt.Sprintf("从%s导入", header.Filename)
+	}
+
+	logging.Default().Infof("name=%#v, format=%s, file_id=%v, triples=%d", h.name, format, h.fileID, len(h.triples))
+
+	return nil
+}
+
+func (h *importHandler) produce() (*importResp, error) {
+	res, err := importer.Import(h.ctx.Request.Context(), &importer.ImportConfig{
+		Name:    h.name,
+		Desc:    h.desc,
+		Triples: h.triples,
+		FileID:  h.fileID,
+	})
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "import %d triples fail", len(h.triples))
+	}
+
+	return &importResp{
+		ExtractorName: h.name,
+		ExtractorID:   res.ExtractorID,
+		EntityCount:   res.EntityCount,
+		RelationCount: res.RelationCount,
+	}, nil
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"autograph-backend-controller/domain/alias"
 	"autograph-backend-controller/logging"
 	"autograph-backend-controller/repository/metadata"
 	"autograph-backend-controller/server/common"
@@ -9,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -44,10 +46,15 @@ type spoSchema struct {
 }
 
 type interventionReq struct {
-	AddEntity   []string    `json:"add_entity"`
-	AddRelation []spoSchema `json:"add_relation"`
-	DelEntity   []string    `json:"del_entity"`
-	DelRelation []spoSchema `json:"del_relation"`
+	AddEntity   []string           `json:"add_entity"`
+	AddRelation []spoSchema        `json:"add_relation"`
+	DelEntity   []string           `json:"del_entity"`
+	DelRelation []spoSchema        `json:"del_relation"`
+	AddAlias    []aliasGroupSchema `json:"add_alias"`
+	DelAlias    []string           `json:"del_alias"`
+
+	// 可选，add_entity 中实体的类别，key 为实体名
+	EntityCategory map[string]string `json:"entity_category"`
 }
 
 type interventionResp struct {
@@ -94,13 +101,6 @@ func (h *interventionHandler) produce() (*interventionResp, error) {
 		entityCollection[ent] = metadata.EntityTypeDel
 	}
 
-	if len(entityCollection) == 0 {
-		return &interventionResp{
-			ExtractorName: "",
-			ExtractorID:   0,
-		}, nil
-	}
-
 	extractor := metadata.Extractor{
 		Name: fmt.Sprintf("人工干预%s", time.Now().Format(time.RFC3339)),
 		Desc: fmt.Sprintf("人工干预%s", time.Now().Format(time.RFC3339)),
@@ -110,21 +110,41 @@ func (h *interventionHandler) produce() (*interventionResp, error) {
 	entities := make([]metadata.Entity, 0, len(entityCollection))
 	relations := make([]metadata.Relation, 0, len(relationCollection))
 
-	// 入库
-	if err := metadata.DatabaseRaw().Transaction(func(tx *gorm.DB) error {
-		if err := metadata.DatabaseRaw().Create(&extractor).Error; err != nil {
+	// 入库，别名与实体、关系在同一事务中写入，任意一步失败时全部回滚
+	if err := metadata.DatabaseRaw().WithContext(h.ctx.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		for _, group := range h.req.AddAlias {
+			if err := alias.SetAliasesWithTx(tx, group.Canonical, group.Aliases); err != nil {
+				return utils.WrapErrorf(err, "set aliases %#v of [%s] fail", group.Aliases, group.Canonical)
+			}
+		}
+
+		if err := alias.RemoveAliasesWithTx(tx, h.req.DelAlias); err != nil {
+			return utils.WrapErrorf(err, "remove aliases %#v fail", h.req.DelAlias)
+		}
+
+		if len(entityCollection) == 0 {
+			return nil
+		}
+
+		if err := tx.Create(&extractor).Error; err != nil {
 			return utils.WrapError(err, "create extractor fail")
 		}
 		for ent, typ := range entityCollection {
 			entityIndex[ent] = len(entities)
+			category := ""
+			if typ == metadata.EntityTypeAdd {
+				category = strings.ToLower(strings.TrimSpace(h.req.EntityCategory[ent]))
+			}
+
 			entities = append(entities, metadata.Entity{
 				Name:        ent,
 				Type:        typ,
+				Category:    category,
 				ExtractorID: extractor.ID,
 			})
 		}
 
-		if err := metadata.DatabaseRaw().Create(&entities).Error; err != nil {
+		if err := tx.Create(&entities).Error; err != nil {
 			return utils.WrapError(err, "create entities fail")
 		}
 
@@ -142,7 +162,7 @@ func (h *interventionHandler) produce() (*interventionResp, error) {
 			})
 		}
 
-		if err := metadata.DatabaseRaw().Create(&relations).Error; err != nil {
+		if err := tx.Create(&relations).Error; err != nil {
 			return utils.WrapError(err, "create relations fail")
 		}
 
@@ -152,6 +172,13 @@ func (h *interventionHandler) produce() (*interventionResp, error) {
 		return nil, utils.WrapError(err, "insert data fail")
 	}
 
+	if len(entityCollection) == 0 {
+		return &interventionResp{
+			ExtractorName: "",
+			ExtractorID:   0,
+		}, nil
+	}
+
 	return &interventionResp{
 		ExtractorName: extractor.Name,
 		ExtractorID:   extractor.ID,
@@ -25,6 +25,17 @@ type listVersionItem struct {
 	Time    int64  `json:"time"`
 	TimeStr string `json:"time_str"`
 	Desc    string `json:"desc"`
+	Status  string `json:"status"`
+
+	RelationResolver      string  `json:"relation_resolver"`
+	RelationResolverParam string  `json:"relation_resolver_param"`
+	MinConfidence         float64 `json:"min_confidence"`
+}
+
+var buildStatusName = map[uint]string{
+	metadata.BuildStatusDraft:     "draft",
+	metadata.BuildStatusPublished: "published",
+	metadata.BuildStatusArchived:  "archived",
 }
 
 func listVersion() ([]listVersionItem, error) {
@@ -42,6 +53,11 @@ func listVersion() ([]listVersionItem, error) {
 			Time:    file.CreatedAt.Unix(),
 			TimeStr: file.CreatedAt.Format(time.RFC3339),
 			Desc:    file.Desc,
+			Status:  buildStatusName[file.Status],
+
+			RelationResolver:      file.RelationResolver,
+			RelationResolverParam: file.RelationResolverParam,
+			MinConfidence:         file.MinConfidence,
 		})
 	}
 
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"autograph-backend-controller/domain/graph"
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/repository/graphstore"
+	"autograph-backend-controller/server/common"
+	"autograph-backend-controller/utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+func Path(ctx *gin.Context) {
+	handler := pathHandler{
+		ctx: ctx,
+	}
+
+	if err := handler.checkParam(); err != nil {
+		logging.Default().WithError(err).Errorf("parse req error: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, common.MakeUnknownErrorResp())
+		return
+	}
+
+	resp, err := handler.produce()
+	if err != nil {
+		logging.Default().WithError(err).Errorf("produce error: %s", err.Error())
+		ctx.JSON(http.StatusInternalServerError, common.MakeUnknownErrorResp())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, common.MakeSuccessResp(resp))
+}
+
+const (
+	pathDefaultHops  = 4
+	pathDefaultLimit = 10
+	pathMaxLimit     = 100
+)
+
+type pathHandler struct {
+	ctx *gin.Context
+
+	// param
+	from    string
+	to      string
+	version uint
+	maxHops int
+	limit   int
+}
+
+/*
+pathEdge 路径上的一条边及其来源文件，Source 与 Target 为 graph.nodes 中的 ID
+*/
+type pathEdge struct {
+	Source string       `json:"source"`
+	Name   string       `json:"name"`
+	Target string       `json:"target"`
+	Files  []searchFile `json:"files"`
+}
+
+/*
+pathResp 中 Graph 为所有最短路径合并后的图，Paths 为每条路径依次经过的实体 ID，Edges 为图中每条边的来源文件
+*/
+type pathResp struct {
+	Graph searchGraph `json:"graph"`
+	Paths [][]string  `json:"paths"`
+	Edges []pathEdge  `json:"edges"`
+	From  string      `json:"from"`
+	To    string      `json:"to"`
+}
+
+func (h *pathHandler) checkParam() error {
+	h.from = strings.TrimSpace(h.ctx.Query("from"))
+	h.to = strings.TrimSpace(h.ctx.Query("to"))
+	if len(h.from) == 0 || len(h.to) == 0 {
+		return utils.WrapError(common.ErrRequestParamEmpty, "from or to is empty")
+	}
+	if h.from == h.to {
+		return utils.WrapErrorf(common.ErrRequestParamInvalid, "from and to are both %#v", h.from)
+	}
+
+	var err error
+
+	h.version, err = versionOrPublished(h.ctx)
+	if err != nil {
+		return utils.WrapError(err, "parse version fail")
+	}
+
+	h.maxHops, err = optionalIntParam(h.ctx.Query("max_hops"), pathDefaultHops, 1, graphstore.MaxPathDepth)
+	if err != nil {
+		return utils.WrapError(err, "parse max_hops fail")
+	}
+
+	h.limit, err = optionalIntParam(h.ctx.Query("limit"), pathDefaultLimit, 1, pathMaxLimit)
+	if err != nil {
+		return utils.WrapError(err, "parse limit fail")
+	}
+
+	logging.Default().Infof("from=%#v, to=%#v, version=%d, max_hops=%d, limit=%d", h.from, h.to, h.version, h.maxHops, h.limit)
+
+	return nil
+}
+
+func (h *pathHandler) produce() (*pathResp, error) {
+	ctx := h.ctx.Request.Context()
+
+	paths, err := graphstore.Default().Paths(ctx, h.version, &graphstore.PathQuery{
+		From:     h.from,
+		To:       h.to,
+		MaxDepth: h.maxHops,
+		Limit:    h.limit,
+	})
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "query paths with [version=%d, from=%#v, to=%#v] fail", h.version, h.from, h.to)
+	}
+
+	// 合并所有路径上的实体和关系
+	entities := make([]graphstore.Entity, 0)
+	relations := make([]graphstore.Relation, 0)
+	entitySet := make(map[string]struct{})
+	edgeSet := make(map[graph.KGEdge]struct{})
+	edges := make([]graph.KGEdge, 0)
+	for _, path := range paths {
+		for _, entity := range path.Entities {
+			if _, ok := entitySet[entity.Name]; !ok {
+				entitySet[entity.Name] = struct{}{}
+				entities = append(entities, entity)
+			}
+		}
+
+		for _, relation := range path.Relations {
+			edge := graph.KGEdge{Head: relation.Head, Relation: relation.Name, Tail: relation.Tail}
+			if _, ok := edgeSet[edge]; !ok {
+				edgeSet[edge] = struct{}{}
+				edges = append(edges, edge)
+				relations = append(relations, relation)
+			}
+		}
+	}
+
+	infos, err := graph.KGEdgeInfo(ctx, h.version, edges)
+	if err != nil {
+		return nil, utils.WrapError(err, "get edge info fail")
+	}
+
+	ids := make(map[string]string, len(entities)) // 实体名 -> ID
+	for _, entity := range entities {
+		ids[entity.Name] = entity.ID
+	}
+
+	ret := pathResp{
+		Graph: makeSearchGraph(entities, relations),
+		Paths: make([][]string, 0, len(paths)),
+		Edges: make([]pathEdge, 0, len(edges)),
+		From:  h.from,
+		To:    h.to,
+	}
+
+	for _, path := range paths {
+		nodeIDs := make([]string, 0, len(path.Entities))
+		for _, entity := range path.Entities {
+			nodeIDs = append(nodeIDs, ids[entity.Name])
+		}
+		ret.Paths = append(ret.Paths, nodeIDs)
+	}
+
+	for _, edge := range edges {
+		files := make([]searchFile, 0)
+		if info, ok := infos[edge]; ok {
+			for _, file := range info.Files {
+				files = append(files, searchFile(file))
+			}
+		}
+
+		ret.Edges = append(ret.Edges, pathEdge{
+			Source: ids[edge.Head],
+			Name:   edge.Relation,
+			Target: ids[edge.Tail],
+			Files:  files,
+		})
+	}
+
+	return &ret, nil
+}
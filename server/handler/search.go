@@ -1,17 +1,17 @@
 package handler
 
 import (
+	"autograph-backend-controller/domain/graph"
 	"autograph-backend-controller/logging"
-	"autograph-backend-controller/repository/neograph"
+	"autograph-backend-controller/repository/graphstore"
 	"autograph-backend-controller/server/common"
 	"autograph-backend-controller/utils"
 	"encoding/json"
-	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 )
 
 func Search(ctx *gin.Context) {
@@ -39,15 +39,21 @@ type searchHandler struct {
 	ctx *gin.Context
 
 	// param
-	query   string
-	version uint
-
-	// status
-	nodeSet  map[searchNode]struct{}
-	linkSet  map[searchLink]struct{}
-	fileList []searchFile
+	query      string
+	version    uint
+	categories []string
+	depth      int
+	direction  string
+	relations  []string
+	limit      int
 }
 
+const (
+	searchDefaultDepth = 2
+	searchDefaultLimit = 100
+	searchMaxLimit     = 1000
+)
+
 func (h *searchHandler) checkParam() error {
 	query := h.ctx.Query("q")
 
@@ -60,29 +66,149 @@ func (h *searchHandler) checkParam() error {
 		return utils.WrapErrorf(err, "unescape [%#v] fail", query)
 	}
 
-	versionStr := h.ctx.Query("v")
-	version, err := strconv.Atoi(versionStr)
+	version, err := versionOrPublished(h.ctx)
 	if err != nil {
-		return utils.WrapErrorf(err, "varsion atoi(%#v) fail:", versionStr)
+		return utils.WrapError(err, "parse version fail")
+	}
+
+	// 可选，逗号分隔的实体类别，只返回这些类别的相邻实体
+	categories := make([]string, 0)
+	for _, category := range strings.Split(h.ctx.Query("category"), ",") {
+		category = strings.ToLower(strings.TrimSpace(category))
+		if len(category) != 0 {
+			categories = append(categories, category)
+		}
+	}
+
+	// 可选，逗号分隔的关系名，只沿这些关系搜索
+	relations := make([]string, 0)
+	for _, relation := range strings.Split(h.ctx.Query("relation"), ",") {
+		relation = strings.TrimSpace(relation)
+		if len(relation) != 0 {
+			relations = append(relations, relation)
+		}
+	}
+
+	depth, err := optionalIntParam(h.ctx.Query("depth"), searchDefaultDepth, 1, graphstore.MaxNeighbourhoodDepth)
+	if err != nil {
+		return utils.WrapError(err, "parse depth fail")
+	}
+
+	limit, err := optionalIntParam(h.ctx.Query("limit"), searchDefaultLimit, 1, searchMaxLimit)
+	if err != nil {
+		return utils.WrapError(err, "parse limit fail")
+	}
+
+	// 可选，in/out/both，默认沿出边搜索
+	direction := strings.ToLower(strings.TrimSpace(h.ctx.Query("direction")))
+	switch direction {
+	case "":
+		direction = graphstore.DirectionOut
+	case graphstore.DirectionOut, graphstore.DirectionIn, graphstore.DirectionBoth:
+	default:
+		return utils.WrapErrorf(common.ErrRequestParamInvalid, "unknown direction %#v", direction)
 	}
 
 	h.query = unescaped
-	h.version = uint(version)
+	h.version = version
+	h.categories = categories
+	h.depth = depth
+	h.direction = direction
+	h.relations = relations
+	h.limit = limit
 
-	logging.Default().Infof("query=%#v, version=%d", h.query, h.version)
+	logging.Default().Infof("query=%#v, version=%d, categories=%#v, depth=%d, direction=%s, relations=%#v, limit=%d",
+		h.query, h.version, h.categories, h.depth, h.direction, h.relations, h.limit)
 
 	return nil
 }
 
+/*
+versionOrPublished 解析可选的版本号参数 v，为空时使用已发布的版本
+*/
+func versionOrPublished(ctx *gin.Context) (uint, error) {
+	versionStr := ctx.Query("v")
+	if len(versionStr) == 0 {
+		published, err := graph.PublishedKG(ctx.Request.Context())
+		if err != nil {
+			return 0, utils.WrapError(err, "get published version fail")
+		}
+		return published, nil
+	}
+
+	version, err := strconv.Atoi(versionStr)
+	if err != nil {
+		return 0, utils.WrapErrorf(err, "varsion atoi(%#v) fail:", versionStr)
+	}
+
+	if version < 0 {
+		return 0, utils.WrapErrorf(common.ErrRequestParamInvalid, "version(%d) cannot be negative", version)
+	}
+
+	return uint(version), nil
+}
+
+/*
+optionalIntParam 解析可选的整数参数，为空时返回 defaultValue，超出 [min, max] 时返回错误
+*/
+func optionalIntParam(s string, defaultValue, min, max int) (int, error) {
+	if len(s) == 0 {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, utils.WrapErrorf(err, "atoi(%#v) fail", s)
+	}
+
+	if value < min || value > max {
+		return 0, utils.WrapErrorf(common.ErrRequestParamInvalid, "%d out of range [%d, %d]", value, min, max)
+	}
+
+	return value, nil
+}
+
+/*
+searchLink 图中的一条边，Provenance 为边的来源，只在搜索结果中返回
+*/
 type searchLink struct {
-	Source string `json:"source"`
-	Name   string `json:"name"`
-	Target string `json:"target"`
+	Source     string            `json:"source"`
+	Name       string            `json:"name"`
+	Target     string            `json:"target"`
+	Provenance *searchProvenance `json:"provenance,omitempty"`
 }
 
-type searchNode struct {
+type searchExtractor struct {
+	ID   uint   `json:"id"`
 	Name string `json:"name"`
-	ID   string `json:"id"`
+	Type uint   `json:"type"`
+}
+
+/*
+searchSpan 实体在句子中的位置，以字符为单位，左闭右开
+*/
+type searchSpan struct {
+	Begin int `json:"begin"`
+	End   int `json:"end"`
+}
+
+type searchSentence struct {
+	TextID  uint        `json:"text_id"`
+	Content string      `json:"content"`
+	Head    *searchSpan `json:"head"`
+	Tail    *searchSpan `json:"tail"`
+}
+
+type searchProvenance struct {
+	Files      []searchFile      `json:"files"`
+	Extractors []searchExtractor `json:"extractors"`
+	Sentences  []searchSentence  `json:"sentences"`
+}
+
+type searchNode struct {
+	Name     string `json:"name"`
+	ID       string `json:"id"`
+	Category string `json:"category"`
 }
 
 type searchGraph struct {
@@ -108,208 +234,122 @@ type searchResp struct {
 }
 
 func (h *searchHandler) produce() (*searchResp, error) {
-
-	h.nodeSet = make(map[searchNode]struct{})
-	h.linkSet = make(map[searchLink]struct{})
-
-	skips := map[int]bool{
-		0: true,
-		1: true,
-	}
-	cyphers := []string{
-		`match 
-			(e1:Entity{
-				version: $version,
-				name: $name
-				})-[r1:Relation{
-					version: $version
-					}]->(e2:Entity{
-						version: $version
-					}),
-			(e3:Entity{
-				version: $version
-				})-[r2:Relation{
-					version: $version
-					}]->(e1)
-			return e1, e2, e3, r1, r2
-		`,
-		`match 
-			(e1:Entity{
-				version: $version,
-				name: $name
-				})-[r1:Relation{
-					version: $version
-					}]->(e2:Entity{
-						version: $version
-					})-[r2:Relation{
-						version: $version
-						}]->(e3:Entity{
-							version: $version
-						}),
-			(e4:Entity{
-				version: $version
-				})-[r3:Relation{
-					version: $version
-					}]->(e5:Entity{
-					version: $version
-						})-[r4:Relation{
-							version: $version
-							}]->(e1)
-			return e1, e2, e3, e4, e5, r1, r2, r3, r4
-		`,
-		`match 
-			(e1:Entity{
-				version: $version,
-				name: $name
-				})-[r1:Relation{
-					version: $version
-					}]->(e2:Entity{
-						version: $version
-					})
-			return e1, e2, r1
-		`,
-		`match 
-			(e1:Entity{
-				version: $version,
-				name: $name
-				})-[r1:Relation{
-					version: $version
-					}]->(e2:Entity{
-						version: $version
-					})-[r2:Relation{
-						version: $version
-						}]->(e3:Entity{
-							version: $version
-						})
-			return e1, e2, e3, r1, r2
-		`,
+	subgraph, err := graphstore.Default().Neighbourhood(h.ctx.Request.Context(), h.version, &graphstore.NeighbourhoodQuery{
+		Name:       h.query,
+		Depth:      h.depth,
+		Direction:  h.direction,
+		Categories: h.categories,
+		Relations:  h.relations,
+		Limit:      h.limit,
+	})
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "query neighbourhood with [version=%d, query=%#v] fail", h.version, h.query)
 	}
 
-	for i, cypher := range cyphers {
-		if skips[i] {
-			continue
-		}
-
-		if err := h.produceCypher(cypher); err != nil {
-			return nil, utils.WrapErrorf(err, "produce cypher [%d] fail", i)
+	fileList := make([]searchFile, 0)
+	for _, entity := range subgraph.Entities {
+		if entity.Name == h.query {
+			var source searchNodeSourceSchema
+			if err := json.Unmarshal([]byte(entity.Source), &source); err != nil {
+				return nil, utils.WrapErrorf(err, "unmarshal source json [%#v] fail", entity.Source)
+			}
+			fileList = source.Files
 		}
 	}
 
-	var nodeList []searchNode
-	for node := range h.nodeSet {
-		nodeList = append(nodeList, node)
-	}
-
-	var linkList []searchLink
-	for link := range h.linkSet {
-		linkList = append(linkList, link)
+	g := makeSearchGraph(subgraph.Entities, subgraph.Relations)
+	if err := h.fillProvenance(g.Links, subgraph.Relations); err != nil {
+		return nil, utils.WrapError(err, "fill provenance of links fail")
 	}
 
 	ret := searchResp{
-		Graph: searchGraph{
-			Links: linkList,
-			Nodes: nodeList,
-		},
-		Files: h.fileList,
+		Graph: g,
+		Files: fileList,
 		Query: h.query,
 	}
 
 	return &ret, nil
 }
 
-func (h *searchHandler) produceCypher(cypher string) error {
-	res, err := neograph.Execute(cypher, map[string]interface{}{
-		"version": h.version,
-		"name":    h.query,
-	})
-	if err != nil {
-		return utils.WrapErrorf(err, "execute query with [version=%d, query=%#v] fail", h.version, h.query)
+/*
+fillProvenance 填充每条边的来源，links[i] 对应 relations[i]
+*/
+func (h *searchHandler) fillProvenance(links []searchLink, relations []graphstore.Relation) error {
+	edges := make([]graph.KGEdge, 0, len(relations))
+	for _, relation := range relations {
+		edges = append(edges, graph.KGEdge{Head: relation.Head, Relation: relation.Name, Tail: relation.Tail})
 	}
 
-	for _, record := range res {
-		if err := h.produceRecord(record); err != nil {
-			return utils.WrapError(err, "produce record fail")
-		}
+	provenances, err := graph.KGEdgeProvenance(h.ctx.Request.Context(), h.version, edges)
+	if err != nil {
+		return utils.WrapErrorf(err, "get edge provenance of version [%d] fail", h.version)
 	}
 
-	return nil
-}
-
-func (h *searchHandler) produceRecord(record *neo4j.Record) error {
-	for i, valueIface := range record.Values {
-		switch value := valueIface.(type) {
-		case neo4j.Node:
-			err := h.produceNode(&value)
-			if err != nil {
-				return utils.WrapErrorf(err, "produce node [%d] fail", value.Id)
-			}
-		case neo4j.Relationship:
-			err := h.produceRelation(&value)
-			if err != nil {
-				return utils.WrapErrorf(err, "produce relation [%d] fail", value.Id)
-			}
-		default:
-			logging.Default().Warnf("key=%#v, unknown type of value=%#v", record.Keys[i], value)
+	for i, edge := range edges {
+		provenance, ok := provenances[edge]
+		if !ok {
+			continue
 		}
+
+		links[i].Provenance = makeSearchProvenance(provenance)
 	}
 
 	return nil
 }
 
-func (h *searchHandler) produceNode(node *neo4j.Node) error {
-	nameIface, exist := node.Props["name"]
-	if !exist {
-		return fmt.Errorf("node name key not found in prop [%#v]", node.Props)
+func makeSearchProvenance(provenance *graph.KGProvenance) *searchProvenance {
+	ret := searchProvenance{
+		Files:      make([]searchFile, 0, len(provenance.Files)),
+		Extractors: make([]searchExtractor, 0, len(provenance.Extractors)),
+		Sentences:  make([]searchSentence, 0, len(provenance.Sentences)),
 	}
 
-	name, ok := nameIface.(string)
-	if !ok {
-		return fmt.Errorf("node name is not string [%#v]", nameIface)
+	for _, file := range provenance.Files {
+		ret.Files = append(ret.Files, searchFile(file))
 	}
 
-	h.nodeSet[searchNode{
-		Name: name,
-		ID:   strconv.Itoa(int(node.Id)),
-	}] = struct{}{}
-
-	if len(h.fileList) == 0 && h.query == name {
-		sourceIface, exist := node.Props["source"]
-		if !exist {
-			return fmt.Errorf("node source key not found in prop [%#v]", node.Props)
-		}
-
-		sourceStr, ok := sourceIface.(string)
-		if !ok {
-			return fmt.Errorf("node source is not string [%#v]", nameIface)
-		}
-
-		var source searchNodeSourceSchema
-		if err := json.Unmarshal([]byte(sourceStr), &source); err != nil {
-			return utils.WrapErrorf(err, "unmarshal source json [%#v] fail", sourceStr)
-		}
+	for _, extractor := range provenance.Extractors {
+		ret.Extractors = append(ret.Extractors, searchExtractor(extractor))
+	}
 
-		h.fileList = source.Files
+	for _, sentence := range provenance.Sentences {
+		ret.Sentences = append(ret.Sentences, searchSentence{
+			TextID:  sentence.TextID,
+			Content: sentence.Content,
+			Head:    (*searchSpan)(sentence.Head),
+			Tail:    (*searchSpan)(sentence.Tail),
+		})
 	}
 
-	return nil
+	return &ret
 }
 
-func (h *searchHandler) produceRelation(relation *neo4j.Relationship) error {
-	nameIface, exist := relation.Props["name"]
-	if !exist {
-		return fmt.Errorf("relation name key not found in prop [%#v]", relation.Props)
+/*
+makeSearchGraph 将实体和关系转换为前端使用的图，关系的两端以实体的 ID 表示
+*/
+func makeSearchGraph(entities []graphstore.Entity, relations []graphstore.Relation) searchGraph {
+	ids := make(map[string]string, len(entities)) // 实体名 -> ID
+	nodeList := make([]searchNode, 0, len(entities))
+	for _, entity := range entities {
+		ids[entity.Name] = entity.ID
+		nodeList = append(nodeList, searchNode{
+			Name:     entity.Name,
+			ID:       entity.ID,
+			Category: entity.Category,
+		})
 	}
 
-	name, ok := nameIface.(string)
-	if !ok {
-		return fmt.Errorf("relation name is not string [%#v]", nameIface)
+	linkList := make([]searchLink, 0, len(relations))
+	for _, relation := range relations {
+		linkList = append(linkList, searchLink{
+			Source: ids[relation.Head],
+			Name:   relation.Name,
+			Target: ids[relation.Tail],
+		})
 	}
 
-	h.linkSet[searchLink{
-		Source: strconv.Itoa(int(relation.StartId)),
-		Name:   name,
-		Target: strconv.Itoa(int(relation.EndId)),
-	}] = struct{}{}
-
-	return nil
+	return searchGraph{
+		Links: linkList,
+		Nodes: nodeList,
+	}
 }
@@ -0,0 +1,276 @@
+package handler
+
+import (
+	"autograph-backend-controller/domain/graph"
+	"autograph-backend-controller/domain/suggest"
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/repository/graphstore"
+	"autograph-backend-controller/repository/metadata"
+	"autograph-backend-controller/server/common"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"testing"
+)
+
+/*
+searchFixture 搜索相关接口的测试数据。图谱载入内存中的图谱存储以代替 Neo4j，
+但构建、边的来源和名称提示仍读取元数据，因此这些测试需要 metadata.GenerateTestConfig 指向的测试数据库。
+
+	A --认识--> B --住在--> C(location) --属于--> D(location)
+*/
+type searchFixture struct {
+	engine    *gin.Engine
+	database  *gorm.DB
+	buildID   uint
+	extractor metadata.Extractor
+	text      metadata.Text
+}
+
+func newSearchFixture(t *testing.T) *searchFixture {
+	logging.SetDefaultConfig(logging.GenerateTestConfig(t))
+	gin.SetMode(gin.TestMode)
+
+	database, err := metadata.CreateDatabase(metadata.GenerateTestConfig())
+	require.Nil(t, err)
+
+	graph.Init(&graph.KGSetting{
+		GetMetadataDatabase: func() *gorm.DB {
+			return database
+		},
+		Logger: logging.NewLogger(),
+	})
+	graphstore.Init(graphstore.NewMemoryStore())
+	suggest.Init(&suggest.SuggestSetting{
+		GetMetadataDatabase: func() *gorm.DB {
+			return database
+		},
+		Logger: logging.NewLogger(),
+	})
+
+	ctx := context.TODO()
+
+	ret := searchFixture{database: database}
+
+	ret.extractor = metadata.Extractor{
+		Name: "SearchPipelineExtractor",
+		Type: metadata.ExtractorTypeModel,
+	}
+	require.Nil(t, database.Create(&ret.extractor).Error)
+
+	entity := []metadata.Entity{
+		{Name: "Pipeline-A", Type: metadata.EntityTypeAdd, ExtractorID: ret.extractor.ID, Category: metadata.EntityCategoryPerson},
+		{Name: "Pipeline-B", Type: metadata.EntityTypeAdd, ExtractorID: ret.extractor.ID, Category: metadata.EntityCategoryPerson},
+		{Name: "Pipeline-C", Type: metadata.EntityTypeAdd, ExtractorID: ret.extractor.ID, Category: metadata.EntityCategoryLocation},
+		{Name: "Pipeline-D", Type: metadata.EntityTypeAdd, ExtractorID: ret.extractor.ID, Category: metadata.EntityCategoryLocation},
+	}
+	require.Nil(t, database.Create(&entity).Error)
+
+	ret.text = metadata.Text{Content: "据说Pipeline-A认识Pipeline-B。"}
+	require.Nil(t, database.Create(&ret.text).Error)
+
+	relation := []metadata.Relation{
+		{Name: "认识", Type: metadata.EntityTypeAdd, ExtractorID: ret.extractor.ID, HeadID: entity[0].ID, TailID: entity[1].ID, TextID: &ret.text.ID},
+		{Name: "住在", Type: metadata.EntityTypeAdd, ExtractorID: ret.extractor.ID, HeadID: entity[1].ID, TailID: entity[2].ID},
+		{Name: "属于", Type: metadata.EntityTypeAdd, ExtractorID: ret.extractor.ID, HeadID: entity[2].ID, TailID: entity[3].ID},
+	}
+	require.Nil(t, database.Create(&relation).Error)
+
+	res, err := graph.BuildKG(ctx, &graph.KGBuildConfig{
+		ExtractorIDList: []uint{ret.extractor.ID},
+		Desc:            t.Name(),
+	})
+	require.Nil(t, err)
+	require.Nil(t, graphstore.Default().LoadVersion(ctx, res.BuildID, graph.NewVersionSource(res.BuildID)))
+	ret.buildID = res.BuildID
+
+	ret.engine = gin.New()
+	ret.engine.GET("/search", Search)
+	ret.engine.GET("/path", Path)
+	ret.engine.GET("/suggest", Suggest)
+
+	return &ret
+}
+
+func (f *searchFixture) get(target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	f.engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+/*
+searchResp 请求 /search 并检查请求成功
+*/
+func (f *searchFixture) searchResp(t *testing.T, query string) *searchResp {
+	w := f.get("/search?" + query)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Code int        `json:"code"`
+		Data searchResp `json:"data"`
+	}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, common.RespCodeSuccess, resp.Code)
+
+	return &resp.Data
+}
+
+/*
+search 请求 /search，返回排序后的 "头-关系->尾" 列表
+*/
+func (f *searchFixture) search(t *testing.T, query string) []string {
+	resp := f.searchResp(t, query)
+
+	names := make(map[string]string)
+	for _, node := range resp.Graph.Nodes {
+		names[node.ID] = node.Name
+	}
+
+	ret := make([]string, 0)
+	for _, link := range resp.Graph.Links {
+		ret = append(ret, fmt.Sprintf("%s-%s->%s", names[link.Source], link.Name, names[link.Target]))
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
+func TestSearch(t *testing.T) {
+	f := newSearchFixture(t)
+	ctx := context.TODO()
+
+	name := url.QueryEscape("Pipeline-A")
+	assert.Equal(t, []string{
+		"Pipeline-A-认识->Pipeline-B",
+		"Pipeline-B-住在->Pipeline-C",
+	}, f.search(t, fmt.Sprintf("q=%s&v=%d", name, f.buildID)))
+
+	assert.Equal(t, []string{
+		"Pipeline-A-认识->Pipeline-B",
+	}, f.search(t, fmt.Sprintf("q=%s&v=%d&category=person", name, f.buildID)))
+
+	// 省略版本号时使用已发布的版本
+	require.Nil(t, graph.PublishKG(ctx, f.buildID))
+	assert.Len(t, f.search(t, fmt.Sprintf("q=%s", name)), 2)
+	require.Nil(t, graph.ArchiveKG(ctx, f.buildID))
+
+	require.Nil(t, graphstore.Default().DeleteVersion(ctx, f.buildID))
+	assert.Empty(t, f.search(t, fmt.Sprintf("q=%s&v=%d", name, f.buildID)))
+}
+
+func TestSearchNeighbourhood(t *testing.T) {
+	f := newSearchFixture(t)
+
+	assert.Equal(t, []string{
+		"Pipeline-B-住在->Pipeline-C",
+		"Pipeline-C-属于->Pipeline-D",
+	}, f.search(t, fmt.Sprintf("q=%s&v=%d&depth=2&direction=in", url.QueryEscape("Pipeline-D"), f.buildID)))
+
+	assert.Equal(t, []string{
+		"Pipeline-A-认识->Pipeline-B",
+		"Pipeline-B-住在->Pipeline-C",
+		"Pipeline-C-属于->Pipeline-D",
+	}, f.search(t, fmt.Sprintf("q=%s&v=%d&depth=3&direction=both", url.QueryEscape("Pipeline-B"), f.buildID)))
+
+	name := url.QueryEscape("Pipeline-A")
+	assert.Equal(t, []string{
+		"Pipeline-A-认识->Pipeline-B",
+	}, f.search(t, fmt.Sprintf("q=%s&v=%d&depth=3&relation=%s", name, f.buildID, url.QueryEscape("认识,属于"))))
+
+	// 实体数量达到限制后不再扩展
+	assert.Len(t, f.search(t, fmt.Sprintf("q=%s&v=%d&depth=3&limit=2", name, f.buildID)), 1)
+
+	for _, query := range []string{"depth=0", "depth=100", "direction=up", "limit=0"} {
+		w := f.get(fmt.Sprintf("/search?q=%s&v=%d&%s", name, f.buildID, query))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestPath(t *testing.T) {
+	f := newSearchFixture(t)
+
+	path := func(query string) *pathResp {
+		w := f.get("/path?" + query)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp struct {
+			Code int      `json:"code"`
+			Data pathResp `json:"data"`
+		}
+		require.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Equal(t, common.RespCodeSuccess, resp.Code)
+
+		return &resp.Data
+	}
+
+	from := url.QueryEscape("Pipeline-D")
+	to := url.QueryEscape("Pipeline-A")
+
+	resp := path(fmt.Sprintf("from=%s&to=%s&v=%d", from, to, f.buildID))
+	require.Len(t, resp.Paths, 1)
+	assert.Len(t, resp.Paths[0], 4)
+	assert.Len(t, resp.Graph.Nodes, 4)
+	assert.Len(t, resp.Graph.Links, 3)
+	require.Len(t, resp.Edges, 3)
+	for _, edge := range resp.Edges {
+		assert.NotEmpty(t, edge.Source)
+		assert.NotEmpty(t, edge.Target)
+		assert.NotNil(t, edge.Files)
+	}
+
+	resp = path(fmt.Sprintf("from=%s&to=%s&v=%d&max_hops=2", from, to, f.buildID))
+	assert.Empty(t, resp.Paths)
+
+	for _, query := range []string{
+		fmt.Sprintf("from=&to=%s", to),
+		fmt.Sprintf("from=%s&to=%s&max_hops=0", from, to),
+		fmt.Sprintf("from=%s&to=%s&max_hops=100", from, to),
+		fmt.Sprintf("from=%s&to=%s&limit=0", from, to),
+		fmt.Sprintf("from=%s&to=%s", from, from),
+	} {
+		w := f.get(fmt.Sprintf("/path?%s&v=%d", query, f.buildID))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestSuggestHandler(t *testing.T) {
+	f := newSearchFixture(t)
+
+	w := f.get(fmt.Sprintf("/suggest?prefix=%s&v=%d&limit=3", url.QueryEscape("pipeline-"), f.buildID))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Code int         `json:"code"`
+		Data suggestResp `json:"data"`
+	}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data.Suggestions, 3)
+	assert.Equal(t, "Pipeline-A", resp.Data.Suggestions[0].Name)
+	assert.Equal(t, suggest.MatchPrefix, resp.Data.Suggestions[0].Match)
+}
+
+func TestSearchProvenance(t *testing.T) {
+	f := newSearchFixture(t)
+
+	resp := f.searchResp(t, fmt.Sprintf("q=%s&v=%d&depth=1", url.QueryEscape("Pipeline-A"), f.buildID))
+	require.Len(t, resp.Graph.Links, 1)
+
+	provenance := resp.Graph.Links[0].Provenance
+	require.NotNil(t, provenance)
+	require.Len(t, provenance.Extractors, 1)
+	assert.Equal(t, f.extractor.Name, provenance.Extractors[0].Name)
+
+	// 句子中的位置以字符为单位
+	require.Len(t, provenance.Sentences, 1)
+	assert.Equal(t, f.text.ID, provenance.Sentences[0].TextID)
+	assert.Equal(t, &searchSpan{Begin: 2, End: 12}, provenance.Sentences[0].Head)
+	assert.Equal(t, &searchSpan{Begin: 14, End: 24}, provenance.Sentences[0].Tail)
+}
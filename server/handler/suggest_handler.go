@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"autograph-backend-controller/domain/suggest"
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/server/common"
+	"autograph-backend-controller/utils"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+)
+
+func Suggest(ctx *gin.Context) {
+	handler := suggestHandler{
+		ctx: ctx,
+	}
+
+	if err := handler.checkParam(); err != nil {
+		logging.Default().WithError(err).Errorf("parse req error: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, common.MakeUnknownErrorResp())
+		return
+	}
+
+	resp, err := handler.produce()
+	if err != nil {
+		logging.Default().WithError(err).Errorf("produce error: %s", err.Error())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, common.MakeUnknownErrorResp())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, common.MakeUnknownErrorResp())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, common.MakeSuccessResp(resp))
+}
+
+const (
+	suggestDefaultLimit = 10
+	suggestMaxLimit     = 50
+)
+
+type suggestHandler struct {
+	ctx *gin.Context
+
+	// param
+	prefix  string
+	version uint
+	limit   int
+}
+
+type suggestItem struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	Match    string `json:"match"`
+	Distance int    `json:"distance"`
+}
+
+type suggestResp struct {
+	Suggestions []suggestItem `json:"suggestions"`
+	Prefix      string        `json:"prefix"`
+}
+
+func (h *suggestHandler) checkParam() error {
+	h.prefix = strings.TrimSpace(h.ctx.Query("prefix"))
+	if len(h.prefix) == 0 {
+		return utils.WrapError(common.ErrRequestParamEmpty, "prefix is empty")
+	}
+
+	var err error
+
+	h.version, err = versionOrPublished(h.ctx)
+	if err != nil {
+		return utils.WrapError(err, "parse version fail")
+	}
+
+	h.limit, err = optionalIntParam(h.ctx.Query("limit"), suggestDefaultLimit, 1, suggestMaxLimit)
+	if err != nil {
+		return utils.WrapError(err, "parse limit fail")
+	}
+
+	logging.Default().Infof("prefix=%#v, version=%d, limit=%d", h.prefix, h.version, h.limit)
+
+	return nil
+}
+
+func (h *suggestHandler) produce() (*suggestResp, error) {
+	suggestions, err := suggest.Suggest(h.ctx.Request.Context(), h.version, h.prefix, h.limit)
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "suggest %#v in version [%d] fail", h.prefix, h.version)
+	}
+
+	ret := suggestResp{
+		Suggestions: make([]suggestItem, 0, len(suggestions)),
+		Prefix:      h.prefix,
+	}
+	for _, s := range suggestions {
+		ret.Suggestions = append(ret.Suggestions, suggestItem(s))
+	}
+
+	return &ret, nil
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"autograph-backend-controller/domain/graph"
+	"autograph-backend-controller/domain/tagger"
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/server/common"
+	"autograph-backend-controller/utils"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"unicode/utf8"
+)
+
+// tagMaxTextLength 一次标注的文本的最大字符数
+const tagMaxTextLength = 10000
+
+func Tag(ctx *gin.Context) {
+	handler := tagHandler{
+		ctx: ctx,
+	}
+
+	if err := handler.checkParam(); err != nil {
+		logging.Default().WithError(err).Errorf("parse req error: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, common.MakeUnknownErrorResp())
+		return
+	}
+
+	resp, err := handler.produce()
+	if err != nil {
+		logging.Default().WithError(err).Errorf("produce error: %s", err.Error())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, common.MakeUnknownErrorResp())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, common.MakeUnknownErrorResp())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, common.MakeSuccessResp(resp))
+}
+
+/*
+tagHandler 用构建的节点和边标注提交的文本，返回文本中出现的已知三元组，位置以字符为单位，左闭右开
+*/
+type tagHandler struct {
+	ctx *gin.Context
+
+	// params
+	version uint
+	text    string
+	matcher tagger.MatcherOption
+}
+
+type tagReqSchema struct {
+	tagMatcherSchema
+	Version uint   `json:"version"` // 可选，为 0 时使用已发布的版本
+	Text    string `json:"text"`
+}
+
+/*
+tagMatcherSchema 可选的实体识别方式：match_mode 为 jieba（默认）、longest、overlap；
+fold_case、fold_width 为 true 时忽略大小写、全角半角，只对 longest、overlap 生效
+*/
+type tagMatcherSchema struct {
+	MatchMode string `json:"match_mode"`
+	FoldCase  bool   `json:"fold_case"`
+	FoldWidth bool   `json:"fold_width"`
+}
+
+func (s *tagMatcherSchema) option() (tagger.MatcherOption, error) {
+	mode, err := tagger.ParseMatchMode(s.MatchMode)
+	if err != nil {
+		return tagger.MatcherOption{}, utils.WrapErrorf(common.ErrRequestParamInvalid, "param match_mode is invalid: %s", err.Error())
+	}
+
+	return tagger.MatcherOption{
+		Mode:      mode,
+		FoldCase:  s.FoldCase,
+		FoldWidth: s.FoldWidth,
+	}, nil
+}
+
+type tagEntity struct {
+	Name  string `json:"name"`
+	Begin int    `json:"begin"`
+	End   int    `json:"end"`
+}
+
+type tagTriple struct {
+	Head     tagEntity `json:"head"`
+	Relation string    `json:"relation"`
+	Tail     tagEntity `json:"tail"`
+}
+
+type tagResp struct {
+	Triples []tagTriple `json:"triples"`
+	Version uint        `json:"version"`
+}
+
+func (h *tagHandler) checkParam() error {
+	var req tagReqSchema
+	if err := h.ctx.Bind(&req); err != nil {
+		return utils.WrapError(err, "bind req fail")
+	}
+
+	if len(req.Text) == 0 {
+		return utils.WrapError(common.ErrRequestParamEmpty, "param text is empty")
+	}
+
+	if length := utf8.RuneCountInString(req.Text); length > tagMaxTextLength {
+		return utils.WrapErrorf(common.ErrRequestParamInvalid, "param text length %d longer than %d", length, tagMaxTextLength)
+	}
+
+	h.version = req.Version
+	if h.version == 0 {
+		published, err := graph.PublishedKG(h.ctx.Request.Context())
+		if err != nil {
+			return utils.WrapError(err, "get published version fail")
+		}
+		h.version = published
+	}
+	h.text = req.Text
+
+	var err error
+	h.matcher, err = req.option()
+	if err != nil {
+		return utils.WrapError(err, "parse matcher fail")
+	}
+
+	logging.Default().Infof("version=%d, matcher=%+v, text length=%d", h.version, h.matcher, len(h.text))
+
+	return nil
+}
+
+func (h *tagHandler) produce() (*tagResp, error) {
+	t, release, err := tagger.Acquire(h.ctx.Request.Context(), h.version, h.matcher)
+	if err != nil {
+		return nil, utils.WrapErrorf(err, "acquire tagger of version [%d] fail", h.version)
+	}
+	defer release()
+
+	triples := t.Produce(h.text)
+
+	ret := tagResp{
+		Triples: make([]tagTriple, 0, len(triples)),
+		Version: h.version,
+	}
+	for _, triple := range triples {
+		ret.Triples = append(ret.Triples, tagTriple{
+			Head:     makeTagEntity(h.text, triple.HeadEntity),
+			Relation: triple.Relation,
+			Tail:     makeTagEntity(h.text, triple.TailEntity),
+		})
+	}
+
+	return &ret, nil
+}
+
+func makeTagEntity(text string, entity tagger.EntityInfo) tagEntity {
+	r := tagger.RuneRange(text, entity.Range)
+	return tagEntity{
+		Name:  entity.Name,
+		Begin: r.Begin,
+		End:   r.End,
+	}
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"autograph-backend-controller/domain/buildjob"
+	"autograph-backend-controller/domain/graph"
+	"autograph-backend-controller/logging"
+	"autograph-backend-controller/server/common"
+	"autograph-backend-controller/utils"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+)
+
+func PublishVersion(ctx *gin.Context) {
+	handleVersion(ctx, graph.PublishKG)
+}
+
+func ArchiveVersion(ctx *gin.Context) {
+	handleVersion(ctx, graph.ArchiveKG)
+}
+
+func DeleteVersion(ctx *gin.Context) {
+	handleVersion(ctx, buildjob.DeleteVersion)
+}
+
+/*
+handleVersion 解析路径中的版本号并执行 action。版本不存在时返回 404，版本状态不允许该操作时返回 409。
+*/
+func handleVersion(ctx *gin.Context, action func(context.Context, uint) error) {
+	handler := versionHandler{
+		ctx: ctx,
+	}
+
+	if err := handler.checkParam(); err != nil {
+		logging.Default().WithError(err).Errorf("parse req error: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, common.MakeUnknownErrorResp())
+		return
+	}
+
+	if err := action(ctx.Request.Context(), handler.id); err != nil {
+		logging.Default().WithError(err).Errorf("produce error: %s", err.Error())
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			ctx.JSON(http.StatusNotFound, common.MakeUnknownErrorResp())
+		case errors.Is(err, graph.ErrBuildNotReady), errors.Is(err, graph.ErrBuildPublished):
+			ctx.JSON(http.StatusConflict, common.MakeUnknownErrorResp())
+		default:
+			ctx.JSON(http.StatusInternalServerError, common.MakeUnknownErrorResp())
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, common.MakeSuccessResp(nil))
+}
+
+type versionHandler struct {
+	ctx *gin.Context
+
+	// params
+	id uint
+}
+
+func (h *versionHandler) checkParam() error {
+	id := h.ctx.Param("id")
+
+	idInteger, err := strconv.Atoi(id)
+	if err != nil {
+		return utils.WrapErrorf(err, "atoi(%#v) fail", id)
+	}
+
+	if idInteger <= 0 {
+		return utils.WrapErrorf(common.ErrRequestParamInvalid, "id(%d) must be positive", idInteger)
+	}
+
+	h.id = uint(idInteger)
+
+	return nil
+}
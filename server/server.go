@@ -36,12 +36,31 @@ func New(config *Config) *Server {
 		adminGroup.Use(common.RejectNotLogin(config.DebugMode))
 
 		adminGroup.POST("/upload", handler.UploadFile)
+		adminGroup.POST("/import", handler.Import)
+		adminGroup.POST("/autolabel", handler.AutoLabel)
+		adminGroup.GET("/autolabel/:id", handler.GetLabelJob)
+		adminGroup.POST("/autolabel/:id/cancel", handler.CancelLabelJob)
 		adminGroup.POST("/build", handler.BuildVersion)
+		adminGroup.GET("/build/:id", handler.GetBuildJob)
+		adminGroup.POST("/build/:id/cancel", handler.CancelBuildJob)
 		adminGroup.GET("/listfile", handler.ListFile)
 		adminGroup.GET("/listversion", handler.ListVersion)
+		adminGroup.POST("/version/:id/publish", handler.PublishVersion)
+		adminGroup.POST("/version/:id/archive", handler.ArchiveVersion)
+		adminGroup.POST("/version/:id/delete", handler.DeleteVersion)
 		adminGroup.GET("/listextractor", handler.ListExtractor)
 		adminGroup.POST("/intervention", handler.Intervention)
+		adminGroup.GET("/alias", handler.ListAlias)
+		adminGroup.POST("/alias", handler.SetAlias)
+		adminGroup.POST("/alias/delete", handler.RemoveAlias)
 		adminGroup.GET("/search", handler.Search)
+		adminGroup.GET("/path", handler.Path)
+		adminGroup.GET("/suggest", handler.Suggest)
+		adminGroup.POST("/tag", handler.Tag)
+		adminGroup.GET("/diff", handler.Diff)
+		adminGroup.GET("/evidence", handler.Evidence)
+		adminGroup.GET("/export", handler.Export)
+		adminGroup.GET("/dataset", handler.Dataset)
 	}
 
 	return &Server{
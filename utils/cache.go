@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"sync"
+)
+
+/*
+LRUCache 带引用计数的缓存，超过容量时淘汰最久未使用的值。
+
+值被淘汰或移除时若仍有引用，等最后一个引用释放后再调用 free。每次 Remove 都会增加代数，
+调用方在加载前通过 Generation 取得代数并在 Put 时传入，加载期间发生过 Remove 时加载的值可能已经过期，因此不放入缓存。
+*/
+type LRUCache[K comparable, V any] struct {
+	mu         sync.Mutex
+	max        int
+	free       func(V) // 可以为 nil
+	order      []K     // 按最近使用排序，最后一个为最近使用的
+	items      map[K]*lruItem[V]
+	generation uint64
+}
+
+type lruItem[V any] struct {
+	value   V
+	refs    int
+	evicted bool
+}
+
+func NewLRUCache[K comparable, V any](max int, free func(V)) *LRUCache[K, V] {
+	return &LRUCache[K, V]{
+		max:   max,
+		free:  free,
+		order: make([]K, 0, max),
+		items: make(map[K]*lruItem[V], max),
+	}
+}
+
+/*
+Acquire 返回缓存中的值并增加引用，使用完后需要调用返回的 release。
+*/
+func (c *LRUCache[K, V]) Acquire(key K) (V, func(), bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, nil, false
+	}
+
+	c.touch(key)
+	item.refs++
+
+	return item.value, c.releaseFunc(item), true
+}
+
+/*
+Generation 返回当前的代数，在加载要放入缓存的值之前调用
+*/
+func (c *LRUCache[K, V]) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+/*
+Put 将加载的值放入缓存并增加引用，generation 为加载前 Generation 的返回值。
+并发加载同一个键时保留先放入的，释放 value 并返回已缓存的；加载期间发生过 Remove 时不放入缓存，value 在 release 后释放。
+*/
+func (c *LRUCache[K, V]) Put(key K, value V, generation uint64) (V, func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		item := &lruItem[V]{value: value, refs: 1, evicted: true}
+		return value, c.releaseFunc(item)
+	}
+
+	if item, ok := c.items[key]; ok {
+		c.freeValue(value)
+
+		c.touch(key)
+		item.refs++
+		return item.value, c.releaseFunc(item)
+	}
+
+	for len(c.order) >= c.max {
+		c.evict(c.order[0])
+	}
+
+	item := &lruItem[V]{value: value, refs: 1}
+	c.items[key] = item
+	c.order = append(c.order, key)
+
+	return value, c.releaseFunc(item)
+}
+
+/*
+Remove 移除所有满足 match 的键并增加代数
+*/
+func (c *LRUCache[K, V]) Remove(match func(K) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for key := range c.items {
+		if match(key) {
+			c.evict(key)
+		}
+	}
+}
+
+func (c *LRUCache[K, V]) touch(key K) {
+	for i, k := range c.order {
+		if k == key {
+			c.order = append(append(c.order[:i:i], c.order[i+1:]...), key)
+			break
+		}
+	}
+}
+
+/*
+evict 将值移出缓存，没有引用时立即释放。需要持有锁。
+*/
+func (c *LRUCache[K, V]) evict(key K) {
+	item, ok := c.items[key]
+	if !ok {
+		return
+	}
+
+	delete(c.items, key)
+	for i, k := range c.order {
+		if k == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+
+	item.evicted = true
+	if item.refs == 0 {
+		c.freeValue(item.value)
+	}
+}
+
+func (c *LRUCache[K, V]) freeValue(value V) {
+	if c.free != nil {
+		c.free(value)
+	}
+}
+
+func (c *LRUCache[K, V]) releaseFunc(item *lruItem[V]) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			item.refs--
+			if item.refs == 0 && item.evicted {
+				c.freeValue(item.value)
+			}
+		})
+	}
+}
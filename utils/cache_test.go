@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestLRUCache(t *testing.T) {
+	freed := make([]string, 0)
+	cache := NewLRUCache[int, string](2, func(value string) {
+		freed = append(freed, value)
+	})
+
+	_, releaseA := cache.Put(1, "a", cache.Generation())
+	_, releaseB := cache.Put(2, "b", cache.Generation())
+	releaseA()
+	releaseB()
+
+	// 使用过的值不会被先淘汰
+	_, releaseA, ok := cache.Acquire(1)
+	require.True(t, ok)
+	releaseA()
+	_, releaseC := cache.Put(3, "c", cache.Generation())
+	releaseC()
+	assert.Equal(t, []string{"b"}, freed)
+
+	value, releaseA, ok := cache.Acquire(1)
+	require.True(t, ok)
+	assert.Equal(t, "a", value)
+
+	// 移除时仍有引用，释放后才 free
+	cache.Remove(func(key int) bool {
+		return key == 1
+	})
+	assert.Equal(t, []string{"b"}, freed)
+	releaseA()
+	assert.Equal(t, []string{"b", "a"}, freed)
+	_, _, ok = cache.Acquire(1)
+	assert.False(t, ok)
+}
+
+func TestLRUCache_Generation(t *testing.T) {
+	freed := make([]string, 0)
+	cache := NewLRUCache[int, string](2, func(value string) {
+		freed = append(freed, value)
+	})
+
+	// 加载期间发生移除，加载的值可能已经过期，不放入缓存
+	generation := cache.Generation()
+	cache.Remove(func(key int) bool {
+		return key == 1
+	})
+	value, release := cache.Put(1, "stale", generation)
+	assert.Equal(t, "stale", value)
+	_, _, ok := cache.Acquire(1)
+	assert.False(t, ok)
+	assert.Empty(t, freed)
+	release()
+	assert.Equal(t, []string{"stale"}, freed)
+
+	_, release = cache.Put(1, "fresh", cache.Generation())
+	release()
+	value, release, ok = cache.Acquire(1)
+	require.True(t, ok)
+	assert.Equal(t, "fresh", value)
+	release()
+}